@@ -0,0 +1,86 @@
+// Package audio 读取语音消息的时长和波形，支持 WAV（PCM/浮点）和 Ogg/Opus。
+//
+// WAV 按采样计算每一段的音量；Opus 不做解码，用每个数据包的码率近似音量
+// （Opus 是可变码率编码，静音时数据包很小），足够绘制语音气泡中的波形。
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"math"
+)
+
+// WaveformBars 波形的柱数，每个值为 0-100 的相对音量
+const WaveformBars = 64
+
+// ErrUnsupported 不支持的音频格式
+var ErrUnsupported = errors.New("不支持的音频格式，仅支持 WAV 和 Ogg/Opus")
+
+// Info 音频信息
+type Info struct {
+	DurationMs int   // 时长（毫秒）
+	Waveform   []int // 降采样后的波形，最多 WaveformBars 个值
+}
+
+// Analyze 根据文件头识别格式并读取时长和波形
+func Analyze(r io.Reader) (*Info, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	head, err := br.Peek(12)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	switch {
+	case bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WAVE")):
+		return analyzeWAV(br)
+	case bytes.Equal(head[:4], []byte("OggS")):
+		return analyzeOgg(br)
+	}
+	return nil, ErrUnsupported
+}
+
+// buckets 将按时间分布的音量值汇总为波形
+type buckets struct {
+	sum   []float64
+	count []int
+}
+
+func newBuckets(n int) *buckets {
+	n = max(min(n, WaveformBars), 1)
+	return &buckets{sum: make([]float64, n), count: make([]int, n)}
+}
+
+// add 累加位于 pos/total 处的音量
+func (b *buckets) add(pos, total int64, v float64) {
+	i := 0
+	if total > 0 {
+		i = int(pos * int64(len(b.sum)) / total)
+	}
+	i = min(max(i, 0), len(b.sum)-1)
+	b.sum[i] += v
+	b.count[i]++
+}
+
+// waveform 每段取平均值，按最大值归一化到 0-100
+func (b *buckets) waveform(root bool) []int {
+	values := make([]float64, len(b.sum))
+	peak := 0.0
+	for i := range values {
+		if b.count[i] > 0 {
+			values[i] = b.sum[i] / float64(b.count[i])
+			if root {
+				values[i] = math.Sqrt(values[i])
+			}
+		}
+		peak = max(peak, values[i])
+	}
+	waveform := make([]int, len(values))
+	if peak == 0 {
+		return waveform
+	}
+	for i, v := range values {
+		waveform[i] = int(math.Round(v / peak * 100))
+	}
+	return waveform
+}
@@ -0,0 +1,163 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	opusSampleRate = 48000 // Opus 的 granule position 始终以 48kHz 计
+	maxOggPackets  = 1 << 20
+)
+
+var errInvalidOgg = errors.New("无效的 Ogg 文件")
+
+// opusPacket 音频数据包的时长（48kHz 采样数）和大小
+type opusPacket struct {
+	samples int
+	size    int
+}
+
+// analyzeOgg 读取 Ogg 页中第一个逻辑流的数据包：OpusHead 提供预跳过的采样数，
+// 最后一页的 granule position 减去预跳过为总时长，各数据包的码率作为波形
+func analyzeOgg(r io.Reader) (*Info, error) {
+	var (
+		serial     uint32
+		first            = true
+		granule    int64 = -1
+		packet     []byte
+		headerSeen int
+		preSkip    int
+		packets    []opusPacket
+	)
+
+	for {
+		var header [27]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF && !first {
+				break
+			}
+			return nil, errInvalidOgg
+		}
+		if !bytes.Equal(header[:4], []byte("OggS")) {
+			return nil, errInvalidOgg
+		}
+		pageGranule := int64(binary.LittleEndian.Uint64(header[6:]))
+		pageSerial := binary.LittleEndian.Uint32(header[14:])
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return nil, errInvalidOgg
+		}
+		dataSize := 0
+		for _, s := range segments {
+			dataSize += int(s)
+		}
+		data := make([]byte, dataSize)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, errInvalidOgg
+		}
+
+		if first {
+			serial, first = pageSerial, false
+		}
+		if pageSerial != serial {
+			// 只处理第一个逻辑流
+			continue
+		}
+		if pageGranule >= 0 {
+			granule = pageGranule
+		}
+
+		// 长度为 255 的段表示数据包在下一段（可能在下一页）继续
+		off := 0
+		for _, s := range segments {
+			packet = append(packet, data[off:off+int(s)]...)
+			off += int(s)
+			if s == 255 {
+				continue
+			}
+			switch headerSeen {
+			case 0:
+				if len(packet) < 19 || !bytes.HasPrefix(packet, []byte("OpusHead")) {
+					return nil, ErrUnsupported
+				}
+				preSkip = int(binary.LittleEndian.Uint16(packet[10:]))
+				headerSeen++
+			case 1:
+				// OpusTags
+				headerSeen++
+			default:
+				if len(packets) >= maxOggPackets {
+					return nil, errInvalidOgg
+				}
+				packets = append(packets, opusPacket{samples: opusPacketSamples(packet), size: len(packet)})
+			}
+			packet = packet[:0]
+		}
+	}
+	if headerSeen < 2 {
+		return nil, errInvalidOgg
+	}
+
+	// 没有有效的 granule position 时按数据包时长累加
+	var total int64
+	for _, p := range packets {
+		total += int64(p.samples)
+	}
+	samples := total - int64(preSkip)
+	if granule > 0 {
+		samples = granule - int64(preSkip)
+	}
+	if samples <= 0 || total == 0 {
+		return nil, errInvalidOgg
+	}
+
+	b := newBuckets(len(packets))
+	var pos int64
+	for _, p := range packets {
+		if p.samples > 0 {
+			// 单位时间的字节数，即码率
+			b.add(pos, total, float64(p.size)/float64(p.samples))
+		}
+		pos += int64(p.samples)
+	}
+
+	return &Info{
+		DurationMs: int(samples * 1000 / opusSampleRate),
+		Waveform:   b.waveform(false),
+	}, nil
+}
+
+// opusPacketSamples 根据数据包的 TOC 字节计算时长（48kHz 采样数），见 RFC 6716 第 3.1 节
+func opusPacketSamples(packet []byte) int {
+	if len(packet) == 0 {
+		return 0
+	}
+	toc := packet[0]
+	config := int(toc >> 3)
+
+	// 每帧时长，单位为 0.5 毫秒（2.5ms 为 5）
+	var halfMs int
+	switch {
+	case config < 12: // SILK: 10, 20, 40, 60ms
+		halfMs = []int{20, 40, 80, 120}[config%4]
+	case config < 16: // Hybrid: 10, 20ms
+		halfMs = []int{20, 40}[config%2]
+	default: // CELT: 2.5, 5, 10, 20ms
+		halfMs = []int{5, 10, 20, 40}[config%4]
+	}
+
+	frames := 1
+	switch toc & 3 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0
+		}
+		frames = int(packet[1] & 0x3F)
+	}
+	return frames * halfMs * opusSampleRate / 2000
+}
@@ -0,0 +1,176 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// opus20ms 一个 20ms 的 CELT 单帧数据包（TOC config 19，code 0），size 为包含 TOC 的总长度，
+// 包体也填充 TOC 字节，数据包被错误拆分时时长会变化
+func opus20ms(size int) []byte {
+	return bytes.Repeat([]byte{19 << 3}, size)
+}
+
+// opusHead 生成 OpusHead 头部数据包
+func opusHead(preSkip uint16) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("OpusHead")
+	buf.WriteByte(1) // version
+	buf.WriteByte(1) // channels
+	binary.Write(&buf, binary.LittleEndian, preSkip)
+	binary.Write(&buf, binary.LittleEndian, uint32(48000))
+	binary.Write(&buf, binary.LittleEndian, int16(0))
+	buf.WriteByte(0) // mapping family
+	return buf.Bytes()
+}
+
+// lace 生成数据包的段表，open 为 true 时最后一个数据包在下一页继续（末尾不写结束段）
+func lace(open bool, packets ...[]byte) (segments, data []byte) {
+	for i, p := range packets {
+		n := len(p)
+		for n >= 255 {
+			segments = append(segments, 255)
+			n -= 255
+		}
+		if !open || i < len(packets)-1 {
+			segments = append(segments, byte(n))
+		} else if n > 0 {
+			panic("跨页的数据包在本页的长度必须是 255 的倍数")
+		}
+		data = append(data, p...)
+	}
+	return segments, data
+}
+
+// makeOggPage 生成一个 Ogg 页，granule 为 -1 表示该页没有数据包结束。解析时不校验 CRC
+func makeOggPage(serial uint32, granule int64, segments, data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("OggS")
+	buf.WriteByte(0) // version
+	buf.WriteByte(0) // header type
+	binary.Write(&buf, binary.LittleEndian, granule)
+	binary.Write(&buf, binary.LittleEndian, serial)
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // sequence
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // CRC
+	buf.WriteByte(byte(len(segments)))
+	buf.Write(segments)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// makeOgg 生成包含 OpusHead、OpusTags 和给定音频页的 Ogg/Opus 文件
+func makeOgg(preSkip uint16, pages ...[]byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(makeOggPage(1, 0, []byte{19}, opusHead(preSkip)))
+	tags := []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00")
+	buf.Write(makeOggPage(1, 0, []byte{byte(len(tags))}, tags))
+	for _, p := range pages {
+		buf.Write(p)
+	}
+	return buf.Bytes()
+}
+
+func TestAnalyzeOggDuration(t *testing.T) {
+	// 50 个 20ms 的数据包分布在两页中，后面的数据包更大
+	var first, second [][]byte
+	for i := 0; i < 25; i++ {
+		first = append(first, opus20ms(10+i))
+		second = append(second, opus20ms(100+i))
+	}
+	s1, d1 := lace(false, first...)
+	s2, d2 := lace(false, second...)
+	data := makeOgg(312,
+		makeOggPage(1, 24000, s1, d1),
+		makeOggPage(1, 48000, s2, d2),
+	)
+
+	info, err := Analyze(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	// 最后一页的 granule position 减去预跳过的采样数：(48000 - 312) / 48kHz
+	if info.DurationMs != 993 {
+		t.Errorf("DurationMs = %d, want 993", info.DurationMs)
+	}
+	if len(info.Waveform) != 50 {
+		t.Fatalf("len(Waveform) = %d, want 50", len(info.Waveform))
+	}
+	if info.Waveform[0] >= info.Waveform[49] {
+		t.Errorf("Waveform = %v, want increasing", info.Waveform)
+	}
+}
+
+func TestAnalyzeOggWithoutGranule(t *testing.T) {
+	s, d := lace(false, opus20ms(20), opus20ms(20), opus20ms(20))
+	info, err := Analyze(bytes.NewReader(makeOgg(480, makeOggPage(1, -1, s, d))))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	// 没有 granule position 时按数据包时长累加：60ms - 10ms 预跳过
+	if info.DurationMs != 50 {
+		t.Errorf("DurationMs = %d, want 50", info.DurationMs)
+	}
+}
+
+func TestAnalyzeOggPacketAcrossPages(t *testing.T) {
+	// 第二个数据包 300 字节，前 255 字节在第一页，剩余 45 字节在第二页
+	long := opus20ms(300)
+	s1, d1 := lace(true, opus20ms(20), long[:255])
+	s2, d2 := lace(false, long[255:], opus20ms(20))
+	// 其他逻辑流的页被忽略
+	other := makeOggPage(2, -1, []byte{20}, opus20ms(20))
+
+	info, err := Analyze(bytes.NewReader(makeOgg(0,
+		makeOggPage(1, -1, s1, d1),
+		other,
+		makeOggPage(1, -1, s2, d2),
+	)))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if info.DurationMs != 60 {
+		t.Errorf("DurationMs = %d, want 60 (3 个数据包)", info.DurationMs)
+	}
+	if len(info.Waveform) != 3 {
+		t.Errorf("len(Waveform) = %d, want 3", len(info.Waveform))
+	}
+}
+
+func TestAnalyzeOggTruncated(t *testing.T) {
+	s, d := lace(false, opus20ms(50), opus20ms(50))
+	data := makeOgg(0, makeOggPage(1, 1920, s, d))
+
+	for _, n := range []int{
+		len(data) - 10,               // 页数据不完整
+		len(data) - 100 - len(s) + 1, // 段表不完整
+		20,                           // 第一个页头不完整
+	} {
+		if _, err := Analyze(bytes.NewReader(data[:n])); !errors.Is(err, errInvalidOgg) {
+			t.Errorf("截断到 %d 字节: err = %v, want errInvalidOgg", n, err)
+		}
+	}
+
+	// 只有 OpusHead 没有 OpusTags
+	head := makeOggPage(1, 0, []byte{19}, opusHead(0))
+	if _, err := Analyze(bytes.NewReader(head)); !errors.Is(err, errInvalidOgg) {
+		t.Errorf("缺少 OpusTags: err = %v, want errInvalidOgg", err)
+	}
+}
+
+func TestAnalyzeOggMissingOpusHead(t *testing.T) {
+	// Ogg/Vorbis 等其他编码的第一个数据包不是 OpusHead
+	vorbis := append([]byte("\x01vorbis"), make([]byte, 22)...)
+	data := makeOggPage(1, 0, []byte{byte(len(vorbis))}, vorbis)
+	if _, err := Analyze(bytes.NewReader(data)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Vorbis: err = %v, want ErrUnsupported", err)
+	}
+
+	// OpusHead 过短
+	short := []byte("OpusHead\x01")
+	data = makeOggPage(1, 0, []byte{byte(len(short))}, short)
+	if _, err := Analyze(bytes.NewReader(data)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("过短的 OpusHead: err = %v, want ErrUnsupported", err)
+	}
+}
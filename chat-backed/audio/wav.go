@@ -0,0 +1,144 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+
+	wavReadBuffer = 64 << 10 // 每次读取的数据量，按整帧向下取整
+)
+
+var errInvalidWAV = errors.New("无效的 WAV 文件")
+
+// wavFormat fmt 块中的格式信息
+type wavFormat struct {
+	format        uint16
+	channels      uint16
+	sampleRate    uint32
+	blockAlign    uint16
+	bitsPerSample uint16
+}
+
+// analyzeWAV 依次读取 RIFF 块，根据 data 块的大小计算时长，并按段计算采样的均方根作为音量
+func analyzeWAV(r io.Reader) (*Info, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, errInvalidWAV
+	}
+
+	var f *wavFormat
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, errInvalidWAV
+		}
+		id, size := string(header[:4]), int64(binary.LittleEndian.Uint32(header[4:]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 || size > 1<<10 {
+				return nil, errInvalidWAV
+			}
+			buf := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, errInvalidWAV
+			}
+			f = &wavFormat{
+				format:        binary.LittleEndian.Uint16(buf[0:]),
+				channels:      binary.LittleEndian.Uint16(buf[2:]),
+				sampleRate:    binary.LittleEndian.Uint32(buf[4:]),
+				blockAlign:    binary.LittleEndian.Uint16(buf[12:]),
+				bitsPerSample: binary.LittleEndian.Uint16(buf[14:]),
+			}
+			if f.format == wavFormatExtensible && size >= 26 {
+				// WAVE_FORMAT_EXTENSIBLE 的实际格式在子格式 GUID 的前两个字节
+				f.format = binary.LittleEndian.Uint16(buf[24:])
+			}
+		case "data":
+			if f == nil {
+				return nil, errInvalidWAV
+			}
+			return readWAVData(r, f, size)
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, errInvalidWAV
+			}
+		}
+	}
+}
+
+func readWAVData(r io.Reader, f *wavFormat, size int64) (*Info, error) {
+	bytesPerSample := int(f.bitsPerSample+7) / 8
+	// blockAlign 来自上传的文件，必须与声道数和采样位数一致，避免按伪造的值分配缓冲区
+	if f.channels == 0 || f.sampleRate == 0 || bytesPerSample == 0 || int(f.blockAlign) != int(f.channels)*bytesPerSample {
+		return nil, errInvalidWAV
+	}
+	sample, ok := sampleDecoder(f.format, f.bitsPerSample)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	frames := size / int64(f.blockAlign)
+	b := newBuckets(int(min(frames, WaveformBars)))
+	buf := make([]byte, max(wavReadBuffer/int(f.blockAlign), 1)*int(f.blockAlign))
+	var frame int64
+	for frame < frames {
+		n, err := io.ReadFull(r, buf[:min(int64(len(buf)), (frames-frame)*int64(f.blockAlign))])
+		for off := 0; off+int(f.blockAlign) <= n; off += int(f.blockAlign) {
+			// 多声道取平均能量
+			var energy float64
+			for ch := 0; ch < int(f.channels); ch++ {
+				s := sample(buf[off+ch*bytesPerSample:])
+				energy += s * s
+			}
+			b.add(frame, frames, energy/float64(f.channels))
+			frame++
+		}
+		if err != nil {
+			// 文件被截断时按实际读到的采样计算
+			frames = frame
+			break
+		}
+	}
+	if frames == 0 {
+		return nil, errInvalidWAV
+	}
+
+	return &Info{
+		DurationMs: int(frames * 1000 / int64(f.sampleRate)),
+		Waveform:   b.waveform(true),
+	}, nil
+}
+
+// sampleDecoder 返回将一个采样转换为 [-1, 1] 范围的函数
+func sampleDecoder(format, bits uint16) (func([]byte) float64, bool) {
+	switch {
+	case format == wavFormatPCM && bits == 8:
+		return func(b []byte) float64 { return (float64(b[0]) - 128) / 128 }, true
+	case format == wavFormatPCM && bits == 16:
+		return func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }, true
+	case format == wavFormatPCM && bits == 24:
+		return func(b []byte) float64 {
+			v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+			return float64(v) / (1 << 23)
+		}, true
+	case format == wavFormatPCM && bits == 32:
+		return func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }, true
+	case format == wavFormatFloat && bits == 32:
+		return func(b []byte) float64 {
+			v := float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			if math.IsNaN(v) {
+				return 0
+			}
+			return math.Max(-1, math.Min(1, v))
+		}, true
+	}
+	return nil, false
+}
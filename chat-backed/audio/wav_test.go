@@ -0,0 +1,63 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// makeWAV 生成 16 位 PCM 的 WAV 文件，blockAlign 为 0 时按声道数计算
+func makeWAV(sampleRate, channels, frames int, blockAlign uint16) []byte {
+	if blockAlign == 0 {
+		blockAlign = uint16(channels * 2)
+	}
+	data := make([]byte, frames*channels*2)
+	for i := 0; i < frames; i++ {
+		v := int16(math.Sin(float64(i)/10) * 20000 * float64(i) / float64(frames))
+		for ch := 0; ch < channels; ch++ {
+			binary.LittleEndian.PutUint16(data[(i*channels+ch)*2:], uint16(v))
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(data)))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(wavFormatPCM))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*int(blockAlign)))
+	binary.Write(&buf, binary.LittleEndian, blockAlign)
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestAnalyzeWAV(t *testing.T) {
+	info, err := Analyze(bytes.NewReader(makeWAV(8000, 2, 12000, 0)))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if info.DurationMs != 1500 {
+		t.Errorf("DurationMs = %d, want 1500", info.DurationMs)
+	}
+	if len(info.Waveform) != WaveformBars {
+		t.Fatalf("len(Waveform) = %d, want %d", len(info.Waveform), WaveformBars)
+	}
+	// 测试音频的音量逐渐增大
+	if info.Waveform[0] >= info.Waveform[WaveformBars-1] {
+		t.Errorf("Waveform = %v, want increasing", info.Waveform)
+	}
+}
+
+func TestAnalyzeWAVRejectsForgedBlockAlign(t *testing.T) {
+	_, err := Analyze(bytes.NewReader(makeWAV(8000, 1, 100, 65535)))
+	if !errors.Is(err, errInvalidWAV) {
+		t.Errorf("err = %v, want errInvalidWAV", err)
+	}
+}
@@ -0,0 +1,121 @@
+// 聊天记录导入工具：从其他聊天系统的导出包导入用户、群组和消息。
+//
+//	go run ./cmd/import -format slack -source slack-acme -file acme-export.zip -dry-run
+//	go run ./cmd/import -format native -source legacy -file ./legacy-export/
+//
+// 导出包可以是 zip 文件或解压后的目录。同一来源（-source）重复导入时会跳过已导入的数据。
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-chat/importer"
+	"go-chat/models"
+	"go-chat/scanner"
+	"go-chat/storage"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	file := flag.String("file", "", "导出包路径（zip 文件或目录）")
+	format := flag.String("format", "native", "导出包格式: native, slack")
+	source := flag.String("source", "", "数据来源标识，同一来源的重复导入会被去重（默认与 -format 相同）")
+	dryRun := flag.Bool("dry-run", false, "只统计将要导入的数据，不写入数据库")
+	matchUsers := flag.Bool("match-existing-users", false, "将与本地已有用户同名的外部用户映射到该用户（默认跳过同名用户）")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *source == "" {
+		*source = *format
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("警告: 无法加载 .env 文件:", err)
+	}
+
+	fsys, closeFn, err := openArchive(*file)
+	if err != nil {
+		log.Fatalf("打开导出包失败: %v", err)
+	}
+	defer closeFn()
+
+	data, err := importer.Load(fsys, *format)
+	if err != nil {
+		log.Fatalf("解析导出包失败: %v", err)
+	}
+	log.Printf("解析完成: %d 个用户, %d 个群组, %d 条消息", len(data.Users), len(data.Groups), len(data.Messages))
+
+	models.InitDB()
+
+	// 附件写入与服务端相同的存储后端（STORAGE_BACKEND 等环境变量）
+	fileStorage, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("初始化文件存储失败: %v", err)
+	}
+
+	// 启用了扫描器（SCANNER 等环境变量）时，导入的附件先隔离，由服务端的扫描任务扫描通过后才能下载
+	fileScanner, err := scanner.FromEnv()
+	if err != nil {
+		log.Fatalf("初始化文件扫描器失败: %v", err)
+	}
+	var scanStatus func(string) string
+	if fileScanner != nil {
+		scanStatus = func(hash string) string { return models.BlobScanStatus(models.DB, hash) }
+	}
+
+	report, err := importer.Run(models.DB, fsys, data, importer.Options{
+		Source:             *source,
+		DryRun:             *dryRun,
+		MatchExistingUsers: *matchUsers,
+		Storage:            fileStorage,
+		ScanStatus:         scanStatus,
+		Progress: func(p importer.Progress) {
+			fmt.Fprintf(os.Stderr, "\r[%s] %d/%d", p.Stage, p.Done, p.Total)
+			if p.Done == p.Total {
+				fmt.Fprintln(os.Stderr)
+			}
+		},
+		Logf: func(format string, args ...interface{}) {
+			fmt.Fprintln(os.Stderr)
+			log.Printf("警告: "+format, args...)
+		},
+	})
+	if report != nil {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+	}
+	if err != nil {
+		log.Fatalf("导入失败: %v", err)
+	}
+	if *dryRun {
+		log.Println("预演完成，未写入任何数据")
+	} else {
+		log.Println("✅ 导入完成")
+	}
+}
+
+// openArchive 打开 zip 文件或目录形式的导出包
+func openArchive(name string) (fs.FS, func(), error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return os.DirFS(name), func() {}, nil
+	}
+
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return zr, func() { zr.Close() }, nil
+}
@@ -0,0 +1,46 @@
+package export
+
+import (
+	"encoding/csv"
+	"go-chat/models"
+	"io"
+	"strconv"
+)
+
+// csvWriter 每条消息一行，首行为表头
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Begin(meta *Meta) error {
+	return c.w.Write([]string{
+		"id", "created_at", "user_id", "username", "group_id", "channel_id", "target_id",
+		"message_type", "content", "file_name", "file_size", "attachment",
+	})
+}
+
+func (c *csvWriter) WriteMessage(m *models.Message, attachment string) error {
+	return c.w.Write([]string{
+		strconv.FormatUint(uint64(m.ID), 10),
+		m.CreatedAt.Format(timeLayout),
+		strconv.FormatUint(uint64(m.UserID), 10),
+		m.Username,
+		strconv.FormatUint(uint64(m.GroupID), 10),
+		strconv.FormatUint(uint64(m.ChannelID), 10),
+		strconv.FormatUint(uint64(m.TargetID), 10),
+		m.MessageType,
+		m.Content,
+		m.FileName,
+		strconv.FormatInt(m.FileSize, 10),
+		attachment,
+	})
+}
+
+func (c *csvWriter) End() error {
+	c.w.Flush()
+	return c.w.Error()
+}
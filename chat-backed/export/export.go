@@ -0,0 +1,70 @@
+package export
+
+import (
+	"fmt"
+	"go-chat/models"
+	"io"
+	"sort"
+	"time"
+)
+
+// Meta 导出文件的描述信息
+type Meta struct {
+	Title      string    `json:"title"`       // 会话名称，如群名或私聊对象
+	Scope      string    `json:"scope"`       // 导出范围: group, direct, user
+	ExportedBy string    `json:"exported_by"` // 导出者用户名
+	ExportedAt time.Time `json:"exported_at"` // 导出时间
+}
+
+// Writer 聊天记录导出格式。调用顺序为 Begin、若干次 WriteMessage、End；
+// attachment 为附件在导出压缩包中的相对路径，无附件时为空
+type Writer interface {
+	Begin(meta *Meta) error
+	WriteMessage(m *models.Message, attachment string) error
+	End() error
+}
+
+// format 导出格式的注册信息
+type format struct {
+	fileName string // 压缩包内的聊天记录文件名
+	newFunc  func(w io.Writer) Writer
+}
+
+var formats = map[string]format{
+	"json": {fileName: "messages.json", newFunc: newJSONWriter},
+	"csv":  {fileName: "messages.csv", newFunc: newCSVWriter},
+	"html": {fileName: "transcript.html", newFunc: newHTMLWriter},
+}
+
+// New 创建指定格式的导出 Writer
+func New(name string, w io.Writer) (Writer, error) {
+	f, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("不支持的导出格式: %s", name)
+	}
+	return f.newFunc(w), nil
+}
+
+// FileName 返回指定格式的聊天记录在导出压缩包中的文件名
+func FileName(name string) string {
+	return formats[name].fileName
+}
+
+// IsValidFormat 校验导出格式
+func IsValidFormat(name string) bool {
+	_, ok := formats[name]
+	return ok
+}
+
+// Formats 返回所有支持的导出格式
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// timeLayout 导出文件中的时间格式
+const timeLayout = "2006-01-02 15:04:05"
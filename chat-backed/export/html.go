@@ -0,0 +1,76 @@
+package export
+
+import (
+	"go-chat/models"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// htmlTemplates 自包含的 HTML 聊天记录模板，样式内联，附件通过压缩包内的相对路径引用
+var htmlTemplates = template.Must(template.New("header").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - 聊天记录</title>
+<style>
+body { font-family: -apple-system, "PingFang SC", "Microsoft YaHei", sans-serif; margin: 0 auto; max-width: 860px; padding: 24px; color: #222; background: #fafafa; }
+header { border-bottom: 1px solid #ddd; margin-bottom: 16px; }
+header p { color: #666; font-size: 13px; }
+.msg { padding: 8px 12px; margin: 6px 0; background: #fff; border-radius: 6px; box-shadow: 0 1px 2px rgba(0,0,0,.06); }
+.msg.system { background: #f0f0f0; color: #666; font-size: 13px; }
+.meta { font-size: 12px; color: #888; }
+.meta .user { font-weight: 600; color: #333; margin-right: 8px; }
+.content { white-space: pre-wrap; word-break: break-word; margin-top: 4px; }
+.content img { max-width: 100%; max-height: 360px; display: block; margin-top: 6px; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p>导出者：{{.ExportedBy}} · 导出时间：{{.ExportedAt.Format "2006-01-02 15:04:05"}}</p>
+</header>
+<main>
+`))
+
+func init() {
+	template.Must(htmlTemplates.New("message").Parse(`<div class="msg {{.Message.MessageType}}" id="m{{.Message.ID}}">
+<div class="meta"><span class="user">{{.Message.Username}}</span>{{.Message.CreatedAt.Format "2006-01-02 15:04:05"}}</div>
+<div class="content">{{.Message.Content}}{{if .Attachment}}{{if .IsImage}}<img src="{{.Attachment}}" alt="{{.Message.FileName}}">{{else}}
+<a href="{{.Attachment}}">{{if .Message.FileName}}{{.Message.FileName}}{{else}}附件{{end}}</a>{{end}}{{end}}</div>
+</div>
+`))
+	template.Must(htmlTemplates.New("footer").Parse(`</main>
+</body>
+</html>
+`))
+}
+
+// htmlWriter 生成单文件的 HTML 聊天记录
+type htmlWriter struct {
+	w io.Writer
+}
+
+func newHTMLWriter(w io.Writer) Writer {
+	return &htmlWriter{w: w}
+}
+
+func (h *htmlWriter) Begin(meta *Meta) error {
+	return htmlTemplates.ExecuteTemplate(h.w, "header", meta)
+}
+
+func (h *htmlWriter) WriteMessage(m *models.Message, attachment string) error {
+	return htmlTemplates.ExecuteTemplate(h.w, "message", struct {
+		Message    *models.Message
+		Attachment string
+		IsImage    bool
+	}{
+		Message:    m,
+		Attachment: attachment,
+		IsImage:    m.MessageType == "image" || strings.HasPrefix(m.MessageType, "image/"),
+	})
+}
+
+func (h *htmlWriter) End() error {
+	return htmlTemplates.ExecuteTemplate(h.w, "footer", nil)
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"encoding/json"
+	"go-chat/models"
+	"io"
+)
+
+// jsonMessage JSON 导出中的单条消息
+type jsonMessage struct {
+	ID          uint   `json:"id"`
+	CreatedAt   string `json:"created_at"`
+	UserID      uint   `json:"user_id"`
+	Username    string `json:"username"`
+	GroupID     uint   `json:"group_id,omitempty"`
+	ChannelID   uint   `json:"channel_id,omitempty"`
+	TargetID    uint   `json:"target_id,omitempty"`
+	MessageType string `json:"message_type"`
+	Content     string `json:"content"`
+	FileName    string `json:"file_name,omitempty"`
+	FileSize    int64  `json:"file_size,omitempty"`
+	Attachment  string `json:"attachment,omitempty"`
+}
+
+// jsonWriter 以 {"meta": ..., "messages": [...]} 的形式流式写出，不需要一次性加载所有消息
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONWriter(w io.Writer) Writer {
+	return &jsonWriter{w: w}
+}
+
+func (j *jsonWriter) Begin(meta *Meta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(j.w, `{"meta":`); err != nil {
+		return err
+	}
+	if _, err := j.w.Write(data); err != nil {
+		return err
+	}
+	_, err = io.WriteString(j.w, `,"messages":[`)
+	return err
+}
+
+func (j *jsonWriter) WriteMessage(m *models.Message, attachment string) error {
+	data, err := json.Marshal(jsonMessage{
+		ID:          m.ID,
+		CreatedAt:   m.CreatedAt.Format(timeLayout),
+		UserID:      m.UserID,
+		Username:    m.Username,
+		GroupID:     m.GroupID,
+		ChannelID:   m.ChannelID,
+		TargetID:    m.TargetID,
+		MessageType: m.MessageType,
+		Content:     m.Content,
+		FileName:    m.FileName,
+		FileSize:    m.FileSize,
+		Attachment:  attachment,
+	})
+	if err != nil {
+		return err
+	}
+	if j.count > 0 {
+		if _, err := io.WriteString(j.w, ","); err != nil {
+			return err
+		}
+	}
+	j.count++
+	if _, err := io.WriteString(j.w, "\n"); err != nil {
+		return err
+	}
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonWriter) End() error {
+	_, err := io.WriteString(j.w, "\n]}\n")
+	return err
+}
@@ -0,0 +1,105 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+// blurhash 使用的 base83 字符表
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Blurhash 按 blurhash 算法（https://blurha.sh）将图片编码为约 30 个字符的占位图，
+// 横图使用 4x3 个分量，竖图使用 3x4 个分量。传入的图片应先缩小到 32 像素左右以减少计算量
+func Blurhash(img *image.RGBA) string {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w == 0 || h == 0 {
+		return ""
+	}
+	xComp, yComp := 4, 3
+	if h > w {
+		xComp, yComp = 3, 4
+	}
+
+	factors := make([][3]float64, 0, xComp*yComp)
+	for j := 0; j < yComp; j++ {
+		for i := 0; i < xComp; i++ {
+			factors = append(factors, blurhashFactor(img, w, h, i, j))
+		}
+	}
+
+	var sb strings.Builder
+	encodeBase83(&sb, (xComp-1)+(yComp-1)*9, 1)
+
+	maxValue := 1.0
+	ac := factors[1:]
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantisedMax+1) / 166
+		encodeBase83(&sb, quantisedMax, 1)
+	} else {
+		encodeBase83(&sb, 0, 1)
+	}
+
+	dc := factors[0]
+	encodeBase83(&sb, linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+	for _, f := range ac {
+		encodeBase83(&sb, quantiseAC(f[0], maxValue)*19*19+quantiseAC(f[1], maxValue)*19+quantiseAC(f[2], maxValue), 2)
+	}
+	return sb.String()
+}
+
+// blurhashFactor 计算 (i, j) 分量的余弦变换系数
+func blurhashFactor(img *image.RGBA, w, h, i, j int) [3]float64 {
+	var r, g, b float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) * math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+			p := img.Pix[y*img.Stride+x*4:]
+			r += basis * sRGBToLinear(p[0])
+			g += basis * sRGBToLinear(p[1])
+			b += basis * sRGBToLinear(p[2])
+		}
+	}
+	normalisation := 2.0
+	if i == 0 && j == 0 {
+		normalisation = 1
+	}
+	scale := normalisation / float64(w*h)
+	return [3]float64{r * scale, g * scale, b * scale}
+}
+
+func quantiseAC(v, maxValue float64) int {
+	return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+func sRGBToLinear(v uint8) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func encodeBase83(sb *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := value / int(math.Pow(83, float64(length-i))) % 83
+		sb.WriteByte(base83Chars[digit])
+	}
+}
@@ -0,0 +1,181 @@
+// Package imaging 处理上传的图片：去除 EXIF 等元数据、读取尺寸、生成缩略图和 blurhash 占位图。
+// 只使用标准库，可解码 JPEG、PNG 和 GIF；WebP 只去除元数据并读取尺寸，不生成缩略图。
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+)
+
+// Size 缩略图规格，MaxSide 为长边的最大像素
+type Size struct {
+	Name    string
+	MaxSide int
+}
+
+// Sizes 生成的缩略图规格，原图长边不超过规格时跳过
+var Sizes = []Size{
+	{Name: "small", MaxSide: 160},
+	{Name: "medium", MaxSide: 480},
+	{Name: "large", MaxSide: 1024},
+}
+
+// Thumbnail 生成的缩略图
+type Thumbnail struct {
+	Size        string // 规格名称
+	Width       int
+	Height      int
+	ContentType string // image/jpeg，带透明通道的图片为 image/png
+	Ext         string // 文件扩展名
+	Data        []byte
+}
+
+// Result 图片处理结果
+type Result struct {
+	Data       []byte // 去除元数据后的图片内容，应替换原文件
+	Width      int    // 原图宽度（已按 EXIF 方向旋转）
+	Height     int    // 原图高度
+	Blurhash   string // 加载前显示的占位图，无法解码时为空
+	Thumbnails []Thumbnail
+}
+
+// 缩略图和重新编码原图的 JPEG 质量
+const (
+	thumbnailQuality = 80
+	reencodeQuality  = 90
+)
+
+// maxPixels 超过该像素数的图片不解码（避免解压炸弹占用大量内存），只去除元数据并记录尺寸
+const maxPixels = 40_000_000
+
+// Process 处理图片。JPEG 和 PNG 在字节层面去除元数据，不重新编码；带 EXIF 方向信息的 JPEG
+// 去除元数据后方向会丢失，因此按方向旋转后重新编码
+func Process(data []byte, contentType string) (*Result, error) {
+	switch contentType {
+	case "image/jpeg":
+		return processJPEG(data)
+	case "image/png":
+		cleaned, err := stripPNGMetadata(data)
+		if err != nil {
+			return nil, err
+		}
+		return processDecoded(cleaned, nil)
+	case "image/gif":
+		return processDecoded(data, nil)
+	case "image/webp":
+		cleaned, err := stripWebPMetadata(data)
+		if err != nil {
+			return nil, err
+		}
+		result := &Result{Data: cleaned}
+		result.Width, result.Height, _ = webpSize(cleaned)
+		return result, nil
+	}
+	return nil, fmt.Errorf("不支持的图片类型: %s", contentType)
+}
+
+func processJPEG(data []byte) (*Result, error) {
+	orientation := jpegOrientation(data)
+	cleaned, err := stripJPEGMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+	if orientation <= 1 || orientation > 8 {
+		return processDecoded(cleaned, nil)
+	}
+	if result, ok := oversized(cleaned); ok {
+		return result, nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(cleaned))
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %w", err)
+	}
+	img = applyOrientation(img, orientation)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: reencodeQuality}); err != nil {
+		return nil, err
+	}
+	return processDecoded(buf.Bytes(), img)
+}
+
+// processDecoded 读取尺寸并生成缩略图和占位图，img 为空时从 data 解码
+func processDecoded(data []byte, img image.Image) (*Result, error) {
+	if img == nil {
+		if result, ok := oversized(data); ok {
+			return result, nil
+		}
+		decoded, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("解码图片失败: %w", err)
+		}
+		img = decoded
+	}
+
+	bounds := img.Bounds()
+	result := &Result{Data: data, Width: bounds.Dx(), Height: bounds.Dy()}
+	if result.Width == 0 || result.Height == 0 {
+		return nil, fmt.Errorf("图片尺寸无效")
+	}
+
+	src := toRGBA(img)
+	for _, size := range Sizes {
+		if result.Width <= size.MaxSide && result.Height <= size.MaxSide {
+			continue
+		}
+		w, h := fit(result.Width, result.Height, size.MaxSide)
+		thumb, err := encodeThumbnail(resize(src, w, h))
+		if err != nil {
+			return nil, err
+		}
+		thumb.Size = size.Name
+		result.Thumbnails = append(result.Thumbnails, *thumb)
+	}
+
+	w, h := fit(result.Width, result.Height, 32)
+	result.Blurhash = Blurhash(resize(src, w, h))
+	return result, nil
+}
+
+// oversized 检查图片是否过大，过大时返回只包含尺寸的结果
+func oversized(data []byte) (*Result, bool) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width*cfg.Height <= maxPixels {
+		return nil, false
+	}
+	return &Result{Data: data, Width: cfg.Width, Height: cfg.Height}, true
+}
+
+// encodeThumbnail 不透明的图片编码为 JPEG，带透明通道的编码为 PNG
+func encodeThumbnail(img *image.RGBA) (*Thumbnail, error) {
+	var buf bytes.Buffer
+	thumb := &Thumbnail{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if img.Opaque() {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+			return nil, err
+		}
+		thumb.ContentType, thumb.Ext = "image/jpeg", ".jpg"
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		thumb.ContentType, thumb.Ext = "image/png", ".png"
+	}
+	thumb.Data = buf.Bytes()
+	return thumb, nil
+}
+
+// fit 按比例缩放到长边不超过 maxSide
+func fit(w, h, maxSide int) (int, int) {
+	if w <= maxSide && h <= maxSide {
+		return w, h
+	}
+	if w >= h {
+		return maxSide, max(1, h*maxSide/w)
+	}
+	return max(1, w*maxSide/h), maxSide
+}
@@ -0,0 +1,235 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformed = errors.New("图片文件结构无效")
+
+// JPEG 中去除的段：APP1（EXIF、XMP，可能包含 GPS 位置和设备信息）、APP13（IPTC）和注释。
+// 保留 APP0（JFIF）、APP2（ICC 颜色配置）和 APP14（Adobe 颜色变换），否则会影响显示
+var jpegStrippedMarkers = map[byte]bool{0xE1: true, 0xED: true, 0xFE: true}
+
+// stripJPEGMetadata 去除 JPEG 的元数据段，图像数据原样保留。EOI 之后的内容一并丢弃：
+// MPF 等多图 JPEG 在其后附带的副图有各自的 EXIF（包括 GPS 位置）
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, errMalformed
+		}
+		marker := data[pos+1:]
+		if len(marker) == 0 {
+			return nil, errMalformed
+		}
+		m := marker[0]
+		switch {
+		case m == 0xFF: // 填充字节
+			pos++
+			continue
+		case m == 0x01 || (m >= 0xD0 && m <= 0xD7): // 无长度的独立标记
+			out = append(out, 0xFF, m)
+			pos += 2
+			continue
+		case m == 0xD9: // EOI
+			return append(out, 0xFF, 0xD9), nil
+		}
+
+		if pos+4 > len(data) {
+			return nil, errMalformed
+		}
+		segEnd := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if segEnd > len(data) {
+			return nil, errMalformed
+		}
+		if m == 0xDA {
+			// SOS 之后是压缩的图像数据，原样保留到下一个标记（渐进式 JPEG 有多段扫描）
+			dataEnd := jpegScanEnd(data, segEnd)
+			out = append(out, data[pos:dataEnd]...)
+			if dataEnd == len(data) {
+				// 文件被截断，缺少 EOI
+				return out, nil
+			}
+			pos = dataEnd
+			continue
+		}
+		if !jpegStrippedMarkers[m] {
+			out = append(out, data[pos:segEnd]...)
+		}
+		pos = segEnd
+	}
+	return nil, errMalformed
+}
+
+// jpegScanEnd 返回从 pos 开始的压缩图像数据之后第一个标记的位置。
+// 数据中的 0xFF 后跟 0x00（转义）或 RST0-7 标记，都属于图像数据
+func jpegScanEnd(data []byte, pos int) int {
+	for ; pos+1 < len(data); pos++ {
+		if data[pos] != 0xFF {
+			continue
+		}
+		next := data[pos+1]
+		if next == 0x00 || (next >= 0xD0 && next <= 0xD7) {
+			pos++
+			continue
+		}
+		return pos
+	}
+	return len(data)
+}
+
+// jpegOrientation 读取 JPEG 中 EXIF 的方向标签（0x0112），没有时返回 1
+func jpegOrientation(data []byte) int {
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		m := data[pos+1]
+		if m == 0xDA || m == 0xD9 {
+			break
+		}
+		segEnd := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if segEnd > len(data) {
+			break
+		}
+		seg := data[pos+4 : segEnd]
+		if m == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return exifOrientation(seg[6:])
+		}
+		pos = segEnd
+	}
+	return 1
+}
+
+// exifOrientation 在 TIFF 结构的 IFD0 中查找方向标签
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
+
+// PNG 中去除的块：EXIF 和文本块（可能包含作者、软件、地点等信息）以及修改时间
+var pngStrippedChunks = map[string]bool{"eXIf": true, "tEXt": true, "iTXt": true, "zTXt": true, "tIME": true}
+
+// stripPNGMetadata 去除 PNG 的元数据块，整块删除不影响其他块的校验和
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, errMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, signature...)
+
+	pos := len(signature)
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, errMalformed
+		}
+		chunkEnd := pos + 12 + int(binary.BigEndian.Uint32(data[pos:]))
+		if chunkEnd > len(data) || chunkEnd < pos {
+			return nil, errMalformed
+		}
+		chunkType := string(data[pos+4 : pos+8])
+		if !pngStrippedChunks[chunkType] {
+			out = append(out, data[pos:chunkEnd]...)
+		}
+		pos = chunkEnd
+		if chunkType == "IEND" {
+			break
+		}
+	}
+	return out, nil
+}
+
+// stripWebPMetadata 去除 WebP 的 EXIF 和 XMP 块，并清除 VP8X 头中对应的标志位
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformed
+	}
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+
+	pos := 12
+	for pos+8 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		chunkEnd := pos + 8 + size + size%2
+		if chunkEnd > len(data) {
+			chunkEnd = len(data)
+		}
+		fourCC := string(data[pos : pos+4])
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, data[pos:chunkEnd]...)
+			if len(out) > start+8 {
+				out[start+8] &^= 0x08 | 0x04 // EXIF 和 XMP 标志
+			}
+		default:
+			out = append(out, data[pos:chunkEnd]...)
+		}
+		pos = chunkEnd
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// webpSize 从 VP8X、VP8 或 VP8L 块中读取 WebP 的尺寸
+func webpSize(data []byte) (int, int, bool) {
+	if len(data) < 30 {
+		return 0, 0, false
+	}
+	chunk := data[12:]
+	payload := chunk[8:]
+	switch string(chunk[:4]) {
+	case "VP8X":
+		w := int(payload[4]) | int(payload[5])<<8 | int(payload[6])<<16
+		h := int(payload[7]) | int(payload[8])<<8 | int(payload[9])<<16
+		return w + 1, h + 1, true
+	case "VP8 ":
+		if payload[3] != 0x9D || payload[4] != 0x01 || payload[5] != 0x2A {
+			return 0, 0, false
+		}
+		w := int(binary.LittleEndian.Uint16(payload[6:])) & 0x3FFF
+		h := int(binary.LittleEndian.Uint16(payload[8:])) & 0x3FFF
+		return w, h, true
+	case "VP8L":
+		if payload[0] != 0x2F {
+			return 0, 0, false
+		}
+		bits := binary.LittleEndian.Uint32(payload[1:])
+		return int(bits&0x3FFF) + 1, int(bits>>14&0x3FFF) + 1, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,66 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// jpegWithEXIF 生成一张 JPEG，并在 SOI 之后插入包含 tag 的 APP1 段
+func jpegWithEXIF(t *testing.T, tag string) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 8), uint8(y * 8), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	payload := append([]byte("Exif\x00\x00"), tag...)
+	app1 := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	app1 = append(app1, payload...)
+
+	encoded := buf.Bytes()
+	out := append([]byte{}, encoded[:2]...)
+	out = append(out, app1...)
+	return append(out, encoded[2:]...)
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	data := jpegWithEXIF(t, "GPS:primary")
+	stripped, err := stripJPEGMetadata(data)
+	if err != nil {
+		t.Fatalf("stripJPEGMetadata: %v", err)
+	}
+	if bytes.Contains(stripped, []byte("GPS:")) {
+		t.Error("EXIF 段未去除")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("去除元数据后无法解码: %v", err)
+	}
+}
+
+// MPF 多图 JPEG 在主图的 EOI 之后附带副图，副图的 EXIF 也要去除
+func TestStripJPEGMetadataDropsTrailingImages(t *testing.T) {
+	primary := jpegWithEXIF(t, "GPS:primary")
+	data := append(append([]byte{}, primary...), jpegWithEXIF(t, "GPS:secondary")...)
+
+	stripped, err := stripJPEGMetadata(data)
+	if err != nil {
+		t.Fatalf("stripJPEGMetadata: %v", err)
+	}
+	if bytes.Contains(stripped, []byte("GPS:")) {
+		t.Error("副图中的 EXIF 未去除")
+	}
+	if !bytes.HasSuffix(stripped, []byte{0xFF, 0xD9}) {
+		t.Error("输出未以 EOI 结尾")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("去除元数据后无法解码: %v", err)
+	}
+}
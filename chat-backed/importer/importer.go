@@ -0,0 +1,508 @@
+// Package importer 从其他聊天系统的导出文件导入用户、群组和消息。
+//
+// 支持两种格式：本项目定义的 JSON 格式（见 native.go）和 Slack 导出格式（见 slack.go）。
+// 两种格式都先解析为统一的 Dataset，再由 Run 写入数据库。导入过程是幂等的：
+// 每个导入的对象都会在 import_mappings 表中记录外部ID与本地ID的对应关系，重复导入时跳过已导入的对象。
+package importer
+
+import (
+	"fmt"
+	"go-chat/models"
+	"go-chat/storage"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User 待导入的用户
+type User struct {
+	ID       string `json:"id"`       // 外部系统中的用户ID
+	Username string `json:"username"` // 用户名，与已有用户同名时见 Options.MatchExistingUsers
+	Bio      string `json:"bio"`      // 个性签名
+}
+
+// Group 待导入的群组（对应外部系统的频道）
+type Group struct {
+	ID          string    `json:"id"`          // 外部系统中的频道ID
+	Name        string    `json:"name"`        // 群名
+	Description string    `json:"description"` // 群描述
+	Owner       string    `json:"owner"`       // 群主的外部用户ID，为空时使用第一个成员
+	Visibility  string    `json:"visibility"`  // 可见性: public, request, private，默认 private
+	Members     []string  `json:"members"`     // 成员的外部用户ID
+	CreatedAt   time.Time `json:"created_at"`  // 创建时间
+}
+
+// Attachment 消息附件
+type Attachment struct {
+	Path string `json:"path"` // 附件在导出包中的相对路径，为空表示导出包中没有该文件
+	Name string `json:"name"` // 原始文件名
+}
+
+// Message 待导入的消息，Group 与 Target 二选一
+type Message struct {
+	ID         string      `json:"id"`         // 外部系统中的消息ID
+	User       string      `json:"user"`       // 发送者的外部用户ID
+	Group      string      `json:"group"`      // 所属群组的外部ID
+	Target     string      `json:"target"`     // 私聊对象的外部用户ID
+	Content    string      `json:"content"`    // 消息内容
+	Type       string      `json:"type"`       // 消息类型: text, image, file，默认 text
+	CreatedAt  time.Time   `json:"created_at"` // 发送时间
+	Attachment *Attachment `json:"attachment"` // 附件
+}
+
+// Dataset 解析后的统一导入数据
+type Dataset struct {
+	Users    []User    `json:"users"`
+	Groups   []Group   `json:"groups"`
+	Messages []Message `json:"messages"`
+}
+
+// Options 导入选项
+type Options struct {
+	Source   string                       // 数据来源标识，同一来源的重复导入会被去重
+	DryRun   bool                         // 只统计将要导入的数据，不写入数据库和文件存储
+	Storage  storage.Storage              // 附件存储，默认为本地 ./uploads 目录
+	Progress func(p Progress)             // 进度回调，可为空
+	Logf     func(string, ...interface{}) // 警告日志，可为空
+
+	// MatchExistingUsers 为 true 时，与本地已有用户同名的外部用户映射到该用户，其消息以该用户的身份导入；
+	// 默认不映射，同名用户计入失败并跳过，避免把外部用户的消息归到无关的本地账号
+	MatchExistingUsers bool
+
+	// ScanStatus 返回新附件的初始安全扫描状态（见 models.BlobScanStatus），为空表示未启用扫描。
+	// 等待扫描的附件在扫描通过前不能下载，由服务端的扫描任务扫描
+	ScanStatus func(blobHash string) string
+}
+
+// Progress 导入进度
+type Progress struct {
+	Stage string // users, groups, messages
+	Done  int
+	Total int
+}
+
+// Counts 某类对象的导入统计
+type Counts struct {
+	Created int `json:"created"`           // 新建（预演时为将要新建）
+	Existed int `json:"existed"`           // 已导入，跳过
+	Matched int `json:"matched,omitempty"` // 按用户名映射到本地已有用户（仅用户）
+	Failed  int `json:"failed"`            // 数据不完整、用户名冲突等原因无法导入
+}
+
+// Report 导入结果
+type Report struct {
+	DryRun      bool   `json:"dry_run"`
+	Users       Counts `json:"users"`
+	Groups      Counts `json:"groups"`
+	Messages    Counts `json:"messages"`
+	Attachments int    `json:"attachments"` // 复制到上传目录的附件数
+}
+
+// 进度回调的间隔（条消息）
+const progressInterval = 500
+
+// importer 一次导入的上下文
+type importer struct {
+	db       *gorm.DB
+	fsys     fs.FS
+	opts     Options
+	report   *Report
+	mappings map[string]uint // kind/外部ID -> 本地ID
+}
+
+// Load 按格式解析导出包。fsys 可以是 zip 文件（zip.Reader）或解压后的目录（os.DirFS）
+func Load(fsys fs.FS, format string) (*Dataset, error) {
+	switch format {
+	case "native", "json":
+		return loadNative(fsys)
+	case "slack":
+		return loadSlack(fsys)
+	default:
+		return nil, fmt.Errorf("不支持的导入格式: %s", format)
+	}
+}
+
+// Run 将数据集导入数据库。附件从 fsys 中读取
+func Run(db *gorm.DB, fsys fs.FS, data *Dataset, opts Options) (*Report, error) {
+	if opts.Source == "" {
+		return nil, fmt.Errorf("必须指定数据来源标识")
+	}
+	if opts.Storage == nil {
+		opts.Storage = storage.NewLocal("./uploads")
+	}
+
+	im := &importer{
+		db:       db,
+		fsys:     fsys,
+		opts:     opts,
+		report:   &Report{DryRun: opts.DryRun},
+		mappings: make(map[string]uint),
+	}
+
+	var existing []models.ImportMapping
+	if err := db.Where("source = ?", opts.Source).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range existing {
+		im.mappings[mappingKey(m.Kind, m.ExternalID)] = m.LocalID
+	}
+
+	if err := im.importUsers(data.Users); err != nil {
+		return im.report, err
+	}
+	if err := im.importGroups(data.Groups); err != nil {
+		return im.report, err
+	}
+	if err := im.importMessages(data.Messages); err != nil {
+		return im.report, err
+	}
+	return im.report, nil
+}
+
+func mappingKey(kind, externalID string) string {
+	return kind + "/" + externalID
+}
+
+func (im *importer) logf(format string, args ...interface{}) {
+	if im.opts.Logf != nil {
+		im.opts.Logf(format, args...)
+	}
+}
+
+func (im *importer) progress(stage string, done, total int) {
+	if im.opts.Progress != nil {
+		im.opts.Progress(Progress{Stage: stage, Done: done, Total: total})
+	}
+}
+
+// lookup 查询已导入对象的本地ID。预演时尚未写入的对象返回 0 和 true
+func (im *importer) lookup(kind, externalID string) (uint, bool) {
+	id, ok := im.mappings[mappingKey(kind, externalID)]
+	return id, ok
+}
+
+// remember 在事务中记录映射关系
+func (im *importer) remember(tx *gorm.DB, kind, externalID string, localID uint) error {
+	im.mappings[mappingKey(kind, externalID)] = localID
+	if im.opts.DryRun {
+		return nil
+	}
+	return tx.Create(&models.ImportMapping{
+		Source:     im.opts.Source,
+		Kind:       kind,
+		ExternalID: externalID,
+		LocalID:    localID,
+	}).Error
+}
+
+// importUsers 导入用户，与已有用户同名时按 MatchExistingUsers 映射或跳过。新建的用户没有密码，无法直接登录
+func (im *importer) importUsers(users []User) error {
+	for i, u := range users {
+		im.progress("users", i+1, len(users))
+		if u.ID == "" || u.Username == "" {
+			im.report.Users.Failed++
+			im.logf("跳过缺少ID或用户名的用户: %+v", u)
+			continue
+		}
+		if _, ok := im.lookup(models.ImportKindUser, u.ID); ok {
+			im.report.Users.Existed++
+			continue
+		}
+
+		err := im.db.Transaction(func(tx *gorm.DB) error {
+			var user models.User
+			err := tx.Where("username = ?", u.Username).First(&user).Error
+			if err == nil {
+				if !im.opts.MatchExistingUsers {
+					im.report.Users.Failed++
+					im.logf("跳过用户 %s: 用户名已被本地用户 %d 使用，如需映射到该用户请启用 MatchExistingUsers", u.Username, user.ID)
+					return nil
+				}
+				im.report.Users.Matched++
+				im.logf("用户 %s 映射到同名的本地用户 %d", u.Username, user.ID)
+				return im.remember(tx, models.ImportKindUser, u.ID, user.ID)
+			}
+			if err != gorm.ErrRecordNotFound {
+				return err
+			}
+
+			im.report.Users.Created++
+			if im.opts.DryRun {
+				return im.remember(tx, models.ImportKindUser, u.ID, 0)
+			}
+			user = models.User{Username: u.Username, Bio: u.Bio, Status: "offline"}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			return im.remember(tx, models.ImportKindUser, u.ID, user.ID)
+		})
+		if err != nil {
+			return fmt.Errorf("导入用户 %s 失败: %w", u.Username, err)
+		}
+	}
+	return nil
+}
+
+// importGroups 导入群组并同步成员。已导入的群组只补充缺少的成员
+func (im *importer) importGroups(groups []Group) error {
+	for i, g := range groups {
+		im.progress("groups", i+1, len(groups))
+		if g.ID == "" || g.Name == "" {
+			im.report.Groups.Failed++
+			im.logf("跳过缺少ID或名称的群组: %+v", g)
+			continue
+		}
+
+		ownerExternal := g.Owner
+		if ownerExternal == "" && len(g.Members) > 0 {
+			ownerExternal = g.Members[0]
+		}
+		ownerID, ok := im.lookup(models.ImportKindUser, ownerExternal)
+		if !ok {
+			im.report.Groups.Failed++
+			im.logf("跳过群组 %s: 群主 %q 不在导入的用户中", g.Name, ownerExternal)
+			continue
+		}
+
+		visibility := g.Visibility
+		if !models.IsValidGroupVisibility(visibility) {
+			visibility = models.GroupVisibilityPrivate
+		}
+
+		err := im.db.Transaction(func(tx *gorm.DB) error {
+			groupID, exists := im.lookup(models.ImportKindGroup, g.ID)
+			if exists {
+				im.report.Groups.Existed++
+			} else {
+				im.report.Groups.Created++
+				if !im.opts.DryRun {
+					group := models.Group{
+						Name:        g.Name,
+						OwnerID:     ownerID,
+						Description: g.Description,
+						Visibility:  visibility,
+					}
+					if !g.CreatedAt.IsZero() {
+						group.CreatedAt = g.CreatedAt
+					}
+					if err := tx.Create(&group).Error; err != nil {
+						return err
+					}
+					groupID = group.ID
+				}
+				if err := im.remember(tx, models.ImportKindGroup, g.ID, groupID); err != nil {
+					return err
+				}
+			}
+			if im.opts.DryRun {
+				return nil
+			}
+
+			members := append([]string{ownerExternal}, g.Members...)
+			for _, externalID := range members {
+				userID, ok := im.lookup(models.ImportKindUser, externalID)
+				if !ok {
+					im.logf("群组 %s 的成员 %q 不在导入的用户中，已跳过", g.Name, externalID)
+					continue
+				}
+				role := models.RoleMember
+				if userID == ownerID {
+					role = models.RoleOwner
+				}
+				member := models.GroupMember{GroupID: groupID, UserID: userID, Role: role}
+				if err := tx.Where("group_id = ? AND user_id = ?", groupID, userID).FirstOrCreate(&member).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("导入群组 %s 失败: %w", g.Name, err)
+		}
+	}
+	return nil
+}
+
+// importMessages 导入消息及其附件，已导入的消息跳过
+func (im *importer) importMessages(messages []Message) error {
+	for i := range messages {
+		if (i+1)%progressInterval == 0 || i+1 == len(messages) {
+			im.progress("messages", i+1, len(messages))
+		}
+		if err := im.importMessage(&messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *importer) importMessage(m *Message) error {
+	if m.ID == "" {
+		im.report.Messages.Failed++
+		return nil
+	}
+	if _, ok := im.lookup(models.ImportKindMessage, m.ID); ok {
+		im.report.Messages.Existed++
+		return nil
+	}
+
+	userID, ok := im.lookup(models.ImportKindUser, m.User)
+	if !ok {
+		im.report.Messages.Failed++
+		im.logf("跳过消息 %s: 发送者 %q 不在导入的用户中", m.ID, m.User)
+		return nil
+	}
+
+	message := models.Message{
+		UserID:      userID,
+		Content:     m.Content,
+		MessageType: m.Type,
+		CreatedAt:   m.CreatedAt,
+	}
+	if message.MessageType == "" {
+		message.MessageType = "text"
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+
+	switch {
+	case m.Group != "":
+		groupID, ok := im.lookup(models.ImportKindGroup, m.Group)
+		if !ok {
+			im.report.Messages.Failed++
+			im.logf("跳过消息 %s: 群组 %q 不在导入的群组中", m.ID, m.Group)
+			return nil
+		}
+		message.GroupID = groupID
+	case m.Target != "":
+		targetID, ok := im.lookup(models.ImportKindUser, m.Target)
+		if !ok {
+			im.report.Messages.Failed++
+			im.logf("跳过消息 %s: 私聊对象 %q 不在导入的用户中", m.ID, m.Target)
+			return nil
+		}
+		message.TargetID = targetID
+	}
+
+	im.report.Messages.Created++
+	if im.opts.DryRun {
+		im.mappings[mappingKey(models.ImportKindMessage, m.ID)] = 0
+		if m.Attachment != nil && m.Attachment.Path != "" {
+			im.report.Attachments++
+		}
+		return nil
+	}
+
+	var user models.User
+	if err := im.db.Select("id, username").First(&user, userID).Error; err == nil {
+		message.Username = user.Username
+	}
+
+	var blobHash, scanStatus string
+	if m.Attachment != nil {
+		message.FileName = m.Attachment.Name
+		if m.Attachment.Path != "" {
+			fileURL, size, hash, err := im.copyAttachment(userID, m.Attachment)
+			if err != nil {
+				im.logf("消息 %s 的附件 %s 复制失败: %v", m.ID, m.Attachment.Path, err)
+			} else {
+				message.FileURL = fileURL
+				message.FileSize = size
+				blobHash = hash
+				im.report.Attachments++
+				if im.opts.ScanStatus != nil {
+					scanStatus = im.opts.ScanStatus(hash)
+				}
+				if scanStatus == models.ScanPending {
+					// 与上传的文件一样，扫描完成前客户端显示扫描中
+					message.FileStatus = models.FileStatusScanning
+				}
+			}
+		}
+		if message.MessageType == "text" {
+			message.MessageType = "file"
+		}
+	}
+
+	err := im.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+		if message.FileURL != "" {
+			// 记录附件归属，下载时按消息所在会话校验权限
+			if err := tx.Create(&models.Attachment{
+				FileURL:      message.FileURL,
+				OriginalName: message.FileName,
+				Size:         message.FileSize,
+				Kind:         models.AttachmentKindFile,
+				BlobHash:     blobHash,
+				ScanStatus:   scanStatus,
+				UploaderID:   userID,
+				MessageID:    message.ID,
+				GroupID:      message.GroupID,
+				TargetID:     message.TargetID,
+			}).Error; err != nil {
+				return err
+			}
+			// 计入上传者和所在群组的存储用量，导入不受配额限制
+			if err := models.AddStorageUsage(tx, models.StorageOwnerUser, userID, message.FileSize, 1); err != nil {
+				return err
+			}
+			if message.GroupID > 0 {
+				if err := models.AddStorageUsage(tx, models.StorageOwnerGroup, message.GroupID, message.FileSize, 1); err != nil {
+					return err
+				}
+			}
+		}
+		return im.remember(tx, models.ImportKindMessage, m.ID, message.ID)
+	})
+	if err != nil {
+		if blobHash != "" {
+			models.ReleaseBlob(im.db, blobHash)
+		}
+		return fmt.Errorf("导入消息 %s 失败: %w", m.ID, err)
+	}
+	return nil
+}
+
+// copyAttachment 将导出包中的附件按内容写入文件存储（与上传文件一样去重），文件名规则与 /upload 接口一致。
+// 返回文件地址、大小和内容哈希
+func (im *importer) copyAttachment(userID uint, a *Attachment) (string, int64, string, error) {
+	src, err := im.fsys.Open(path.Clean(strings.TrimPrefix(a.Path, "/")))
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer src.Close()
+
+	// zip 中的文件不能重复读取，先复制到临时文件再计算哈希和写入存储
+	tmp, err := os.CreateTemp("", "go-chat-import-*")
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, src); err != nil {
+		return "", 0, "", err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, "", err
+	}
+	hash, size, err := models.PutBlob(im.opts.Storage, tmp, "")
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	name := a.Name
+	if name == "" {
+		name = path.Base(a.Path)
+	}
+	fileName := fmt.Sprintf("%d_%d%s", userID, time.Now().UnixNano(), filepath.Ext(name))
+	return "/uploads/" + fileName, size, hash, nil
+}
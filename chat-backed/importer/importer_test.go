@@ -0,0 +1,248 @@
+package importer
+
+import (
+	"database/sql"
+	"go-chat/models"
+	"go-chat/storage"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func init() {
+	// 存储用量的累加用到 MySQL 的 GREATEST
+	sql.Register("sqlite3_import", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("greatest", func(a, b int64) int64 { return max(a, b) }, true)
+		},
+	})
+}
+
+// setupImportDB 使用临时的 SQLite 数据库替换 models.DB，返回数据库和附件存储目录
+func setupImportDB(t *testing.T) (*gorm.DB, string) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.New(sqlite.Config{
+		DriverName: "sqlite3_import",
+		DSN:        filepath.Join(t.TempDir(), "chat.db") + "?_busy_timeout=5000",
+	}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Group{}, &models.GroupMember{}, &models.Message{},
+		&models.Attachment{}, &models.Blob{}, &models.StorageUsage{}, &models.ImportMapping{}); err != nil {
+		t.Fatalf("创建测试表失败: %v", err)
+	}
+
+	oldDB := models.DB
+	models.DB = db
+	t.Cleanup(func() {
+		models.DB = oldDB
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db, filepath.Join(t.TempDir(), "uploads")
+}
+
+// testExport 两个用户、一个群组、一条群消息和一条带附件的私聊消息
+func testExport() (fstest.MapFS, *Dataset) {
+	fsys := fstest.MapFS{"files/photo.png": {Data: []byte("png data")}}
+	data := &Dataset{
+		Users: []User{{ID: "u1", Username: "alice"}, {ID: "u2", Username: "bob"}},
+		Groups: []Group{{ID: "g1", Name: "项目组", Owner: "u1", Members: []string{"u1", "u2"},
+			CreatedAt: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)}},
+		Messages: []Message{
+			{ID: "m1", User: "u1", Group: "g1", Content: "你好"},
+			{ID: "m2", User: "u2", Target: "u1", Type: "image", Attachment: &Attachment{Path: "files/photo.png", Name: "photo.png"}},
+		},
+	}
+	return fsys, data
+}
+
+func countRows(t *testing.T, db *gorm.DB) map[string]int64 {
+	t.Helper()
+	counts := make(map[string]int64)
+	for name, model := range map[string]interface{}{
+		"users": &models.User{}, "groups": &models.Group{}, "group_members": &models.GroupMember{},
+		"messages": &models.Message{}, "attachments": &models.Attachment{}, "blobs": &models.Blob{},
+		"import_mappings": &models.ImportMapping{},
+	} {
+		var n int64
+		if err := db.Model(model).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		counts[name] = n
+	}
+	return counts
+}
+
+func TestRunIsIdempotent(t *testing.T) {
+	db, uploads := setupImportDB(t)
+	fsys, data := testExport()
+	opts := Options{Source: "test", Storage: storage.NewLocal(uploads)}
+
+	report, err := Run(db, fsys, data, opts)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Users.Created != 2 || report.Groups.Created != 1 || report.Messages.Created != 2 || report.Attachments != 1 {
+		t.Fatalf("首次导入 report = %+v", report)
+	}
+	first := countRows(t, db)
+	if first["users"] != 2 || first["groups"] != 1 || first["group_members"] != 2 || first["messages"] != 2 || first["attachments"] != 1 {
+		t.Fatalf("首次导入后的记录数 = %v", first)
+	}
+
+	report, err = Run(db, fsys, data, opts)
+	if err != nil {
+		t.Fatalf("重复导入: %v", err)
+	}
+	if report.Users.Created+report.Groups.Created+report.Messages.Created != 0 || report.Attachments != 0 {
+		t.Errorf("重复导入 report = %+v, want nothing created", report)
+	}
+	if report.Users.Existed != 2 || report.Groups.Existed != 1 || report.Messages.Existed != 2 {
+		t.Errorf("重复导入 report = %+v, want everything existed", report)
+	}
+	if second := countRows(t, db); !maps.Equal(first, second) {
+		t.Errorf("重复导入后的记录数 = %v, want %v", second, first)
+	}
+}
+
+func TestRunDryRunWritesNothing(t *testing.T) {
+	db, uploads := setupImportDB(t)
+	fsys, data := testExport()
+
+	report, err := Run(db, fsys, data, Options{Source: "test", DryRun: true, Storage: storage.NewLocal(uploads)})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !report.DryRun || report.Users.Created != 2 || report.Groups.Created != 1 || report.Messages.Created != 2 || report.Attachments != 1 {
+		t.Errorf("预演 report = %+v", report)
+	}
+	for table, n := range countRows(t, db) {
+		if n != 0 {
+			t.Errorf("预演写入了 %d 条 %s", n, table)
+		}
+	}
+	if _, err := os.Stat(uploads); !os.IsNotExist(err) {
+		t.Errorf("预演写入了附件存储目录: %v", err)
+	}
+}
+
+func TestRunExistingUsername(t *testing.T) {
+	db, uploads := setupImportDB(t)
+	local := models.User{Username: "alice", Status: "offline"}
+	if err := db.Create(&local).Error; err != nil {
+		t.Fatal(err)
+	}
+	fsys, data := testExport()
+
+	// 默认不映射到同名的本地用户，该用户及其消息都不导入
+	report, err := Run(db, fsys, data, Options{Source: "test", Storage: storage.NewLocal(uploads)})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Users.Created != 1 || report.Users.Matched != 0 || report.Users.Failed != 1 {
+		t.Errorf("users = %+v, want 1 created and 1 failed", report.Users)
+	}
+	var count int64
+	db.Model(&models.Message{}).Where("user_id = ?", local.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("本地用户名下导入了 %d 条消息，want 0", count)
+	}
+
+	// 明确要求映射时计入 Matched，消息归到本地用户
+	report, err = Run(db, fsys, data, Options{Source: "test", Storage: storage.NewLocal(uploads), MatchExistingUsers: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Users.Matched != 1 || report.Users.Existed != 1 {
+		t.Errorf("users = %+v, want 1 matched and 1 existed", report.Users)
+	}
+	db.Model(&models.Message{}).Where("user_id = ?", local.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("本地用户名下有 %d 条消息，want 1", count)
+	}
+}
+
+func TestLoadSlack(t *testing.T) {
+	fsys := fstest.MapFS{
+		"users.json": {Data: []byte(`[
+			{"id": "U1", "name": "alice", "profile": {"title": "工程师"}},
+			{"id": "U2", "name": "bob"}
+		]`)},
+		"channels.json": {Data: []byte(`[
+			{"id": "C1", "name": "general", "created": 1700000000, "creator": "U1",
+			 "members": ["U1", "U2"], "purpose": {"value": "闲聊"}}
+		]`)},
+		"dms.json": {Data: []byte(`[{"id": "D1", "members": ["U1", "U2"]}]`)},
+		"general/2024-01-02.json": {Data: []byte(`[
+			{"type": "message", "subtype": "channel_join", "user": "U2", "text": "<@U2> has joined", "ts": "1704153600.000100"},
+			{"type": "message", "user": "U1", "text": "hi <@U2>, see <https://example.com|the docs> in <#C1|general> &amp; <https://go.dev>", "ts": "1704153601.000200"},
+			{"type": "message", "subtype": "me_message", "user": "U2", "text": "waves", "ts": "1704153602.000000"},
+			{"type": "message", "user": "U2", "text": "two files", "ts": "1704153603.000000",
+			 "files": [{"id": "F1", "name": "a.png", "mimetype": "image/png"}, {"id": "F2", "name": "b.pdf", "mimetype": "application/pdf"}]}
+		]`)},
+		"files/F1/a.png": {Data: []byte("png")},
+		"D1/2024-01-03.json": {Data: []byte(`[
+			{"type": "message", "user": "U2", "text": "私信", "ts": "1704240000.000000"}
+		]`)},
+	}
+
+	data, err := Load(fsys, "slack")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if len(data.Users) != 2 || data.Users[0] != (User{ID: "U1", Username: "alice", Bio: "工程师"}) {
+		t.Errorf("users = %+v", data.Users)
+	}
+	if len(data.Groups) != 1 {
+		t.Fatalf("groups = %+v", data.Groups)
+	}
+	g := data.Groups[0]
+	if g.ID != "C1" || g.Name != "general" || g.Owner != "U1" || g.Description != "闲聊" ||
+		g.Visibility != models.GroupVisibilityPublic || len(g.Members) != 2 || !g.CreatedAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("group = %+v", g)
+	}
+
+	// 加入频道的系统消息被跳过，带两个文件的消息拆成两条
+	if len(data.Messages) != 5 {
+		t.Fatalf("messages = %+v, want 5", data.Messages)
+	}
+	text := data.Messages[0]
+	if text.ID != "C1/1704153601.000200" || text.Group != "C1" || text.Type != "text" ||
+		text.Content != "hi @bob, see the docs in #general & https://go.dev" {
+		t.Errorf("text message = %+v", text)
+	}
+	if !text.CreatedAt.Equal(time.Unix(1704153601, 200000)) {
+		t.Errorf("created_at = %v", text.CreatedAt)
+	}
+	if data.Messages[1].Type != "emote" {
+		t.Errorf("me_message type = %q, want emote", data.Messages[1].Type)
+	}
+
+	image, file := data.Messages[2], data.Messages[3]
+	if image.Type != "image" || image.Content != "two files" || image.Attachment == nil ||
+		image.Attachment.Path != "files/F1/a.png" || image.Attachment.Name != "a.png" {
+		t.Errorf("first file message = %+v (%+v)", image, image.Attachment)
+	}
+	// 导出包中没有的文件只保留文件名
+	if file.ID != "C1/1704153603.000000/F2" || file.Type != "file" || file.Content != "" ||
+		file.Attachment == nil || file.Attachment.Path != "" || file.Attachment.Name != "b.pdf" {
+		t.Errorf("second file message = %+v (%+v)", file, file.Attachment)
+	}
+
+	dm := data.Messages[4]
+	if dm.ID != "D1/1704240000.000000" || dm.User != "U2" || dm.Target != "U1" || dm.Group != "" {
+		t.Errorf("dm = %+v", dm)
+	}
+}
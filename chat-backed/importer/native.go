@@ -0,0 +1,55 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+)
+
+// nativeFileName 本项目导入格式中数据文件的名称
+const nativeFileName = "export.json"
+
+// nativeVersion 当前支持的导入格式版本
+const nativeVersion = 1
+
+// 本项目的导入格式：导出包（zip 或目录）根目录下的 export.json，附件按 attachment.path 放在导出包内。
+//
+//	{
+//	  "version": 1,
+//	  "users": [
+//	    {"id": "u1", "username": "alice", "bio": "..."}
+//	  ],
+//	  "groups": [
+//	    {"id": "g1", "name": "项目组", "description": "...", "owner": "u1",
+//	     "visibility": "private", "members": ["u1", "u2"], "created_at": "2024-01-02T15:04:05Z"}
+//	  ],
+//	  "messages": [
+//	    {"id": "m1", "user": "u1", "group": "g1", "content": "你好", "type": "text",
+//	     "created_at": "2024-01-02T15:04:05Z"},
+//	    {"id": "m2", "user": "u2", "target": "u1", "content": "", "type": "image",
+//	     "created_at": "2024-01-02T15:05:00Z", "attachment": {"path": "files/photo.jpg", "name": "photo.jpg"}}
+//	  ]
+//	}
+//
+// 所有 id 均为来源系统中的字符串ID，只需在同一来源内唯一；消息的 group 和 target 二选一，都为空时导入到全局聊天。
+type nativeFile struct {
+	Version int `json:"version"`
+	Dataset
+}
+
+// loadNative 解析本项目格式的导出包
+func loadNative(fsys fs.FS) (*Dataset, error) {
+	data, err := fs.ReadFile(fsys, nativeFileName)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", nativeFileName, err)
+	}
+
+	var file nativeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", nativeFileName, err)
+	}
+	if file.Version != nativeVersion {
+		return nil, fmt.Errorf("不支持的导入格式版本: %d", file.Version)
+	}
+	return &file.Dataset, nil
+}
@@ -0,0 +1,256 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-chat/models"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Slack 导出格式：根目录下的 users.json、channels.json（公开频道）、groups.json（私有频道）、
+// mpims.json（多人私信）和 dms.json（私信），以及每个会话一个目录（频道按名称、私信按ID），
+// 目录中按天保存消息，如 general/2024-01-02.json。
+//
+// Slack 导出本身不包含附件文件，只包含需要令牌访问的链接。如果使用工具将附件下载到了
+// 导出包内的 files/<文件ID>/<文件名>，导入时会一并复制到上传目录；否则只保留文件名。
+
+type slackUser struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Profile struct {
+		DisplayName string `json:"display_name"`
+		Title       string `json:"title"`
+	} `json:"profile"`
+}
+
+type slackChannel struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Created int64    `json:"created"`
+	Creator string   `json:"creator"`
+	Members []string `json:"members"`
+	Purpose struct {
+		Value string `json:"value"`
+	} `json:"purpose"`
+}
+
+type slackFile struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Mimetype string `json:"mimetype"`
+}
+
+type slackMessage struct {
+	Type    string      `json:"type"`
+	Subtype string      `json:"subtype"`
+	User    string      `json:"user"`
+	Text    string      `json:"text"`
+	Ts      string      `json:"ts"`
+	Files   []slackFile `json:"files"`
+}
+
+// slackMention 消息中的用户提及，如 <@U024BE7LH> 或 <@U024BE7LH|bob>
+var slackMention = regexp.MustCompile(`<@([A-Z0-9]+)(?:\|[^>]*)?>`)
+
+// slackLink 消息中的链接，如 <https://example.com|example> 或 <#C123|general>
+var slackLink = regexp.MustCompile(`<([^@>|][^>|]*)(?:\|([^>]*))?>`)
+
+// 不导入的系统消息子类型（加入、离开频道和修改频道信息等）
+var slackSkippedSubtypes = map[string]bool{
+	"channel_join": true, "channel_leave": true, "channel_topic": true, "channel_purpose": true,
+	"channel_name": true, "channel_archive": true, "channel_unarchive": true,
+	"group_join": true, "group_leave": true, "group_topic": true, "group_purpose": true,
+	"group_name": true, "group_archive": true, "group_unarchive": true,
+}
+
+// loadSlack 解析 Slack 导出包
+func loadSlack(fsys fs.FS) (*Dataset, error) {
+	var users []slackUser
+	if err := readSlackJSON(fsys, "users.json", &users, true); err != nil {
+		return nil, err
+	}
+
+	data := &Dataset{}
+	usernames := make(map[string]string, len(users))
+	for _, u := range users {
+		data.Users = append(data.Users, User{ID: u.ID, Username: u.Name, Bio: u.Profile.Title})
+		usernames[u.ID] = u.Name
+	}
+
+	// 公开频道、私有频道和多人私信都导入为群组，消息目录名为频道名
+	conversations := []struct {
+		file       string
+		visibility string
+	}{
+		{"channels.json", models.GroupVisibilityPublic},
+		{"groups.json", models.GroupVisibilityPrivate},
+		{"mpims.json", models.GroupVisibilityPrivate},
+	}
+	for _, conv := range conversations {
+		var channels []slackChannel
+		if err := readSlackJSON(fsys, conv.file, &channels, false); err != nil {
+			return nil, err
+		}
+		for _, ch := range channels {
+			data.Groups = append(data.Groups, Group{
+				ID:          ch.ID,
+				Name:        ch.Name,
+				Description: ch.Purpose.Value,
+				Owner:       ch.Creator,
+				Visibility:  conv.visibility,
+				Members:     ch.Members,
+				CreatedAt:   time.Unix(ch.Created, 0),
+			})
+			messages, err := loadSlackMessages(fsys, ch.Name, ch.ID, usernames)
+			if err != nil {
+				return nil, err
+			}
+			for i := range messages {
+				messages[i].Group = ch.ID
+			}
+			data.Messages = append(data.Messages, messages...)
+		}
+	}
+
+	// 私信导入为私聊消息，消息目录名为私信ID
+	var dms []slackChannel
+	if err := readSlackJSON(fsys, "dms.json", &dms, false); err != nil {
+		return nil, err
+	}
+	for _, dm := range dms {
+		if len(dm.Members) != 2 {
+			continue
+		}
+		messages, err := loadSlackMessages(fsys, dm.ID, dm.ID, usernames)
+		if err != nil {
+			return nil, err
+		}
+		for i := range messages {
+			if messages[i].User == dm.Members[0] {
+				messages[i].Target = dm.Members[1]
+			} else {
+				messages[i].Target = dm.Members[0]
+			}
+		}
+		data.Messages = append(data.Messages, messages...)
+	}
+
+	return data, nil
+}
+
+// readSlackJSON 读取导出包根目录下的 JSON 文件，可选文件不存在时忽略
+func readSlackJSON(fsys fs.FS, name string, v interface{}, required bool) error {
+	raw, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("读取 %s 失败: %w", name, err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+// loadSlackMessages 读取会话目录下按天保存的消息。消息ID由会话ID和 Slack 时间戳组成；
+// 带多个文件的消息拆分为多条，每条一个附件
+func loadSlackMessages(fsys fs.FS, dir, conversationID string, usernames map[string]string) ([]Message, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var messages []Message
+	for _, file := range files {
+		var day []slackMessage
+		if err := readSlackJSON(fsys, file, &day, true); err != nil {
+			return nil, err
+		}
+
+		for _, sm := range day {
+			if sm.Type != "message" || sm.User == "" || slackSkippedSubtypes[sm.Subtype] {
+				continue
+			}
+			base := Message{
+				ID:        conversationID + "/" + sm.Ts,
+				User:      sm.User,
+				Content:   convertSlackText(sm.Text, usernames),
+				Type:      "text",
+				CreatedAt: parseSlackTs(sm.Ts),
+			}
+			if sm.Subtype == "me_message" {
+				base.Type = "emote"
+			}
+			if len(sm.Files) == 0 {
+				messages = append(messages, base)
+				continue
+			}
+
+			for i, f := range sm.Files {
+				m := base
+				if i > 0 {
+					m.ID = base.ID + "/" + f.ID
+					m.Content = ""
+				}
+				m.Type = "file"
+				if strings.HasPrefix(f.Mimetype, "image/") {
+					m.Type = "image"
+				}
+				m.Attachment = &Attachment{Name: f.Name}
+				if candidate := path.Join("files", f.ID, f.Name); fileExists(fsys, candidate) {
+					m.Attachment.Path = candidate
+				}
+				messages = append(messages, m)
+			}
+		}
+	}
+	return messages, nil
+}
+
+// convertSlackText 将 Slack 的消息标记转换为纯文本：提及转为 @用户名，链接保留显示文本或地址
+func convertSlackText(text string, usernames map[string]string) string {
+	text = slackMention.ReplaceAllStringFunc(text, func(s string) string {
+		id := slackMention.FindStringSubmatch(s)[1]
+		if name, ok := usernames[id]; ok {
+			return "@" + name
+		}
+		return "@" + id
+	})
+	text = slackLink.ReplaceAllStringFunc(text, func(s string) string {
+		parts := slackLink.FindStringSubmatch(s)
+		if parts[2] != "" {
+			if strings.HasPrefix(parts[1], "#") {
+				return "#" + parts[2]
+			}
+			return parts[2]
+		}
+		return parts[1]
+	})
+	replacer := strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+	return replacer.Replace(text)
+}
+
+// parseSlackTs 解析 Slack 时间戳，如 1580000000.000200
+func parseSlackTs(ts string) time.Time {
+	sec, frac, _ := strings.Cut(ts, ".")
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	us, _ := strconv.ParseInt((frac + "000000")[:6], 10, 64)
+	return time.Unix(s, us*1000)
+}
+
+func fileExists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
@@ -18,10 +18,22 @@ func main() {
 	}
 
 	checkEnvVariables()
+	utils.InitJWT()
 	models.InitDB()
-
-	go routes.HandleMessages() // 启动广播协程
-	utils.InitOnlineUsers()    // 启动在线用户清理协程
+	routes.InitStorage()
+	routes.InitScanner()
+
+	go routes.HandleMessages()           // 启动广播协程
+	utils.InitOnlineUsers()              // 启动在线用户清理协程
+	routes.StartGroupPurgeWorker()       // 启动已解散群组清除任务
+	routes.StartRetentionWorker()        // 启动消息保留策略任务
+	routes.StartDisappearingWorker()     // 启动阅后即焚消息销毁任务
+	routes.StartScheduledMessageWorker() // 启动定时消息调度器
+	routes.StartExportWorker()           // 启动聊天记录导出任务
+	routes.StartUploadSessionWorker()    // 启动过期上传会话清理任务
+	routes.StartUploadGCWorker()         // 启动未引用上传文件清理任务
+	routes.StartScanWorker()             // 启动上传文件安全扫描任务
+	routes.StartLinkPreviewWorker()      // 启动链接预览抓取任务
 
 	r := gin.Default()
 	r.Use(middleware.CORSMiddleware())
@@ -40,6 +52,13 @@ func main() {
 	// 添加文件上传路由
 	r.POST("/upload", middleware.JWTAuthMiddleware(), routes.UploadFile)
 	r.GET("/uploads/:filename", routes.ServeFile)
+	// 断点续传：创建会话、查询进度、上传分片、完成和取消
+	r.POST("/upload/sessions", middleware.JWTAuthMiddleware(), routes.CreateUploadSession)
+	r.GET("/upload/sessions/:id", middleware.JWTAuthMiddleware(), routes.GetUploadSession)
+	r.PATCH("/upload/sessions/:id", middleware.JWTAuthMiddleware(), routes.UploadChunk)
+	r.POST("/upload/sessions/:id/complete", middleware.JWTAuthMiddleware(), routes.CompleteUploadSession)
+	r.DELETE("/upload/sessions/:id", middleware.JWTAuthMiddleware(), routes.AbortUploadSession)
+	r.GET("/attachments/signed-url", middleware.JWTAuthMiddleware(), routes.GetSignedFileURL)
 
 	// 添加新的路由
 	// 用户资料路由
@@ -47,6 +66,11 @@ func main() {
 	r.PUT("/profile", middleware.JWTAuthMiddleware(), routes.UpdateProfile)
 	r.POST("/profile/avatar", middleware.JWTAuthMiddleware(), routes.UploadAvatar)
 	r.PUT("/profile/status", middleware.JWTAuthMiddleware(), routes.UpdateUserStatus)
+	r.GET("/profile/storage", middleware.JWTAuthMiddleware(), routes.GetStorageUsage)
+
+	// 存储配额管理（仅系统管理员，type 为 user 或 group）
+	r.GET("/admin/storage/:type/:id", middleware.JWTAuthMiddleware(), middleware.AdminMiddleware(), routes.AdminGetStorageUsage)
+	r.PUT("/admin/storage/:type/:id/quota", middleware.JWTAuthMiddleware(), middleware.AdminMiddleware(), routes.AdminSetStorageQuota)
 
 	// 好友系统路由
 	r.GET("/friends", middleware.JWTAuthMiddleware(), routes.GetFriends)
@@ -58,8 +82,29 @@ func main() {
 	// 用户搜索（按用户名模糊查询）
 	r.GET("/users/search", middleware.JWTAuthMiddleware(), routes.SearchUsers)
 
+	// 消息全文搜索
+	r.GET("/search/messages", middleware.JWTAuthMiddleware(), routes.SearchMessages)
+
+	// 阅后即焚：标记已读、私聊会话设置
+	r.POST("/messages/:id/read", middleware.JWTAuthMiddleware(), routes.MarkMessageRead)
+	r.GET("/direct/:userId/disappearing", middleware.JWTAuthMiddleware(), routes.GetDirectDisappearing)
+	r.PUT("/direct/:userId/disappearing", middleware.JWTAuthMiddleware(), routes.UpdateDirectDisappearing)
+
+	// 定时消息
+	r.POST("/scheduled-messages", middleware.JWTAuthMiddleware(), routes.CreateScheduledMessage)
+	r.GET("/scheduled-messages", middleware.JWTAuthMiddleware(), routes.GetScheduledMessages)
+	r.PUT("/scheduled-messages/:id", middleware.JWTAuthMiddleware(), routes.UpdateScheduledMessage)
+	r.DELETE("/scheduled-messages/:id", middleware.JWTAuthMiddleware(), routes.CancelScheduledMessage)
+
+	// 聊天记录导出（下载链接自带令牌，无需登录）
+	r.POST("/exports", middleware.JWTAuthMiddleware(), routes.CreateExport)
+	r.GET("/exports", middleware.JWTAuthMiddleware(), routes.GetExports)
+	r.GET("/exports/:id", middleware.JWTAuthMiddleware(), routes.GetExport)
+	r.GET("/exports/:id/download", routes.DownloadExport)
+
 	// 静态文件服务（添加头像目录）
-	r.Static("/uploads/avatars", "./uploads/avatars")
+	r.GET("/uploads/avatars/:filename", routes.ServeAvatar)
+	r.GET("/uploads/thumbs/:filename", routes.ServeThumbnail)
 
 	// 注册群组路由
 	routes.GroupsRoutes(r)
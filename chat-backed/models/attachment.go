@@ -0,0 +1,93 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 附件类型
+const (
+	AttachmentKindFile      = "file"      // 聊天消息中的文件、图片
+	AttachmentKindAvatar    = "avatar"    // 用户头像
+	AttachmentKindThumbnail = "thumbnail" // 图片缩略图，访问权限跟随原图
+)
+
+// 附件的安全扫描状态，为空表示未启用扫描时上传的文件，视同安全
+const (
+	ScanPending     = "pending"     // 等待扫描，扫描通过前不能下载
+	ScanClean       = "clean"       // 扫描通过
+	ScanInfected    = "infected"    // 检测到恶意软件，文件已删除
+	ScanUnscannable = "unscannable" // 超出扫描器的大小限制等原因无法扫描，不能下载
+)
+
+// Thumbnail 图片缩略图
+type Thumbnail struct {
+	Size   string `json:"size"` // 规格: small, medium, large
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Attachment 上传文件记录，关联上传者以及文件被发送到的会话，下载时据此校验访问权限
+type Attachment struct {
+	ID           uint        `json:"id" gorm:"primaryKey"`
+	FileURL      string      `json:"file_url" gorm:"size:255;not null;uniqueIndex"`         // 访问地址，如 /uploads/1_1700000000.png
+	OriginalName string      `json:"original_name" gorm:"size:255"`                         // 上传时的原始文件名
+	Size         int64       `json:"size"`                                                  // 文件大小
+	ContentType  string      `json:"content_type" gorm:"size:127"`                          // 根据文件头识别出的类型
+	BlobHash     string      `json:"-" gorm:"size:64;index"`                                // 文件内容的哈希（见 Blob），为空表示去重之前上传、按地址存储的文件
+	ScanStatus   string      `json:"scan_status,omitempty" gorm:"size:16;index"`            // 安全扫描状态: pending, clean, unscannable
+	ScanAttempts int         `json:"-" gorm:"default:0"`                                    // 扫描失败的次数
+	NextScanAt   *time.Time  `json:"-"`                                                     // 扫描失败后下次重试的时间，为空表示尽快扫描
+	Kind         string      `json:"kind" gorm:"size:16;not null;default:'file'"`           // 类型: file, avatar, thumbnail
+	ParentID     uint        `json:"parent_id,omitempty" gorm:"default:0;index"`            // 缩略图所属的原图附件ID
+	Width        int         `json:"width,omitempty"`                                       // 图片宽度
+	Height       int         `json:"height,omitempty"`                                      // 图片高度
+	Blurhash     string      `json:"blurhash,omitempty" gorm:"size:64"`                     // 图片加载前显示的占位图
+	Thumbnails   []Thumbnail `json:"thumbnails,omitempty" gorm:"serializer:json;type:text"` // 缩略图列表
+	DurationMs   int         `json:"duration_ms,omitempty"`                                 // 音频时长（毫秒）
+	Waveform     []int       `json:"waveform,omitempty" gorm:"serializer:json;type:text"`   // 音频波形，0-100 的相对音量
+	UploaderID   uint        `json:"uploader_id" gorm:"not null;index"`                     // 上传者ID
+	MessageID    uint        `json:"message_id" gorm:"default:0;index"`                     // 发送后所属的消息ID，0表示尚未发送
+	GroupID      uint        `json:"group_id" gorm:"default:0"`                             // 发送到的群组ID
+	ChannelID    uint        `json:"channel_id" gorm:"default:0"`                           // 发送到的群组频道ID
+	TargetID     uint        `json:"target_id" gorm:"default:0"`                            // 发送到的私聊对象ID
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// TableName 指定附件表名
+func (Attachment) TableName() string {
+	return "attachments"
+}
+
+// Quarantined 文件是否因等待扫描或无法扫描而不能下载
+func (a *Attachment) Quarantined() bool {
+	return a.ScanStatus == ScanPending || a.ScanStatus == ScanUnscannable
+}
+
+// BlobScanStatus 启用扫描时新文件的初始扫描状态：相同内容已扫描通过时直接通过，否则等待扫描
+func BlobScanStatus(db *gorm.DB, blobHash string) string {
+	var clean int64
+	db.Model(&Attachment{}).Where("blob_hash = ? AND scan_status = ?", blobHash, ScanClean).Count(&clean)
+	if clean > 0 {
+		return ScanClean
+	}
+	return ScanPending
+}
+
+// BackfillAttachments 为引入附件表之前上传的文件补充附件记录，消息中的文件按消息所在会话关联，头像按用户关联
+func BackfillAttachments() {
+	DB.Exec(`INSERT INTO attachments (file_url, original_name, size, kind, uploader_id, message_id, group_id, channel_id, target_id, created_at)
+		SELECT m.file_url, m.file_name, m.file_size, ?, m.user_id, m.id, m.group_id, m.channel_id, m.target_id, m.created_at
+		FROM messages m
+		WHERE m.file_url LIKE '/uploads/%' AND m.id = (SELECT MIN(m2.id) FROM messages m2 WHERE m2.file_url = m.file_url)
+		AND NOT EXISTS (SELECT 1 FROM attachments a WHERE a.file_url = m.file_url)`, AttachmentKindFile)
+	DB.Exec(`INSERT INTO attachments (file_url, kind, uploader_id, created_at)
+		SELECT u.avatar, ?, u.id, u.updated_at
+		FROM users u
+		WHERE u.avatar LIKE '/uploads/avatars/%'
+		AND NOT EXISTS (SELECT 1 FROM attachments a WHERE a.file_url = u.avatar)`, AttachmentKindAvatar)
+	fmt.Println("✅ 附件记录检查完成")
+}
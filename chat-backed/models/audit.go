@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 群组审计操作类型
+const (
+	AuditGroupCreate        = "group_create"        // 创建群组
+	AuditGroupUpdate        = "group_update"        // 修改群信息
+	AuditGroupDelete        = "group_delete"        // 解散群组
+	AuditGroupRestore       = "group_restore"       // 恢复已解散的群组
+	AuditMemberAdd          = "member_add"          // 邀请成员入群
+	AuditMemberJoin         = "member_join"         // 成员自行加入（公开群组、邀请链接）
+	AuditMemberRemove       = "member_remove"       // 移出成员
+	AuditMemberBan          = "member_ban"          // 移出并封禁成员
+	AuditMemberUnban        = "member_unban"        // 解除封禁
+	AuditMemberMute         = "member_mute"         // 禁言成员
+	AuditMemberUnmute       = "member_unmute"       // 解除禁言
+	AuditMemberRoleChange   = "member_role_change"  // 修改成员角色
+	AuditOwnerTransfer      = "owner_transfer"      // 转让群主
+	AuditSlowModeChange     = "slow_mode_change"    // 修改慢速模式
+	AuditInviteCreate       = "invite_create"       // 创建邀请链接
+	AuditInviteRevoke       = "invite_revoke"       // 撤销邀请链接
+	AuditJoinRequestReview  = "join_request_review" // 审批入群申请
+	AuditRoleCreate         = "role_create"         // 创建自定义角色
+	AuditRoleUpdate         = "role_update"         // 修改自定义角色
+	AuditRoleDelete         = "role_delete"         // 删除自定义角色
+	AuditMessagePin         = "message_pin"         // 置顶消息
+	AuditMessageUnpin       = "message_unpin"       // 取消置顶
+	AuditAnnouncement       = "announcement"        // 发布群公告
+	AuditChannelCreate      = "channel_create"      // 创建频道
+	AuditChannelUpdate      = "channel_update"      // 修改频道
+	AuditChannelDelete      = "channel_delete"      // 删除频道
+	AuditChannelMemberAdd   = "channel_member_add"  // 添加私有频道成员
+	AuditChannelMemberDel   = "channel_member_del"  // 移除私有频道成员
+	AuditRetentionChange    = "retention_change"    // 修改消息保留策略
+	AuditDisappearingChange = "disappearing_change" // 修改阅后即焚设置
+)
+
+// ErrAuditLogImmutable 审计日志只能追加，不能修改或删除
+var ErrAuditLogImmutable = errors.New("审计日志不可修改或删除")
+
+// GroupAuditLog 群组审计日志模型（只追加）
+type GroupAuditLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	GroupID   uint      `json:"group_id" gorm:"not null"`         // 群组ID
+	ActorID   uint      `json:"actor_id" gorm:"not null"`         // 操作者ID
+	Action    string    `json:"action" gorm:"size:32;not null"`   // 操作类型
+	TargetID  uint      `json:"target_id"`                        // 操作对象ID（用户、邀请链接、角色等，视操作类型而定）
+	Before    string    `json:"before" gorm:"type:text"`          // 操作前的值（JSON）
+	After     string    `json:"after" gorm:"type:text"`           // 操作后的值（JSON）
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"` // 操作时间
+
+	// 关联关系
+	Actor User `json:"actor" gorm:"foreignKey:ActorID"` // 操作者信息
+}
+
+// TableName 指定群组审计日志表名
+func (GroupAuditLog) TableName() string {
+	return "group_audit_logs"
+}
+
+// BeforeUpdate 禁止修改审计日志
+func (GroupAuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete 禁止删除审计日志
+func (GroupAuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// CreateAuditIndexes 创建审计日志相关索引
+func CreateAuditIndexes() {
+	// 按群组和时间倒序查询审计日志
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_group_audit_logs_group_created ON group_audit_logs(group_id, created_at)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_group_audit_logs_group_action ON group_audit_logs(group_id, action)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_group_audit_logs_actor_id ON group_audit_logs(actor_id)")
+}
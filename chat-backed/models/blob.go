@@ -0,0 +1,90 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"go-chat/storage"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Blob 按内容哈希存储的文件。内容相同的上传只保存一份，RefCount 记录引用它的附件数量，
+// 计数归零后由清理任务从存储后端删除
+type Blob struct {
+	Hash        string    `json:"hash" gorm:"primaryKey;size:64"` // 内容的 SHA-256
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type" gorm:"size:127"`
+	RefCount    int       `json:"ref_count" gorm:"not null;default:0;index"` // 引用该文件的附件数量
+	Pending     bool      `json:"pending" gorm:"not null;default:false"`     // 内容尚未写入存储后端
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定文件内容表名
+func (Blob) TableName() string {
+	return "blobs"
+}
+
+// BlobKey 文件内容在存储后端中的键，按哈希前两位分目录
+func BlobKey(hash string) string {
+	return "blobs/" + hash[:2] + "/" + hash
+}
+
+// PutBlob 计算内容哈希并增加引用计数，内容尚未写入时写入存储后端。返回哈希和内容大小，
+// 调用方保存引用该内容的附件失败时需要调用 ReleaseBlob。
+//
+// 第一次出现的内容在写入完成前标记为 Pending，同时上传相同内容的请求各自写入一份（键相同、内容相同），
+// 而不是直接返回，避免先上传的请求写入失败后其他附件引用了不存在的内容
+func PutBlob(s storage.Storage, r io.ReadSeeker, contentType string) (string, int64, error) {
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return "", 0, err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	blob := Blob{Hash: hash, Size: size, ContentType: contentType, RefCount: 1, Pending: true}
+	if err := DB.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"ref_count":  gorm.Expr("ref_count + 1"),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&blob).Error; err != nil {
+		return "", 0, err
+	}
+
+	// 已持有引用，记录不会被清理任务删除；不再是 Pending 时内容已写入完成
+	var stored Blob
+	if err := DB.Select("pending").Where("hash = ?", hash).First(&stored).Error; err != nil {
+		ReleaseBlob(DB, hash)
+		return "", 0, err
+	}
+	if !stored.Pending {
+		return hash, size, nil
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		ReleaseBlob(DB, hash)
+		return "", 0, err
+	}
+	if err := s.Put(BlobKey(hash), r, size, contentType); err != nil {
+		ReleaseBlob(DB, hash)
+		return "", 0, err
+	}
+	if err := DB.Model(&Blob{}).Where("hash = ?", hash).Update("pending", false).Error; err != nil {
+		ReleaseBlob(DB, hash)
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+// ReleaseBlob 减少文件内容的引用计数
+func ReleaseBlob(tx *gorm.DB, hash string) error {
+	return tx.Model(&Blob{}).Where("hash = ? AND ref_count > 0", hash).Updates(map[string]interface{}{
+		"ref_count":  gorm.Expr("ref_count - 1"),
+		"updated_at": time.Now(),
+	}).Error
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// DefaultChannelName 默认频道的名称。默认频道不单独建表，对应消息的 channel_id 为 0
+const DefaultChannelName = "general"
+
+// GroupChannel 群组内的频道模型
+type GroupChannel struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	GroupID   uint      `json:"group_id" gorm:"not null;uniqueIndex:idx_group_channels_group_name"`     // 所属群组ID
+	Name      string    `json:"name" gorm:"size:32;not null;uniqueIndex:idx_group_channels_group_name"` // 频道名，如 deploys
+	Topic     string    `json:"topic" gorm:"size:255"`                                                  // 频道话题
+	Private   bool      `json:"private" gorm:"not null;default:false"`                                  // 私有频道仅频道成员可见
+	CreatorID uint      `json:"creator_id"`                                                             // 创建者ID
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GroupChannelMember 私有频道成员关系模型
+type GroupChannelMember struct {
+	ChannelID uint      `json:"channel_id" gorm:"primaryKey"` // 频道ID
+	UserID    uint      `json:"user_id" gorm:"primaryKey"`    // 用户ID
+	CreatedAt time.Time `json:"created_at"`
+
+	// 关联关系
+	User User `json:"user" gorm:"foreignKey:UserID"` // 用户信息
+}
+
+// TableName 指定频道表名
+func (GroupChannel) TableName() string {
+	return "group_channels"
+}
+
+// TableName 指定频道成员表名
+func (GroupChannelMember) TableName() string {
+	return "group_channel_members"
+}
+
+// CreateChannelIndexes 创建频道相关索引
+func CreateChannelIndexes() {
+	// 按用户查询所在的私有频道
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_group_channel_members_user_id ON group_channel_members(user_id)")
+	// 按群组、频道和时间查询消息历史
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_group_channel_created ON messages(group_id, channel_id, created_at)")
+}
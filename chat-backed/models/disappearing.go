@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// 阅后即焚消息的计时方式
+const (
+	ExpireModeTimer     = "timer"      // 发送后经过固定时间销毁
+	ExpireModeAfterRead = "after_read" // 接收方阅读后开始计时，到期销毁
+)
+
+// DisappearingSetting 会话级的阅后即焚设置。群组按 GroupID 区分；
+// 私聊的 GroupID 为 0，UserLowID/UserHighID 为双方用户ID中较小和较大的一个
+type DisappearingSetting struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	GroupID    uint      `json:"group_id" gorm:"not null;default:0;uniqueIndex:idx_disappearing_conversation"`     // 群组ID，0表示私聊
+	UserLowID  uint      `json:"user_low_id" gorm:"not null;default:0;uniqueIndex:idx_disappearing_conversation"`  // 私聊双方中较小的用户ID
+	UserHighID uint      `json:"user_high_id" gorm:"not null;default:0;uniqueIndex:idx_disappearing_conversation"` // 私聊双方中较大的用户ID
+	Mode       string    `json:"mode" gorm:"size:16;not null;default:'timer'"`                                     // 计时方式: timer, after_read
+	TTLSeconds int       `json:"ttl_seconds" gorm:"not null"`                                                      // 消息存活时间（秒）
+	UpdatedBy  uint      `json:"updated_by"`                                                                       // 最后修改者ID
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName 指定阅后即焚设置表名
+func (DisappearingSetting) TableName() string {
+	return "disappearing_settings"
+}
+
+// DirectConversationKey 返回私聊双方按大小排序后的用户ID，作为私聊会话的唯一标识
+func DirectConversationKey(userA, userB uint) (uint, uint) {
+	if userA > userB {
+		return userB, userA
+	}
+	return userA, userB
+}
+
+// IsValidExpireMode 校验阅后即焚计时方式
+func IsValidExpireMode(mode string) bool {
+	return mode == ExpireModeTimer || mode == ExpireModeAfterRead
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// 导出范围
+const (
+	ExportScopeGroup  = "group"  // 群组聊天记录
+	ExportScopeDirect = "direct" // 与某个用户的私聊记录
+	ExportScopeUser   = "user"   // 当前用户的全部数据
+)
+
+// 导出任务状态
+const (
+	ExportPending = "pending" // 等待处理
+	ExportRunning = "running" // 正在生成
+	ExportDone    = "done"    // 已完成，可下载
+	ExportFailed  = "failed"  // 生成失败
+	ExportExpired = "expired" // 下载链接已过期，文件已删除
+)
+
+// ExportJob 聊天记录导出任务模型
+type ExportJob struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`                    // 导出者ID
+	Scope         string     `json:"scope" gorm:"size:16;not null"`                    // 导出范围: group, direct, user
+	GroupID       uint       `json:"group_id,omitempty" gorm:"default:0"`              // 导出的群组ID
+	PeerID        uint       `json:"peer_id,omitempty" gorm:"default:0"`               // 导出的私聊对象ID
+	Format        string     `json:"format" gorm:"size:8;not null"`                    // 格式: json, csv, html
+	Status        string     `json:"status" gorm:"size:16;not null;default:'pending'"` // 状态: pending, running, done, failed, expired
+	MessageCount  int        `json:"message_count"`                                    // 已导出的消息数
+	FileSize      int64      `json:"file_size"`                                        // 压缩包大小
+	StorageKey    string     `json:"-" gorm:"size:255"`                                // 压缩包在存储后端中的键
+	FilePath      string     `json:"-"`                                                // 早期版本保存在本地导出目录的压缩包路径
+	DownloadToken string     `json:"-" gorm:"size:64;index"`                           // 下载链接中的随机令牌
+	Error         string     `json:"error,omitempty" gorm:"size:255"`                  // 失败原因
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`                             // 下载链接过期时间
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// TableName 指定导出任务表名
+func (ExportJob) TableName() string {
+	return "export_jobs"
+}
+
+// IsValidExportScope 校验导出范围
+func IsValidExportScope(scope string) bool {
+	return scope == ExportScopeGroup || scope == ExportScopeDirect || scope == ExportScopeUser
+}
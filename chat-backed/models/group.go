@@ -7,13 +7,22 @@ import (
 	"gorm.io/gorm"
 )
 
+// 群组可见性
+const (
+	GroupVisibilityPublic  = "public"  // 公开：任何人可搜索并直接加入
+	GroupVisibilityRequest = "request" // 申请加入：可被搜索，加入需群主或管理员审批
+	GroupVisibilityPrivate = "private" // 私有：不可搜索，只能由成员邀请加入
+)
+
 // Group 群组模型
 type Group struct {
 	gorm.Model
-	Name        string `json:"name" gorm:"not null"`         // 群名
-	OwnerID     uint   `json:"owner_id" gorm:"not null"`     // 群主的用户ID
-	Avatar      string `json:"avatar" gorm:"default:''"`     // 群头像链接
-	Description string `json:"description" gorm:"type:text"` // 群描述
+	Name        string `json:"name" gorm:"not null"`                         // 群名
+	OwnerID     uint   `json:"owner_id" gorm:"not null"`                     // 群主的用户ID
+	Avatar      string `json:"avatar" gorm:"default:''"`                     // 群头像链接
+	Description string `json:"description" gorm:"type:text"`                 // 群描述
+	Visibility  string `json:"visibility" gorm:"not null;default:'private'"` // 可见性: public, request, private
+	SlowMode    int    `json:"slow_mode" gorm:"not null;default:0"`          // 慢速模式间隔（秒），0表示关闭
 
 	// 关联关系
 	Owner   User          `json:"owner" gorm:"foreignKey:OwnerID"`   // 群主信息
@@ -22,17 +31,79 @@ type Group struct {
 
 // GroupMember 群组成员关系模型
 type GroupMember struct {
-	UserID    uint      `json:"user_id" gorm:"primaryKey"`             // 用户ID
-	GroupID   uint      `json:"group_id" gorm:"primaryKey"`            // 群组ID
-	Role      string    `json:"role" gorm:"not null;default:'member'"` // 角色: owner, admin, member
-	JoinedAt  time.Time `json:"joined_at" gorm:"autoCreateTime"`       // 加入时间
-	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`      // 更新时间
+	UserID     uint       `json:"user_id" gorm:"primaryKey"`             // 用户ID
+	GroupID    uint       `json:"group_id" gorm:"primaryKey"`            // 群组ID
+	Role       string     `json:"role" gorm:"not null;default:'member'"` // 角色: owner, admin, member
+	MutedUntil *time.Time `json:"muted_until"`                           // 禁言截止时间，为空表示未禁言
+	JoinedAt   time.Time  `json:"joined_at" gorm:"autoCreateTime"`       // 加入时间
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"autoUpdateTime"`      // 更新时间
 
 	// 关联关系
 	User  User  `json:"user" gorm:"foreignKey:UserID"`   // 用户信息
 	Group Group `json:"group" gorm:"foreignKey:GroupID"` // 群组信息
 }
 
+// GroupBan 群组封禁记录模型，被封禁的用户无法通过任何方式重新加入群组
+type GroupBan struct {
+	GroupID    uint      `json:"group_id" gorm:"primaryKey"` // 群组ID
+	UserID     uint      `json:"user_id" gorm:"primaryKey"`  // 被封禁用户ID
+	OperatorID uint      `json:"operator_id"`                // 操作者ID
+	Reason     string    `json:"reason" gorm:"type:text"`    // 封禁原因
+	CreatedAt  time.Time `json:"created_at"`
+
+	// 关联关系
+	User User `json:"user" gorm:"foreignKey:UserID"` // 被封禁用户信息
+}
+
+// GroupJoinRequest 入群申请模型
+type GroupJoinRequest struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	GroupID    uint      `json:"group_id" gorm:"not null"`                 // 群组ID
+	UserID     uint      `json:"user_id" gorm:"not null"`                  // 申请人ID
+	Message    string    `json:"message" gorm:"type:text"`                 // 申请附言
+	Status     string    `json:"status" gorm:"not null;default:'pending'"` // 状态: pending, approved, rejected
+	ReviewerID uint      `json:"reviewer_id"`                              // 审批人ID
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// 关联关系
+	User User `json:"user" gorm:"foreignKey:UserID"` // 申请人信息
+}
+
+// GroupInvite 群组邀请链接模型
+type GroupInvite struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	GroupID   uint       `json:"group_id" gorm:"not null"`                 // 群组ID
+	Code      string     `json:"code" gorm:"size:32;not null;uniqueIndex"` // 邀请码
+	CreatorID uint       `json:"creator_id" gorm:"not null"`               // 创建者ID
+	ExpiresAt *time.Time `json:"expires_at"`                               // 过期时间，为空表示永不过期
+	MaxUses   int        `json:"max_uses" gorm:"not null;default:0"`       // 最大使用次数，0表示不限
+	Uses      int        `json:"uses" gorm:"not null;default:0"`           // 已使用次数
+	RevokedAt *time.Time `json:"revoked_at"`                               // 撤销时间，为空表示未撤销
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// IsUsable 判断邀请链接当前是否可用
+func (i *GroupInvite) IsUsable() bool {
+	if i.RevokedAt != nil {
+		return false
+	}
+	if i.ExpiresAt != nil && !i.ExpiresAt.After(time.Now()) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+// IsValidGroupVisibility 判断可见性取值是否合法
+func IsValidGroupVisibility(v string) bool {
+	return v == GroupVisibilityPublic || v == GroupVisibilityRequest || v == GroupVisibilityPrivate
+}
+
+// IsMuted 判断成员当前是否处于禁言状态
+func (m *GroupMember) IsMuted() bool {
+	return m.MutedUntil != nil && m.MutedUntil.After(time.Now())
+}
+
 // TableName 指定群组表名
 func (Group) TableName() string {
 	return "groups"
@@ -43,6 +114,21 @@ func (GroupMember) TableName() string {
 	return "group_members"
 }
 
+// TableName 指定入群申请表名
+func (GroupJoinRequest) TableName() string {
+	return "group_join_requests"
+}
+
+// TableName 指定群组封禁表名
+func (GroupBan) TableName() string {
+	return "group_bans"
+}
+
+// TableName 指定邀请链接表名
+func (GroupInvite) TableName() string {
+	return "group_invites"
+}
+
 // CreateGroupIndexes 创建群组相关索引
 func CreateGroupIndexes() {
 	// 为群组所有者创建索引
@@ -61,5 +147,15 @@ func CreateGroupIndexes() {
 	// 为群组名称创建索引，支持群组搜索
 	DB.Exec("CREATE INDEX IF NOT EXISTS idx_groups_name ON groups(name)")
 
+	// 为群组可见性创建索引，支持群组发现
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_groups_visibility ON groups(visibility)")
+
+	// 为入群申请创建复合索引，提高按群组和状态查询的性能
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_group_join_requests_group_status ON group_join_requests(group_id, status)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_group_join_requests_user_id ON group_join_requests(user_id)")
+
+	// 为邀请链接创建索引，提高按群组查询的性能
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_group_invites_group_id ON group_invites(group_id)")
+
 	log.Println("✅ 群组相关索引创建完成")
 }
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// 导入映射的对象类型
+const (
+	ImportKindUser    = "user"
+	ImportKindGroup   = "group"
+	ImportKindMessage = "message"
+)
+
+// ImportMapping 记录外部系统中的对象与本地记录的对应关系，重复导入同一份数据时据此跳过已导入的对象
+type ImportMapping struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Source     string    `json:"source" gorm:"size:64;not null;uniqueIndex:idx_import_mappings_external"`       // 数据来源标识，如 slack-acme
+	Kind       string    `json:"kind" gorm:"size:16;not null;uniqueIndex:idx_import_mappings_external"`         // 对象类型: user, group, message
+	ExternalID string    `json:"external_id" gorm:"size:128;not null;uniqueIndex:idx_import_mappings_external"` // 外部系统中的ID
+	LocalID    uint      `json:"local_id" gorm:"not null"`                                                      // 本地记录ID
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName 指定导入映射表名
+func (ImportMapping) TableName() string {
+	return "import_mappings"
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// LinkPreview 消息中链接的预览信息
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// LinkPreviewCache 链接预览缓存，抓取失败的结果也会缓存一段时间，避免反复请求同一地址
+type LinkPreviewCache struct {
+	ID        uint         `json:"id" gorm:"primaryKey"`
+	URLHash   string       `json:"-" gorm:"size:64;not null;uniqueIndex"`    // 链接的 SHA-256，链接可能很长，按哈希查询
+	URL       string       `json:"url" gorm:"type:text"`                     // 链接地址
+	Preview   *LinkPreview `json:"preview" gorm:"serializer:json;type:text"` // 预览信息，为空表示抓取失败或页面没有预览信息
+	FetchedAt time.Time    `json:"fetched_at" gorm:"index"`                  // 抓取时间
+}
+
+// TableName 指定链接预览缓存表名
+func (LinkPreviewCache) TableName() string {
+	return "link_preview_cache"
+}
@@ -5,17 +5,38 @@ import (
 	"time"
 )
 
+// 消息中文件的状态，为空表示可以正常下载
+const (
+	FileStatusScanning    = "scanning"    // 文件正在进行安全扫描
+	FileStatusInfected    = "infected"    // 文件未通过安全扫描，已被删除
+	FileStatusUnscannable = "unscannable" // 文件无法完成安全扫描，不能下载
+)
+
 type Message struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	UserID      uint      `json:"user_id"`
-	Username    string    `json:"username"`
-	Content     string    `json:"content"`
-	MessageType string    `json:"message_type" gorm:"default:'text'"` // 消息类型: text, image, file
-	FileURL     string    `json:"file_url"`                           // 文件URL
-	FileName    string    `json:"file_name"`                          // 文件名
-	FileSize    int64     `json:"file_size"`                          // 文件大小
-	GroupID     uint      `json:"group_id" gorm:"index"`              // 群组ID，0表示私聊或全局聊天
-	CreatedAt   time.Time `json:"created_at"`
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	UserID      uint         `json:"user_id"`
+	Username    string       `json:"username"`
+	Content     string       `json:"content"`
+	MessageType string       `json:"message_type" gorm:"default:'text'"`                      // 消息类型: text, image, file, voice
+	FileURL     string       `json:"file_url"`                                                // 文件URL
+	FileName    string       `json:"file_name"`                                               // 文件名
+	FileSize    int64        `json:"file_size"`                                               // 文件大小
+	ImageWidth  int          `json:"image_width,omitempty"`                                   // 图片宽度
+	ImageHeight int          `json:"image_height,omitempty"`                                  // 图片高度
+	Blurhash    string       `json:"blurhash,omitempty" gorm:"size:64"`                       // 图片加载前显示的占位图
+	Thumbnails  []Thumbnail  `json:"thumbnails,omitempty" gorm:"serializer:json;type:text"`   // 图片缩略图
+	DurationMs  int          `json:"duration_ms,omitempty"`                                   // 语音时长（毫秒）
+	Waveform    []int        `json:"waveform,omitempty" gorm:"serializer:json;type:text"`     // 语音波形，0-100 的相对音量
+	FileStatus  string       `json:"file_status,omitempty" gorm:"size:16"`                    // 文件状态: scanning, infected, unscannable，为空表示正常
+	LinkPreview *LinkPreview `json:"link_preview,omitempty" gorm:"serializer:json;type:text"` // 消息中第一个链接的预览，发送后异步抓取
+	GroupID     uint         `json:"group_id" gorm:"index"`                                   // 群组ID，0表示私聊或全局聊天
+	ChannelID   uint         `json:"channel_id" gorm:"default:0"`                             // 群组内的频道ID，0表示默认频道
+	TargetID    uint         `json:"target_id" gorm:"index"`                                  // 私聊目标用户ID，0表示群聊或全局聊天
+	ArchivedAt  *time.Time   `json:"archived_at,omitempty" gorm:"index"`                      // 归档时间（所属群组解散时设置），已归档的消息不出现在历史和搜索中
+	ExpireMode  string       `json:"expire_mode,omitempty" gorm:"size:16"`                    // 阅后即焚计时方式: timer, after_read，为空表示普通消息
+	ExpireTTL   int          `json:"expire_ttl,omitempty"`                                    // 阅后即焚存活时间（秒）
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty" gorm:"index"`                       // 销毁时间，after_read 消息在被阅读前为空
+	CreatedAt   time.Time    `json:"created_at"`
 }
 
 // 添加TableName方法指定表名（可选）
@@ -36,5 +57,18 @@ func CreateMessageIndexes() {
 	DB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_group_id ON messages(group_id)")
 	// 创建复合索引，提高按群组和时间查询的性能
 	DB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_group_created ON messages(group_id, created_at)")
+	// 创建复合索引，提高私聊会话查询的性能
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_user_target ON messages(user_id, target_id)")
+	// 为文件地址创建前缀索引，用于统计上传文件的引用
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_file_url ON messages(file_url(191))")
+	// 创建全文索引（ngram 分词支持中文），用于消息搜索。MySQL 不支持 IF NOT EXISTS，先检查是否已创建
+	var fulltext int64
+	DB.Raw(`SELECT COUNT(*) FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name = 'messages' AND index_name = 'idx_messages_content_ft'`).Scan(&fulltext)
+	if fulltext == 0 {
+		if err := DB.Exec("CREATE FULLTEXT INDEX idx_messages_content_ft ON messages(content) WITH PARSER ngram").Error; err != nil {
+			fmt.Printf("⚠️ 创建消息全文索引失败，消息搜索将无法使用: %v\n", err)
+		}
+	}
 	fmt.Println("✅ 消息表索引创建完成")
 }
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// 群组权限
+const (
+	PermSend               = "send"                // 发送消息
+	PermSendFiles          = "send_files"          // 发送图片和文件
+	PermInvite             = "invite"              // 直接邀请用户入群
+	PermManageInvites      = "manage_invites"      // 管理邀请链接、审批入群申请
+	PermKick               = "kick"                // 移出、封禁成员
+	PermMute               = "mute"                // 禁言成员、设置慢速模式（拥有该权限的成员不受慢速模式限制）
+	PermPin                = "pin"                 // 置顶消息、发布公告
+	PermEditInfo           = "edit_info"           // 修改群名称、头像、描述等信息
+	PermManageRoles        = "manage_roles"        // 管理自定义角色、修改成员角色
+	PermViewAuditLog       = "view_audit_log"      // 查看群组审计日志
+	PermManageChannel      = "manage_channel"      // 创建、修改、删除频道，管理并访问所有私有频道
+	PermManageDisappearing = "manage_disappearing" // 开启、修改、关闭群组的阅后即焚（内置角色中仅群主拥有）
+	PermDissolve           = "dissolve"            // 解散群组（仅群主）
+	PermTransferOwner      = "transfer_owner"      // 转让群主（仅群主）
+)
+
+// 内置角色
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// 内置角色等级，等级高的成员才能管理等级低的成员
+const (
+	RoleLevelOwner  = 100
+	RoleLevelAdmin  = 50
+	RoleLevelMember = 0
+)
+
+// AllPermissions 所有可分配给自定义角色的权限
+var AllPermissions = []string{
+	PermSend, PermSendFiles, PermInvite, PermManageInvites, PermKick,
+	PermMute, PermPin, PermEditInfo, PermManageRoles, PermViewAuditLog, PermManageChannel,
+	PermManageDisappearing,
+}
+
+// ownerOnlyPermissions 仅群主拥有、不可分配给其他角色的权限
+var ownerOnlyPermissions = []string{PermDissolve, PermTransferOwner}
+
+// 内置角色的权限集合
+var builtinRolePermissions = map[string][]string{
+	RoleOwner:  append(append([]string{}, AllPermissions...), ownerOnlyPermissions...),
+	RoleAdmin:  {PermSend, PermSendFiles, PermInvite, PermManageInvites, PermKick, PermMute, PermPin, PermEditInfo, PermViewAuditLog, PermManageChannel},
+	RoleMember: {PermSend, PermSendFiles, PermInvite},
+}
+
+var builtinRoleLevels = map[string]int{
+	RoleOwner:  RoleLevelOwner,
+	RoleAdmin:  RoleLevelAdmin,
+	RoleMember: RoleLevelMember,
+}
+
+// GroupRole 群组自定义角色模型
+type GroupRole struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	GroupID     uint      `json:"group_id" gorm:"not null;uniqueIndex:idx_group_roles_group_name"`     // 群组ID
+	Name        string    `json:"name" gorm:"size:32;not null;uniqueIndex:idx_group_roles_group_name"` // 角色名
+	Level       int       `json:"level" gorm:"not null;default:1"`                                     // 角色等级（1-99）
+	Permissions string    `json:"-" gorm:"type:text"`                                                  // 权限列表，逗号分隔
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定自定义角色表名
+func (GroupRole) TableName() string {
+	return "group_roles"
+}
+
+// PermissionList 获取角色的权限列表
+func (r *GroupRole) PermissionList() []string {
+	if r.Permissions == "" {
+		return []string{}
+	}
+	return strings.Split(r.Permissions, ",")
+}
+
+// SetPermissions 设置角色的权限列表（去重并排序）
+func (r *GroupRole) SetPermissions(perms []string) {
+	set := make(map[string]bool, len(perms))
+	list := make([]string, 0, len(perms))
+	for _, p := range perms {
+		if !set[p] {
+			set[p] = true
+			list = append(list, p)
+		}
+	}
+	sort.Strings(list)
+	r.Permissions = strings.Join(list, ",")
+}
+
+// RoleInfo 解析后的角色信息
+type RoleInfo struct {
+	Name        string          `json:"name"`
+	Level       int             `json:"level"`
+	Builtin     bool            `json:"builtin"`
+	Permissions map[string]bool `json:"-"`
+}
+
+// Has 判断角色是否拥有指定权限
+func (r *RoleInfo) Has(perm string) bool {
+	return r.Permissions[perm]
+}
+
+// PermissionList 获取角色的权限列表（按名称排序）
+func (r *RoleInfo) PermissionList() []string {
+	list := make([]string, 0, len(r.Permissions))
+	for p := range r.Permissions {
+		list = append(list, p)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// IsBuiltinRole 判断是否为内置角色
+func IsBuiltinRole(name string) bool {
+	_, ok := builtinRoleLevels[name]
+	return ok
+}
+
+// IsAssignablePermission 判断权限是否可以分配给自定义角色
+func IsAssignablePermission(perm string) bool {
+	for _, p := range AllPermissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// BuiltinRoleInfo 获取内置角色信息
+func BuiltinRoleInfo(name string) *RoleInfo {
+	perms := make(map[string]bool)
+	for _, p := range builtinRolePermissions[name] {
+		perms[p] = true
+	}
+	return &RoleInfo{Name: name, Level: builtinRoleLevels[name], Builtin: true, Permissions: perms}
+}
+
+// ResolveRole 解析群组中的角色：内置角色直接返回，自定义角色从数据库读取。
+// 找不到的自定义角色（例如已被删除）按普通成员处理
+func ResolveRole(groupID uint, name string) *RoleInfo {
+	if IsBuiltinRole(name) {
+		return BuiltinRoleInfo(name)
+	}
+
+	var role GroupRole
+	if err := DB.Where("group_id = ? AND name = ?", groupID, name).First(&role).Error; err != nil {
+		info := BuiltinRoleInfo(RoleMember)
+		info.Name = name
+		info.Builtin = false
+		return info
+	}
+
+	perms := make(map[string]bool)
+	for _, p := range role.PermissionList() {
+		perms[p] = true
+	}
+	return &RoleInfo{Name: role.Name, Level: role.Level, Permissions: perms}
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// PinnedMessage 群组置顶消息模型
+type PinnedMessage struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	GroupID   uint      `json:"group_id" gorm:"not null;uniqueIndex:idx_pinned_messages_group_message"`   // 群组ID
+	MessageID uint      `json:"message_id" gorm:"not null;uniqueIndex:idx_pinned_messages_group_message"` // 被置顶的消息ID
+	PinnedBy  uint      `json:"pinned_by" gorm:"not null"`                                                // 置顶操作者ID
+	CreatedAt time.Time `json:"created_at"`
+
+	// 关联关系
+	Message Message `json:"message" gorm:"foreignKey:MessageID"` // 消息内容
+}
+
+// GroupAnnouncement 群公告模型，成员需要确认已读
+type GroupAnnouncement struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	GroupID   uint      `json:"group_id" gorm:"not null;index"` // 群组ID
+	AuthorID  uint      `json:"author_id" gorm:"not null"`      // 发布者ID
+	Content   string    `json:"content" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// 关联关系
+	Author User `json:"author" gorm:"foreignKey:AuthorID"` // 发布者信息
+}
+
+// AnnouncementAck 群公告确认记录
+type AnnouncementAck struct {
+	AnnouncementID uint      `json:"announcement_id" gorm:"primaryKey"` // 公告ID
+	UserID         uint      `json:"user_id" gorm:"primaryKey"`         // 确认的用户ID
+	AckedAt        time.Time `json:"acked_at" gorm:"autoCreateTime"`    // 确认时间
+}
+
+// TableName 指定置顶消息表名
+func (PinnedMessage) TableName() string {
+	return "pinned_messages"
+}
+
+// TableName 指定群公告表名
+func (GroupAnnouncement) TableName() string {
+	return "group_announcements"
+}
+
+// TableName 指定群公告确认表名
+func (AnnouncementAck) TableName() string {
+	return "announcement_acks"
+}
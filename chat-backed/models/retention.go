@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// 过期消息的处理方式
+const (
+	RetentionActionDelete  = "delete"  // 直接删除
+	RetentionActionArchive = "archive" // 导出到归档文件后删除
+)
+
+// RetentionPolicy 消息保留策略。MaxAgeDays 和 MaxMessages 为 0 表示该维度不限制
+type RetentionPolicy struct {
+	MaxAgeDays  int    `json:"max_age_days"` // 删除早于 N 天的消息
+	MaxMessages int    `json:"max_messages"` // 仅保留最近 N 条消息
+	Action      string `json:"action"`       // 过期消息的处理方式: delete, archive
+}
+
+// Enabled 判断策略是否需要执行
+func (p *RetentionPolicy) Enabled() bool {
+	return p.MaxAgeDays > 0 || p.MaxMessages > 0
+}
+
+// GroupRetentionPolicy 群组级消息保留策略，存在时覆盖全局策略
+type GroupRetentionPolicy struct {
+	GroupID     uint      `json:"group_id" gorm:"primaryKey;autoIncrement:false"`  // 群组ID
+	MaxAgeDays  int       `json:"max_age_days" gorm:"not null;default:0"`          // 删除早于 N 天的消息，0表示不限制
+	MaxMessages int       `json:"max_messages" gorm:"not null;default:0"`          // 仅保留最近 N 条消息，0表示不限制
+	Action      string    `json:"action" gorm:"size:16;not null;default:'delete'"` // 过期消息的处理方式: delete, archive
+	UpdatedBy   uint      `json:"updated_by"`                                      // 最后修改者ID
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定群组保留策略表名
+func (GroupRetentionPolicy) TableName() string {
+	return "group_retention_policies"
+}
+
+// Policy 转换为通用的保留策略
+func (p *GroupRetentionPolicy) Policy() RetentionPolicy {
+	return RetentionPolicy{MaxAgeDays: p.MaxAgeDays, MaxMessages: p.MaxMessages, Action: p.Action}
+}
+
+// IsValidRetentionAction 校验过期消息处理方式
+func IsValidRetentionAction(action string) bool {
+	return action == RetentionActionDelete || action == RetentionActionArchive
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// 定时消息状态
+const (
+	ScheduledPending   = "pending"   // 等待发送
+	ScheduledSent      = "sent"      // 已发送
+	ScheduledFailed    = "failed"    // 发送失败（如发送时已不是群成员或被禁言）
+	ScheduledCancelled = "cancelled" // 已取消
+)
+
+// ScheduledMessage 定时消息模型，到达发送时间后由调度器通过与实时消息相同的流程发出
+type ScheduledMessage struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`                    // 发送者ID
+	Content     string     `json:"content" gorm:"type:text"`                         // 消息内容
+	MessageType string     `json:"message_type" gorm:"size:16;default:'text'"`       // 消息类型: text, image, file, voice
+	FileURL     string     `json:"file_url"`                                         // 文件URL
+	FileName    string     `json:"file_name"`                                        // 文件名
+	FileSize    int64      `json:"file_size"`                                        // 文件大小
+	GroupID     uint       `json:"group_id" gorm:"default:0"`                        // 群组ID，0表示私聊
+	ChannelID   uint       `json:"channel_id" gorm:"default:0"`                      // 群组内的频道ID，0表示默认频道
+	TargetID    uint       `json:"target_id" gorm:"default:0"`                       // 私聊目标用户ID，0表示群聊
+	ExpireMode  string     `json:"expire_mode,omitempty" gorm:"size:16"`             // 阅后即焚计时方式，为空时使用会话设置
+	ExpireTTL   int        `json:"expire_ttl,omitempty"`                             // 阅后即焚存活时间（秒）
+	SendAt      time.Time  `json:"send_at" gorm:"not null"`                          // 计划发送时间
+	Status      string     `json:"status" gorm:"size:16;not null;default:'pending'"` // 状态: pending, sent, failed, cancelled
+	MessageID   uint       `json:"message_id,omitempty"`                             // 发送后生成的消息ID
+	Error       string     `json:"error,omitempty" gorm:"size:255"`                  // 发送失败原因
+	Attempts    int        `json:"attempts,omitempty" gorm:"default:0"`              // 因临时错误发送失败的次数
+	SentAt      *time.Time `json:"sent_at,omitempty"`                                // 实际发送时间
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定定时消息表名
+func (ScheduledMessage) TableName() string {
+	return "scheduled_messages"
+}
+
+// CreateScheduledIndexes 创建定时消息相关索引
+func CreateScheduledIndexes() {
+	// 调度器按状态和发送时间查询到期消息
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_scheduled_messages_status_send_at ON scheduled_messages(status, send_at)")
+}
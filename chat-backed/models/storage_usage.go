@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 存储用量的归属类型
+const (
+	StorageOwnerUser  = "user"  // 用户上传的文件（包括头像）
+	StorageOwnerGroup = "group" // 发送到群组中的文件
+)
+
+// StorageUsage 用户或群组的存储用量和配额，上传、发送和删除附件时更新
+type StorageUsage struct {
+	ID         uint      `json:"-" gorm:"primaryKey"`
+	OwnerType  string    `json:"owner_type" gorm:"size:16;not null;uniqueIndex:idx_storage_usage_owner"` // 归属类型: user, group
+	OwnerID    uint      `json:"owner_id" gorm:"not null;uniqueIndex:idx_storage_usage_owner"`           // 用户ID或群组ID
+	UsedBytes  int64     `json:"used_bytes" gorm:"not null;default:0"`                                   // 已使用的字节数
+	FileCount  int64     `json:"file_count" gorm:"not null;default:0"`                                   // 文件数量
+	QuotaBytes *int64    `json:"quota_bytes"`                                                            // 管理员设置的配额，为空时使用默认配额，0 表示不限制
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName 指定存储用量表名
+func (StorageUsage) TableName() string {
+	return "storage_usages"
+}
+
+// AddStorageUsage 增减用户或群组的存储用量，bytes 和 files 为负数时表示删除
+func AddStorageUsage(tx *gorm.DB, ownerType string, ownerID uint, bytes, files int64) error {
+	usage := StorageUsage{OwnerType: ownerType, OwnerID: ownerID, UsedBytes: max(bytes, 0), FileCount: max(files, 0)}
+	return tx.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"used_bytes": gorm.Expr("GREATEST(used_bytes + ?, 0)", bytes),
+			"file_count": gorm.Expr("GREATEST(file_count + ?, 0)", files),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&usage).Error
+}
+
+// ErrStorageQuotaExceeded 存入文件后会超过配额
+var ErrStorageQuotaExceeded = errors.New("存储空间不足")
+
+// ReserveStorageUsage 在不超过配额的前提下增加用户或群组的存储用量，检查和累加在同一条语句中完成，
+// 并发的上传不会一起超过配额。defaultQuota 为未单独设置配额时的默认配额，配额为 0 表示不限制
+func ReserveStorageUsage(tx *gorm.DB, ownerType string, ownerID uint, bytes, files, defaultQuota int64) error {
+	// 确保记录存在，条件更新才能命中
+	if err := AddStorageUsage(tx, ownerType, ownerID, 0, 0); err != nil {
+		return err
+	}
+	result := tx.Model(&StorageUsage{}).
+		Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+		Where("COALESCE(quota_bytes, ?) = 0 OR used_bytes + ? <= COALESCE(quota_bytes, ?)", defaultQuota, bytes, defaultQuota).
+		Updates(map[string]interface{}{
+			"used_bytes": gorm.Expr("used_bytes + ?", bytes),
+			"file_count": gorm.Expr("file_count + ?", files),
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStorageQuotaExceeded
+	}
+	return nil
+}
+
+// BackfillStorageUsage 首次启用用量统计时，根据已有的附件记录计算用户和群组的用量
+func BackfillStorageUsage() {
+	now := time.Now()
+	var count int64
+	DB.Model(&StorageUsage{}).Count(&count)
+	if count > 0 {
+		return
+	}
+	DB.Exec(`INSERT INTO storage_usages (owner_type, owner_id, used_bytes, file_count, updated_at)
+		SELECT ?, uploader_id, SUM(size), COUNT(*), ? FROM attachments
+		WHERE kind <> ? GROUP BY uploader_id`, StorageOwnerUser, now, AttachmentKindThumbnail)
+	DB.Exec(`INSERT INTO storage_usages (owner_type, owner_id, used_bytes, file_count, updated_at)
+		SELECT ?, group_id, SUM(size), COUNT(*), ? FROM attachments
+		WHERE kind = ? AND message_id > 0 AND group_id > 0 GROUP BY group_id`, StorageOwnerGroup, now, AttachmentKindFile)
+	fmt.Println("✅ 存储用量统计完成")
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// 断点续传会话状态
+const (
+	UploadSessionActive     = "uploading"  // 正在上传分片
+	UploadSessionCompleting = "completing" // 正在校验并合并为附件，不能再上传分片或重复完成
+	UploadSessionCompleted  = "completed"  // 已合并为附件
+)
+
+// UploadSession 断点续传会话：客户端按偏移量依次上传分片，中断后可查询已接收的字节数继续上传，
+// 全部上传后校验整个文件的 SHA-256 并生成与普通上传相同的附件
+type UploadSession struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`                      // 上传者ID
+	FileName    string     `json:"file_name" gorm:"size:255;not null"`                 // 原始文件名
+	Size        int64      `json:"size" gorm:"not null"`                               // 文件总大小
+	Offset      int64      `json:"offset" gorm:"column:upload_offset;not null"`        // 已接收的字节数
+	Checksum    string     `json:"checksum" gorm:"size:64;not null"`                   // 整个文件的 SHA-256（十六进制）
+	Status      string     `json:"status" gorm:"size:16;not null;default:'uploading'"` // 状态: uploading, completing, completed
+	FileURL     string     `json:"file_url,omitempty" gorm:"size:255"`                 // 完成后的文件地址
+	TempPath    string     `json:"-" gorm:"size:255"`                                  // 未完成时分片写入的临时文件
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"index"`                            // 过期时间，每次收到分片后顺延
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定断点续传会话表名
+func (UploadSession) TableName() string {
+	return "upload_sessions"
+}
@@ -75,7 +75,7 @@ func InitDB() {
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
 	// 自动迁移模式
-	DB.AutoMigrate(&User{}, &Message{}, &Friendship{}, &Group{}, &GroupMember{})
+	DB.AutoMigrate(&User{}, &Message{}, &Friendship{}, &Group{}, &GroupMember{}, &GroupJoinRequest{}, &GroupInvite{}, &GroupBan{}, &GroupRole{}, &GroupAuditLog{}, &PinnedMessage{}, &GroupAnnouncement{}, &AnnouncementAck{}, &GroupChannel{}, &GroupChannelMember{}, &GroupRetentionPolicy{}, &DisappearingSetting{}, &ScheduledMessage{}, &ExportJob{}, &ImportMapping{}, &Attachment{}, &UploadSession{}, &Blob{}, &StorageUsage{}, &LinkPreviewCache{})
 
 	// 创建消息表索引
 	CreateMessageIndexes()
@@ -83,6 +83,21 @@ func InitDB() {
 	// 创建群组相关索引
 	CreateGroupIndexes()
 
+	// 创建审计日志索引
+	CreateAuditIndexes()
+
+	// 创建频道相关索引
+	CreateChannelIndexes()
+
+	// 创建定时消息索引
+	CreateScheduledIndexes()
+
+	// 为已有的上传文件补充附件记录
+	BackfillAttachments()
+
+	// 根据已有的附件统计存储用量
+	BackfillStorageUsage()
+
 	// 创建好友关系表索引
 	DB.Exec("CREATE INDEX IF NOT EXISTS idx_friendships_user_friend ON friendships(user_id, friend_id)")
 	DB.Exec("CREATE INDEX IF NOT EXISTS idx_friendships_status ON friendships(status)")
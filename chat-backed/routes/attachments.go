@@ -0,0 +1,244 @@
+package routes
+
+import (
+	"errors"
+	"go-chat/models"
+	"go-chat/storage"
+	"go-chat/utils"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 签名下载地址的有效期
+const signedFileURLTTL = 10 * time.Minute
+
+// errAttachmentTaken 附件已被其他消息使用
+var errAttachmentTaken = errors.New("附件已在其他消息中发送")
+
+// saveMessageError 将 saveMessage 的错误转换为返回给客户端的错误，数据库等临时错误为 500
+func saveMessageError(err error) *groupError {
+	var gerr *groupError
+	if errors.As(err, &gerr) {
+		return gerr
+	}
+	if errors.Is(err, errAttachmentTaken) {
+		return newGroupError(http.StatusConflict, "附件已在其他消息中发送，请重新上传")
+	}
+	return newGroupError(http.StatusInternalServerError, "保存消息失败")
+}
+
+// fileRequestUserID 识别文件下载请求的用户：签名地址（uid/expires/sig 参数）或认证令牌
+// （Authorization 请求头或 token 参数，便于 <img> 标签直接引用）
+func fileRequestUserID(c *gin.Context) (uint, bool) {
+	if sig := c.Query("sig"); sig != "" {
+		return utils.VerifyFileSignature(c.Request.URL.Path, c.Query("uid"), c.Query("expires"), sig)
+	}
+
+	token := c.Query("token")
+	if header := c.GetHeader("Authorization"); header != "" {
+		token = strings.TrimPrefix(header, "Bearer ")
+	}
+	if token == "" {
+		return 0, false
+	}
+	claims, err := utils.ParseJWT(token)
+	if err != nil {
+		return 0, false
+	}
+	return claims.UserID, true
+}
+
+// findAttachment 按访问地址查询附件记录
+func findAttachment(fileURL string) (*models.Attachment, error) {
+	var attachment models.Attachment
+	if err := models.DB.Where("file_url = ?", fileURL).First(&attachment).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// canAccessAttachment 校验用户能否下载附件：缩略图跟随原图；上传者本人；头像对所有登录用户可见；
+// 已发送的附件按所在会话校验（群聊需能访问所在频道，私聊仅限双方，全局聊天所有登录用户）
+func canAccessAttachment(userID uint, attachment *models.Attachment) bool {
+	if attachment.Kind == models.AttachmentKindThumbnail {
+		var parent models.Attachment
+		if err := models.DB.First(&parent, attachment.ParentID).Error; err != nil {
+			return false
+		}
+		return canAccessAttachment(userID, &parent)
+	}
+	if attachment.UploaderID == userID || attachment.Kind == models.AttachmentKindAvatar {
+		return true
+	}
+	if attachment.MessageID == 0 {
+		return false
+	}
+	if attachment.GroupID > 0 {
+		_, _, _, gerr := authorizeChannel(userID, attachment.GroupID, attachment.ChannelID, "")
+		return gerr == nil
+	}
+	if attachment.TargetID > 0 {
+		return attachment.TargetID == userID
+	}
+	return true
+}
+
+// serveAttachment 校验请求者身份和访问权限后返回文件，安全扫描通过前的文件处于隔离状态不能下载。
+// 存储后端支持预签名时重定向到限时下载地址，
+// 否则由本服务读取并返回文件内容
+func serveAttachment(c *gin.Context, fileURL string) {
+	userID, ok := fileRequestUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "需要登录或有效的签名链接"})
+		return
+	}
+
+	attachment, err := findAttachment(fileURL)
+	if err != nil || !canAccessAttachment(userID, attachment) {
+		// 无权访问时同样返回不存在，避免泄露文件是否存在
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	if attachment.ScanStatus == models.ScanPending {
+		c.JSON(http.StatusLocked, gin.H{"error": "文件正在进行安全扫描，请稍后再试", "scan_status": attachment.ScanStatus})
+		return
+	}
+	if attachment.ScanStatus == models.ScanUnscannable {
+		c.JSON(http.StatusForbidden, gin.H{"error": "文件无法完成安全扫描，不能下载", "scan_status": attachment.ScanStatus})
+		return
+	}
+	key, _ := attachmentStorageKey(attachment)
+
+	if attachment.ContentType == "" {
+		// 旧附件没有记录类型，识别一次后保存
+		attachment.ContentType = sniffStoredContentType(fileURL)
+		models.DB.Model(attachment).Update("content_type", attachment.ContentType)
+	}
+
+	if presigner, ok := fileStorage.(storage.Presigner); ok {
+		contentType, disposition := safeFileHeaders(attachment.ContentType, attachment.OriginalName)
+		url, err := presigner.PresignGet(key, signedFileURLTTL, storage.GetOptions{
+			ContentType:        contentType,
+			ContentDisposition: disposition,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取文件失败"})
+			return
+		}
+		c.Redirect(http.StatusFound, url)
+		return
+	}
+
+	rc, err := fileStorage.Open(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取文件失败"})
+		}
+		return
+	}
+	defer rc.Close()
+
+	setSafeFileHeaders(c, attachment.ContentType, attachment.OriginalName)
+	if rs, ok := rc.(io.ReadSeeker); ok {
+		// 支持断点续传和条件请求
+		http.ServeContent(c.Writer, c.Request, "", attachment.CreatedAt, rs)
+		return
+	}
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, rc)
+}
+
+// ServeAvatar 返回用户头像（需要登录或签名链接）
+func ServeAvatar(c *gin.Context) {
+	serveAttachment(c, "/uploads/avatars/"+filepath.Base(c.Param("filename")))
+}
+
+// ServeThumbnail 返回图片缩略图（访问权限与原图相同）
+func ServeThumbnail(c *gin.Context) {
+	serveAttachment(c, "/uploads/thumbs/"+filepath.Base(c.Param("filename")))
+}
+
+// GetSignedFileURL 为有权访问的上传文件生成短期有效的签名下载地址，可分享给无法携带令牌的客户端
+func GetSignedFileURL(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	fileURL := c.Query("file_url")
+
+	attachment, err := findAttachment(fileURL)
+	if err != nil || !canAccessAttachment(userID, attachment) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+
+	url, expiresAt := utils.SignFileURL(attachment.FileURL, userID, signedFileURLTTL)
+	c.JSON(http.StatusOK, gin.H{
+		"url":        url,
+		"expires_at": expiresAt,
+	})
+}
+
+// checkMessageAttachment 校验消息引用的上传文件：必须是发送者本人上传且尚未在其他消息中发送。
+// 不是本地上传的文件返回 nil
+func checkMessageAttachment(userID uint, fileURL string) (*models.Attachment, *groupError) {
+	if !strings.HasPrefix(fileURL, "/uploads/") {
+		return nil, nil
+	}
+	attachment, err := findAttachment(fileURL)
+	if err != nil || attachment.Kind != models.AttachmentKindFile || attachment.UploaderID != userID {
+		return nil, newGroupError(http.StatusBadRequest, "无效的附件，请重新上传")
+	}
+	if attachment.MessageID != 0 {
+		return nil, newGroupError(http.StatusConflict, "附件已在其他消息中发送，请重新上传")
+	}
+	return attachment, nil
+}
+
+// saveMessage 保存消息，并将引用的上传文件关联到消息所在的会话
+func saveMessage(tx *gorm.DB, message *models.Message) error {
+	if err := tx.Create(message).Error; err != nil {
+		return err
+	}
+	if !strings.HasPrefix(message.FileURL, "/uploads/") {
+		return nil
+	}
+
+	result := tx.Model(&models.Attachment{}).
+		Where("file_url = ? AND uploader_id = ? AND message_id = 0", message.FileURL, message.UserID).
+		Updates(map[string]interface{}{
+			"message_id": message.ID,
+			"group_id":   message.GroupID,
+			"channel_id": message.ChannelID,
+			"target_id":  message.TargetID,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errAttachmentTaken
+	}
+
+	var attachment models.Attachment
+	if err := tx.Select("size, scan_status").Where("file_url = ?", message.FileURL).First(&attachment).Error; err != nil {
+		return err
+	}
+	// 准备消息之后扫描可能已经完成
+	if status := fileStatusForScan(attachment.ScanStatus); message.FileStatus == models.FileStatusScanning && status != message.FileStatus {
+		message.FileStatus = status
+		if err := tx.Model(message).Update("file_status", status).Error; err != nil {
+			return err
+		}
+	}
+
+	// 发送到群组的文件计入群组的存储用量
+	if message.GroupID > 0 {
+		return reserveStorage(tx, models.StorageOwnerGroup, message.GroupID, attachment.Size)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"bytes"
+	"go-chat/models"
+	"go-chat/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestServeAttachmentSignedURL(t *testing.T) {
+	setupTestDB(t)
+	hash, size, err := models.PutBlob(fileStorage, bytes.NewReader([]byte("secret report")), "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 用户 1 私聊发给用户 2 的文件
+	attachment := &models.Attachment{
+		FileURL: "/uploads/1_1700000000.txt", Size: size, ContentType: "text/plain", BlobHash: hash,
+		Kind: models.AttachmentKindFile, UploaderID: 1, MessageID: 9, TargetID: 2,
+	}
+	if err := models.DB.Create(attachment).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(rawURL string) *httptest.ResponseRecorder {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", rawURL, nil)
+		serveAttachment(c, attachment.FileURL)
+		return w
+	}
+
+	signed, _ := utils.SignFileURL(attachment.FileURL, 2, time.Minute)
+	if w := serve(signed); w.Code != http.StatusOK || w.Body.String() != "secret report" {
+		t.Fatalf("私聊对象的签名地址: status = %d, body = %q", w.Code, w.Body)
+	}
+
+	// 签名绑定用户：改写 uid 后签名失效
+	if w := serve(strings.Replace(signed, "uid=2", "uid=3", 1)); w.Code != http.StatusUnauthorized {
+		t.Errorf("改写 uid: status = %d, want 401", w.Code)
+	}
+	// 签名有效但绑定的用户无权访问该文件
+	other, _ := utils.SignFileURL(attachment.FileURL, 3, time.Minute)
+	if w := serve(other); w.Code != http.StatusNotFound {
+		t.Errorf("无权访问的用户: status = %d, want 404", w.Code)
+	}
+	// 签名只对签发时的路径有效
+	thumb, _ := utils.SignFileURL("/uploads/thumbs/1_1700000000_small.jpg", 2, time.Minute)
+	_, query, _ := strings.Cut(thumb, "?")
+	if w := serve(attachment.FileURL + "?" + query); w.Code != http.StatusUnauthorized {
+		t.Errorf("其他路径的签名: status = %d, want 401", w.Code)
+	}
+	expired, _ := utils.SignFileURL(attachment.FileURL, 2, -time.Second)
+	if w := serve(expired); w.Code != http.StatusUnauthorized {
+		t.Errorf("过期的签名地址: status = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"errors"
+	"go-chat/audio"
+	"go-chat/models"
+	"log"
+	"os"
+	"strings"
+)
+
+// processUploadedAudio 读取暂存音频的时长和波形，用于语音消息。
+// 无法识别的音频（如 MP3）仍可作为普通文件发送，只是不能作为语音消息
+func processUploadedAudio(stagedPath, contentType string, attachment *models.Attachment) {
+	if !strings.HasPrefix(contentType, "audio/") && contentType != "application/ogg" {
+		return
+	}
+	f, err := os.Open(stagedPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	info, err := audio.Analyze(f)
+	if err != nil {
+		if !errors.Is(err, audio.ErrUnsupported) {
+			log.Printf("读取音频 %s 失败: %v", attachment.OriginalName, err)
+		}
+		return
+	}
+	attachment.DurationMs = info.DurationMs
+	attachment.Waveform = info.Waveform
+}
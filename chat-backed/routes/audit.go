@@ -0,0 +1,138 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-chat/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recordGroupAudit 追加一条群组审计日志。before、after 为操作前后的值，序列化为JSON保存，为 nil 时留空。
+// 在事务中调用时传入事务对象，使日志与操作一同提交或回滚
+func recordGroupAudit(db *gorm.DB, groupID, actorID uint, action string, targetID uint, before, after interface{}) {
+	entry := models.GroupAuditLog{
+		GroupID:  groupID,
+		ActorID:  actorID,
+		Action:   action,
+		TargetID: targetID,
+		Before:   auditValue(before),
+		After:    auditValue(after),
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		fmt.Printf("记录群组审计日志失败: %v\n", err)
+	}
+}
+
+// auditValue 将审计值序列化为JSON字符串
+func auditValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// getGroupAuditLog 获取群组审计日志（需要查看审计日志权限）
+// 支持按操作类型、操作者、操作对象和时间范围过滤，按时间倒序分页返回
+func getGroupAuditLog(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermViewAuditLog); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "50"))
+	if err != nil || pageSize < 1 {
+		pageSize = 50
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	query := models.DB.Model(&models.GroupAuditLog{}).Where("group_id = ?", uint(groupID))
+
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if actorStr := c.Query("actor_id"); actorStr != "" {
+		actorID, err := strconv.ParseUint(actorStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的操作者ID"})
+			return
+		}
+		query = query.Where("actor_id = ?", uint(actorID))
+	}
+	if targetStr := c.Query("target_id"); targetStr != "" {
+		targetID, err := strconv.ParseUint(targetStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的操作对象ID"})
+			return
+		}
+		query = query.Where("target_id = ?", uint(targetID))
+	}
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := parseSearchTime(fromStr, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的开始时间"})
+			return
+		}
+		query = query.Where("created_at >= ?", from)
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := parseSearchTime(toStr, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的结束时间"})
+			return
+		}
+		query = query.Where("created_at < ?", to)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取审计日志失败"})
+		return
+	}
+
+	var logs []models.GroupAuditLog
+	if err := query.Preload("Actor", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, username, avatar")
+	}).Order("created_at desc, id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取审计日志失败"})
+		return
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs": logs,
+		"pagination": gin.H{
+			"page":       page,
+			"pageSize":   pageSize,
+			"total":      total,
+			"totalPages": totalPages,
+			"hasNext":    page < totalPages,
+			"hasPrev":    page > 1,
+		},
+	})
+}
@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"go-chat/models"
+	"go-chat/storage"
+	"io"
+	"testing"
+)
+
+// stallingStorage 第一次写入时阻塞，直到 release 关闭后以失败返回
+type stallingStorage struct {
+	storage.Storage
+	started chan struct{}
+	release chan struct{}
+	calls   int
+}
+
+func (s *stallingStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	s.calls++
+	if s.calls == 1 {
+		close(s.started)
+		<-s.release
+		return errors.New("存储服务不可用")
+	}
+	return s.Storage.Put(key, r, size, contentType)
+}
+
+// 先上传的请求写入失败时，同时上传相同内容的请求不能引用不存在的内容
+func TestPutBlobConcurrentFirstWriterFails(t *testing.T) {
+	setupTestDB(t)
+	s := &stallingStorage{Storage: fileStorage, started: make(chan struct{}), release: make(chan struct{})}
+	content := []byte("same content")
+
+	firstErr := make(chan error, 1)
+	go func() {
+		_, _, err := models.PutBlob(s, bytes.NewReader(content), "text/plain")
+		firstErr <- err
+	}()
+	<-s.started
+
+	hash, _, err := models.PutBlob(s, bytes.NewReader(content), "text/plain")
+	if err != nil {
+		t.Fatalf("second PutBlob: %v", err)
+	}
+	close(s.release)
+	if err := <-firstErr; err == nil {
+		t.Fatal("first PutBlob succeeded, want error")
+	}
+
+	rc, err := fileStorage.Open(models.BlobKey(hash))
+	if err != nil {
+		t.Fatalf("内容未写入: %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if !bytes.Equal(got, content) {
+		t.Errorf("content = %q, want %q", got, content)
+	}
+
+	var blob models.Blob
+	models.DB.First(&blob, "hash = ?", hash)
+	if blob.RefCount != 1 || blob.Pending {
+		t.Errorf("blob ref_count = %d pending = %v, want 1 false", blob.RefCount, blob.Pending)
+	}
+
+	// 内容写入完成后，相同内容的上传不再重复写入
+	if _, _, err := models.PutBlob(s, bytes.NewReader(content), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if s.calls != 2 {
+		t.Errorf("写入次数 = %d, want 2", s.calls)
+	}
+}
@@ -6,11 +6,13 @@ import (
 	"go-chat/models"
 	"go-chat/utils"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
 )
 
 // 升级 HTTP 连接为 WebSocket
@@ -24,17 +26,32 @@ var upgrader = websocket.Upgrader{
 
 // 定义广播消息的结构
 type BroadcastMessage struct {
-	Type        string `json:"type"`
-	UserID      uint   `json:"user_id"`
-	Username    string `json:"username"`
-	Content     string `json:"content"`
-	MessageType string `json:"message_type"` // text, image, file
-	FileURL     string `json:"file_url"`
-	FileName    string `json:"file_name"`
-	FileSize    int64  `json:"file_size"`
-	Target      uint   `json:"target"`   // 0表示全局/群聊，>0表示私聊目标用户ID
-	GroupID     uint   `json:"group_id"` // 群组ID，0表示全局聊天，>0表示群聊
-	CreatedAt   string `json:"created_at"`
+	Type        string              `json:"type"`
+	MessageID   uint                `json:"message_id,omitempty"` // 已保存消息的ID，用于置顶、引用等操作
+	UserID      uint                `json:"user_id"`
+	Username    string              `json:"username"`
+	Content     string              `json:"content"`
+	MessageType string              `json:"message_type"` // text, image, file, voice
+	FileURL     string              `json:"file_url"`
+	FileName    string              `json:"file_name"`
+	FileSize    int64               `json:"file_size"`
+	ImageWidth  int                 `json:"image_width,omitempty"`  // 图片宽度
+	ImageHeight int                 `json:"image_height,omitempty"` // 图片高度
+	Blurhash    string              `json:"blurhash,omitempty"`     // 图片加载前显示的占位图
+	Thumbnails  []models.Thumbnail  `json:"thumbnails,omitempty"`   // 图片缩略图
+	DurationMs  int                 `json:"duration_ms,omitempty"`  // 语音时长（毫秒）
+	Waveform    []int               `json:"waveform,omitempty"`     // 语音波形，0-100 的相对音量
+	FileStatus  string              `json:"file_status,omitempty"`  // 文件状态: scanning, infected，为空表示正常
+	LinkPreview *models.LinkPreview `json:"link_preview,omitempty"` // 链接预览，抓取完成后通过 message_updated 事件发送
+	Target      uint                `json:"target"`                 // 0表示全局/群聊，>0表示私聊目标用户ID
+	GroupID     uint                `json:"group_id"`               // 群组ID，0表示全局聊天，>0表示群聊
+	ChannelID   uint                `json:"channel_id,omitempty"`   // 群组内的频道ID，0表示默认频道
+	ExpireMode  string              `json:"expire_mode,omitempty"`  // 阅后即焚计时方式: timer, after_read
+	ExpireTTL   int                 `json:"expire_ttl,omitempty"`   // 阅后即焚存活时间（秒）
+	ExpiresAt   string              `json:"expires_at,omitempty"`   // 销毁时间，after_read 消息在被阅读前为空
+	CreatedAt   string              `json:"created_at"`
+
+	Recipients []uint `json:"-"` // 指定接收者用户ID列表，非空时仅发送给这些用户
 }
 
 // 全局存储连接
@@ -183,59 +200,225 @@ func WSHandler(c *gin.Context) {
 			groupID = uint(groupVal)
 		}
 
-		// 对于群聊消息，验证发送者是否为群成员
-		if groupID > 0 {
-			var member models.GroupMember
-			if err := models.DB.Where("user_id = ? AND group_id = ?", userID, groupID).First(&member).Error; err != nil {
-				// 发送者不是群成员，向其发送错误消息并跳过处理
-				errorMsg := map[string]interface{}{
-					"type":    "error",
-					"message": "您不是该群组成员，无法发送消息",
-				}
-				if errorJSON, err := json.Marshal(errorMsg); err == nil {
-					conn.WriteMessage(websocket.TextMessage, errorJSON)
+		// 群组内的频道ID，0表示默认频道，仅对群聊消息有效
+		channelID := uint(0)
+		if channelVal, ok := messageData["channel_id"].(float64); ok && groupID > 0 {
+			channelID = uint(channelVal)
+		}
+
+		// 阅后即焚参数，未指定时使用会话设置
+		expireTTL := 0
+		if ttlVal, ok := messageData["ttl"].(float64); ok {
+			expireTTL = int(ttlVal)
+		}
+		expireMode, _ := messageData["expire_mode"].(string)
+
+		// 以 / 开头的文本消息作为斜杠命令处理，不作为普通消息保存；以 // 开头可发送字面量斜杠
+		if messageType == "text" {
+			if strings.HasPrefix(content, "//") {
+				content = content[1:]
+			} else if strings.HasPrefix(content, "/") {
+				if groupID > 0 {
+					if _, gerr := authorizeSender(userID, groupID, channelID); gerr != nil {
+						sendErrorMessage(conn, gerr.Message)
+						continue
+					}
 				}
-				fmt.Printf("❌ 用户 %s 尝试向群组 %d 发送消息但不是成员\n", username, groupID)
-				continue // 跳过此消息的处理
+				handleSlashCommand(conn, &CommandContext{
+					UserID:    userID,
+					Username:  username,
+					GroupID:   groupID,
+					ChannelID: channelID,
+					Target:    target,
+				}, content)
+				continue
 			}
 		}
 
-		// 创建消息实例并保存到数据库
-		message := models.Message{
-			UserID:      userID,
-			Username:    username,
+		// 校验、保存并广播消息
+		message, gerr := deliverMessage(userID, username, &OutgoingMessage{
 			Content:     content,
 			MessageType: messageType,
 			FileURL:     fileURL,
 			FileName:    fileName,
 			FileSize:    fileSize,
+			Target:      target,
 			GroupID:     groupID,
-			CreatedAt:   time.Now(),
+			ChannelID:   channelID,
+			ExpireMode:  expireMode,
+			ExpireTTL:   expireTTL,
+		})
+		if gerr != nil {
+			sendErrorMessage(conn, gerr.Message)
+			continue
 		}
 
-		if err := models.DB.Create(&message).Error; err != nil {
-			fmt.Printf("保存消息到数据库失败: %v\n", err)
-		} else {
-			fmt.Printf("消息已保存到数据库: %s: %s\n", username, content)
+		// 文本消息中的链接在后台抓取预览
+		unfurlMessage(message)
+	}
+}
+
+// OutgoingMessage 待发送的聊天消息，WebSocket 实时消息和定时消息都通过它进入统一的发送流程
+type OutgoingMessage struct {
+	Content     string
+	MessageType string // text, image, file, voice 等
+	FileURL     string
+	FileName    string
+	FileSize    int64
+	Target      uint   // 私聊目标用户ID，0表示群聊或全局聊天
+	GroupID     uint   // 群组ID，0表示私聊或全局聊天
+	ChannelID   uint   // 群组内的频道ID，0表示默认频道
+	ExpireMode  string // 阅后即焚计时方式，为空时使用会话设置
+	ExpireTTL   int    // 阅后即焚存活时间（秒），为 0 时使用会话设置
+
+	SkipSlowMode bool // 跳过慢速模式检查（定时消息在设定时间发出，不受发言间隔限制）
+}
+
+// authorizeSender 校验发送者能否在群组的指定频道发言：必须是群成员、能访问该频道且未被禁言
+func authorizeSender(userID, groupID, channelID uint) (*models.RoleInfo, *groupError) {
+	member, role, _, gerr := authorizeChannel(userID, groupID, channelID, "")
+	if gerr != nil {
+		fmt.Printf("❌ 用户 %d 尝试向群组 %d 发送消息但不是成员\n", userID, groupID)
+		if gerr.Status == http.StatusForbidden {
+			return nil, newGroupError(http.StatusForbidden, "您不是该群组成员，无法发送消息")
 		}
+		return nil, gerr
+	}
 
-		// 构建广播消息
-		broadcastMsg := BroadcastMessage{
-			Type:        "message",
-			UserID:      userID,
-			Username:    username,
-			Content:     content,
-			MessageType: messageType,
-			FileURL:     fileURL,
-			FileName:    fileName,
-			FileSize:    fileSize,
-			Target:      target,
-			GroupID:     groupID,
-			CreatedAt:   time.Now().Format("2006-01-02 15:04:05"),
+	// 被禁言的成员只能阅读，不能发送消息
+	if member.IsMuted() {
+		return nil, newGroupError(http.StatusForbidden, fmt.Sprintf("您已被禁言至 %s", member.MutedUntil.Format("2006-01-02 15:04:05")))
+	}
+	return role, nil
+}
+
+// prepareMessage 校验发送权限、附件和阅后即焚参数，返回待保存的消息。慢速模式由 deliverMessage 检查
+func prepareMessage(userID uint, username string, out *OutgoingMessage) (*models.Message, *groupError) {
+	if out.GroupID > 0 {
+		role, gerr := authorizeSender(userID, out.GroupID, out.ChannelID)
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		// 校验发送权限，携带图片、文件等附件的消息还需要发送文件权限
+		perm := models.PermSend
+		if out.FileURL != "" {
+			perm = models.PermSendFiles
 		}
-		// 给广播通道发送消息
-		broadcast <- broadcastMsg
+		if !role.Has(perm) {
+			return nil, permissionDenied(perm)
+		}
+	}
+
+	// 引用的上传文件必须是本人上传且尚未发送过的附件
+	attachment, gerr := checkMessageAttachment(userID, out.FileURL)
+	if gerr != nil {
+		return nil, gerr
+	}
+	if out.MessageType == "voice" && (attachment == nil || attachment.DurationMs <= 0) {
+		return nil, newGroupError(http.StatusBadRequest, "语音消息需要上传 WAV 或 Ogg/Opus 格式的音频")
 	}
+	if attachment != nil && out.GroupID > 0 {
+		if gerr := checkStorageQuota(models.StorageOwnerGroup, out.GroupID, attachment.Size); gerr != nil {
+			return nil, gerr
+		}
+	}
+
+	// 阅后即焚：消息自带的存活时间优先，否则使用会话设置
+	expireMode, expireTTL, gerr := resolveMessageExpiry(userID, out.GroupID, out.Target, out.ExpireTTL, out.ExpireMode)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	message := &models.Message{
+		UserID:      userID,
+		Username:    username,
+		Content:     out.Content,
+		MessageType: out.MessageType,
+		FileURL:     out.FileURL,
+		FileName:    out.FileName,
+		FileSize:    out.FileSize,
+		GroupID:     out.GroupID,
+		ChannelID:   out.ChannelID,
+		TargetID:    out.Target,
+		CreatedAt:   time.Now(),
+	}
+	if attachment != nil {
+		// 图片附件带上尺寸、占位图和缩略图，客户端无需下载原图即可预览
+		message.ImageWidth = attachment.Width
+		message.ImageHeight = attachment.Height
+		message.Blurhash = attachment.Blurhash
+		message.Thumbnails = attachment.Thumbnails
+		// 音频附件带上时长和波形，客户端无需下载文件即可显示语音气泡
+		message.DurationMs = attachment.DurationMs
+		message.Waveform = attachment.Waveform
+		// 安全扫描完成前文件不能下载，客户端显示扫描中
+		message.FileStatus = fileStatusForScan(attachment.ScanStatus)
+	}
+	applyMessageExpiry(message, expireMode, expireTTL)
+	return message, nil
+}
+
+// broadcastChatMessage 将已保存的聊天消息广播给会话中的在线用户
+func broadcastChatMessage(message *models.Message) {
+	broadcastMsg := BroadcastMessage{
+		Type:        "message",
+		MessageID:   message.ID,
+		UserID:      message.UserID,
+		Username:    message.Username,
+		Content:     message.Content,
+		MessageType: message.MessageType,
+		FileURL:     message.FileURL,
+		FileName:    message.FileName,
+		FileSize:    message.FileSize,
+		ImageWidth:  message.ImageWidth,
+		ImageHeight: message.ImageHeight,
+		Blurhash:    message.Blurhash,
+		Thumbnails:  message.Thumbnails,
+		DurationMs:  message.DurationMs,
+		Waveform:    message.Waveform,
+		FileStatus:  message.FileStatus,
+		Target:      message.TargetID,
+		GroupID:     message.GroupID,
+		ChannelID:   message.ChannelID,
+		ExpireMode:  message.ExpireMode,
+		ExpireTTL:   message.ExpireTTL,
+		CreatedAt:   message.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if message.ExpiresAt != nil {
+		broadcastMsg.ExpiresAt = message.ExpiresAt.Format("2006-01-02 15:04:05")
+	}
+	// 给广播通道发送消息
+	broadcast <- broadcastMsg
+}
+
+// deliverMessage 校验并保存消息，然后广播给会话中的在线用户
+func deliverMessage(userID uint, username string, out *OutgoingMessage) (*models.Message, *groupError) {
+	message, gerr := prepareMessage(userID, username, out)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	// 慢速模式：其他校验都通过后再预占发言间隔，保存失败时归还
+	release := func() {}
+	if out.GroupID > 0 && !out.SkipSlowMode {
+		if release, gerr = reserveSlowMode(out.GroupID, userID); gerr != nil {
+			return nil, gerr
+		}
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		return saveMessage(tx, message)
+	})
+	if err != nil {
+		release()
+		fmt.Printf("保存消息到数据库失败: %v\n", err)
+		return nil, saveMessageError(err)
+	}
+	fmt.Printf("消息已保存到数据库: %s: %s\n", username, message.Content)
+
+	broadcastChatMessage(message)
+	return message, nil
 }
 
 // 广播消息给所有连接
@@ -244,15 +427,24 @@ func HandleMessages() {
 		msg := <-broadcast
 
 		// 根据消息类型处理
-		if msg.Type == "user_joined" || msg.Type == "user_left" {
+		if len(msg.Recipients) > 0 {
+			// 定向消息（如命令的私有回复、通知），仅发送给指定用户的所有连接
+			for _, recipientID := range msg.Recipients {
+				for _, conn := range utils.GetUserConnections(recipientID) {
+					sendMessageToClient(conn, msg)
+				}
+			}
+		} else if msg.Type == "user_joined" || msg.Type == "user_left" {
 			// 用户上下线消息，广播给所有人
 			mutex.RLock()
 			for client := range clients {
 				sendMessageToClient(client, msg)
 			}
 			mutex.RUnlock()
-		} else if msg.Type == "group_member_joined" || msg.Type == "group_member_left" {
-			// 群成员变动消息，仅广播给该群在线成员
+		} else if msg.Type == "group_member_joined" || msg.Type == "group_member_left" ||
+			msg.Type == "message_pinned" || msg.Type == "message_unpinned" || msg.Type == "announcement" ||
+			msg.Type == "group_dissolved" || msg.Type == "group_restored" {
+			// 群成员变动、置顶、公告和群组解散/恢复消息，仅广播给该群在线成员
 			if msg.GroupID > 0 {
 				broadcastToGroupMembers(msg, msg.GroupID)
 			}
@@ -301,11 +493,29 @@ func sendMessageToClient(client *websocket.Conn, msg BroadcastMessage) {
 	}
 }
 
-// 辅助函数：向群成员广播消息（支持多连接）
+// 辅助函数：向单个连接发送错误消息
+func sendErrorMessage(conn *websocket.Conn, message string) {
+	errorMsg := map[string]interface{}{
+		"type":    "error",
+		"message": message,
+	}
+	if errorJSON, err := json.Marshal(errorMsg); err == nil {
+		conn.WriteMessage(websocket.TextMessage, errorJSON)
+	}
+}
+
+// 辅助函数：向群成员广播消息（支持多连接），私有频道的消息仅发送给能访问该频道的成员
 func broadcastToGroupMembers(msg BroadcastMessage, groupID uint) {
 	// 查询群成员ID列表
 	var memberIDs []uint
-	if err := models.DB.Model(&models.GroupMember{}).Where("group_id = ?", groupID).Pluck("user_id", &memberIDs).Error; err != nil {
+	if msg.ChannelID > 0 {
+		channel, gerr := getGroupChannel(groupID, msg.ChannelID)
+		if gerr != nil {
+			fmt.Printf("查询频道失败: %s\n", gerr.Message)
+			return
+		}
+		memberIDs = channelAudienceIDs(channel)
+	} else if err := models.DB.Model(&models.GroupMember{}).Where("group_id = ?", groupID).Pluck("user_id", &memberIDs).Error; err != nil {
 		fmt.Printf("查询群成员失败: %v\n", err)
 		return
 	}
@@ -323,3 +533,12 @@ func broadcastToGroupMembers(msg BroadcastMessage, groupID uint) {
 func SendBroadcastMessage(msg BroadcastMessage) {
 	broadcast <- msg
 }
+
+// SendToUsers 通过广播通道向指定用户发送消息
+func SendToUsers(msg BroadcastMessage, userIDs ...uint) {
+	if len(userIDs) == 0 {
+		return
+	}
+	msg.Recipients = userIDs
+	broadcast <- msg
+}
@@ -0,0 +1,408 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"go-chat/models"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// CommandContext 斜杠命令执行上下文
+type CommandContext struct {
+	UserID    uint
+	Username  string
+	GroupID   uint     // 命令所在群组ID，0表示全局聊天或私聊
+	ChannelID uint     // 命令所在的群组频道ID，0表示默认频道
+	Target    uint     // 私聊目标用户ID，0表示非私聊
+	Name      string   // 命令名（不含 /）
+	Args      []string // 按空白分割后的参数
+	RawArgs   string   // 命令名之后的原始参数文本
+}
+
+// CommandReply 命令执行结果
+type CommandReply struct {
+	Content     string
+	Public      bool   // true 表示在当前会话中公开发送，false 表示仅发送者可见
+	MessageType string // 公开回复保存的消息类型，默认为 system
+}
+
+// CommandHandler 命令处理函数，返回的 error 会以错误消息的形式仅发送给命令发起者
+type CommandHandler func(ctx *CommandContext) (*CommandReply, error)
+
+// Command 斜杠命令定义
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	Owner       string // 注册方：system 表示内置命令，否则为机器人名称
+	GroupOnly   bool   // 是否只能在群聊中使用
+	Handler     CommandHandler
+}
+
+var commandNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// 已注册的命令
+var commandRegistry = struct {
+	sync.RWMutex
+	commands map[string]*Command
+}{commands: make(map[string]*Command)}
+
+func init() {
+	registerBuiltinCommands()
+}
+
+// RegisterCommand 注册斜杠命令，命令名重复或不合法时返回错误
+func RegisterCommand(cmd Command) error {
+	cmd.Name = strings.ToLower(strings.TrimPrefix(cmd.Name, "/"))
+	if !commandNamePattern.MatchString(cmd.Name) {
+		return fmt.Errorf("命令名不合法: %s", cmd.Name)
+	}
+	if cmd.Handler == nil {
+		return fmt.Errorf("命令 /%s 缺少处理函数", cmd.Name)
+	}
+	if cmd.Owner == "" {
+		cmd.Owner = "system"
+	}
+
+	commandRegistry.Lock()
+	defer commandRegistry.Unlock()
+
+	if _, exists := commandRegistry.commands[cmd.Name]; exists {
+		return fmt.Errorf("命令 /%s 已被注册", cmd.Name)
+	}
+	commandRegistry.commands[cmd.Name] = &cmd
+	return nil
+}
+
+// RegisterBotCommand 以机器人身份注册斜杠命令
+func RegisterBotCommand(botName string, cmd Command) error {
+	if botName == "" || botName == "system" {
+		return errors.New("机器人名称不合法")
+	}
+	cmd.Owner = botName
+	return RegisterCommand(cmd)
+}
+
+// UnregisterCommand 注销斜杠命令
+func UnregisterCommand(name string) {
+	commandRegistry.Lock()
+	delete(commandRegistry.commands, strings.ToLower(name))
+	commandRegistry.Unlock()
+}
+
+// ListCommands 获取所有已注册命令（按名称排序）
+func ListCommands() []Command {
+	commandRegistry.RLock()
+	defer commandRegistry.RUnlock()
+
+	list := make([]Command, 0, len(commandRegistry.commands))
+	for _, cmd := range commandRegistry.commands {
+		list = append(list, *cmd)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func lookupCommand(name string) (*Command, bool) {
+	commandRegistry.RLock()
+	defer commandRegistry.RUnlock()
+	cmd, ok := commandRegistry.commands[name]
+	return cmd, ok
+}
+
+// handleSlashCommand 解析并执行斜杠命令，根据回复类型决定私有回复或公开发送
+func handleSlashCommand(conn *websocket.Conn, ctx *CommandContext, content string) {
+	text := strings.TrimSpace(strings.TrimPrefix(content, "/"))
+	name, rawArgs, _ := strings.Cut(text, " ")
+	ctx.Name = strings.ToLower(name)
+	ctx.RawArgs = strings.TrimSpace(rawArgs)
+	ctx.Args = strings.Fields(ctx.RawArgs)
+
+	cmd, ok := lookupCommand(ctx.Name)
+	if !ok {
+		sendErrorMessage(conn, fmt.Sprintf("未知命令 /%s，输入 /help 查看可用命令", ctx.Name))
+		return
+	}
+
+	if cmd.GroupOnly && ctx.GroupID == 0 {
+		sendErrorMessage(conn, fmt.Sprintf("命令 /%s 只能在群聊中使用", cmd.Name))
+		return
+	}
+
+	reply, err := cmd.Handler(ctx)
+	if err != nil {
+		sendErrorMessage(conn, err.Error())
+		return
+	}
+	if reply == nil || reply.Content == "" {
+		return
+	}
+
+	if !reply.Public {
+		// 私有回复：仅发送给命令发起者
+		SendToUsers(BroadcastMessage{
+			Type:        "command_reply",
+			UserID:      ctx.UserID,
+			Username:    ctx.Username,
+			Content:     reply.Content,
+			MessageType: "system",
+			Target:      ctx.Target,
+			GroupID:     ctx.GroupID,
+			CreatedAt:   time.Now().Format("2006-01-02 15:04:05"),
+		}, ctx.UserID)
+		return
+	}
+
+	messageType := reply.MessageType
+	if messageType == "" {
+		messageType = "system"
+	}
+
+	// 公开回复与普通消息走相同的发送流程：校验发言权限、慢速模式和阅后即焚设置后保存并广播
+	if _, gerr := deliverMessage(ctx.UserID, ctx.Username, &OutgoingMessage{
+		Content:     reply.Content,
+		MessageType: messageType,
+		Target:      ctx.Target,
+		GroupID:     ctx.GroupID,
+		ChannelID:   ctx.ChannelID,
+	}); gerr != nil {
+		sendErrorMessage(conn, gerr.Message)
+	}
+}
+
+// resolveUserArg 将 @username 形式的参数解析为用户
+func resolveUserArg(arg string) (*models.User, error) {
+	username := strings.TrimPrefix(arg, "@")
+	if username == "" {
+		return nil, errors.New("请指定用户，例如 @username")
+	}
+
+	var user models.User
+	if err := models.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("用户 %s 不存在", username)
+	}
+	return &user, nil
+}
+
+// maxCommandDuration 时长参数的上限，避免按天换算时溢出为负数
+const maxCommandDuration = 3650 * 24 * time.Hour
+
+// parseCommandDuration 解析时长参数，在 time.ParseDuration 基础上支持 d（天），最长 3650 天
+func parseCommandDuration(s string) (time.Duration, error) {
+	var d time.Duration
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 || days > int(maxCommandDuration/(24*time.Hour)) {
+			return 0, fmt.Errorf("无效的时长: %s", s)
+		}
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil || d <= 0 || d > maxCommandDuration {
+			return 0, fmt.Errorf("无效的时长: %s", s)
+		}
+	}
+	return d, nil
+}
+
+// registerBuiltinCommands 注册内置命令
+func registerBuiltinCommands() {
+	builtins := []Command{
+		{Name: "help", Usage: "/help", Description: "查看可用命令", Handler: cmdHelp},
+		{Name: "me", Usage: "/me <动作>", Description: "以第三人称描述动作", Handler: cmdMe},
+		{Name: "topic", Usage: "/topic [话题]", Description: "查看或设置群话题", GroupOnly: true, Handler: cmdTopic},
+		{Name: "kick", Usage: "/kick @用户", Description: "将成员移出群组", GroupOnly: true, Handler: cmdKick},
+		{Name: "mute", Usage: "/mute @用户 [时长，如 10m]", Description: "禁言群成员", GroupOnly: true, Handler: cmdMute},
+		{Name: "unmute", Usage: "/unmute @用户", Description: "解除群成员禁言", GroupOnly: true, Handler: cmdUnmute},
+		{Name: "ban", Usage: "/ban @用户 [原因]", Description: "将成员移出群组并禁止其重新加入", GroupOnly: true, Handler: cmdBan},
+		{Name: "slowmode", Usage: "/slowmode <秒数|off>", Description: "设置群组慢速模式", GroupOnly: true, Handler: cmdSlowMode},
+		{Name: "invite", Usage: "/invite @用户", Description: "邀请用户加入群组", GroupOnly: true, Handler: cmdInvite},
+	}
+
+	for _, cmd := range builtins {
+		if err := RegisterCommand(cmd); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func cmdHelp(ctx *CommandContext) (*CommandReply, error) {
+	var sb strings.Builder
+	sb.WriteString("可用命令：")
+	for _, cmd := range ListCommands() {
+		if cmd.GroupOnly && ctx.GroupID == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n%s - %s", cmd.Usage, cmd.Description))
+	}
+	return &CommandReply{Content: sb.String()}, nil
+}
+
+func cmdMe(ctx *CommandContext) (*CommandReply, error) {
+	if ctx.RawArgs == "" {
+		return nil, errors.New("用法: /me <动作>")
+	}
+	return &CommandReply{
+		Content:     fmt.Sprintf("* %s %s", ctx.Username, ctx.RawArgs),
+		Public:      true,
+		MessageType: "emote",
+	}, nil
+}
+
+func cmdTopic(ctx *CommandContext) (*CommandReply, error) {
+	// 无参数时显示当前话题
+	if ctx.RawArgs == "" {
+		var group models.Group
+		if err := models.DB.First(&group, ctx.GroupID).Error; err != nil {
+			return nil, errors.New("群组不存在")
+		}
+		if group.Description == "" {
+			return &CommandReply{Content: "当前群组没有设置话题"}, nil
+		}
+		return &CommandReply{Content: "当前话题：" + group.Description}, nil
+	}
+
+	if _, _, gerr := authorizeGroup(ctx.UserID, ctx.GroupID, models.PermEditInfo); gerr != nil {
+		return nil, gerr
+	}
+
+	var group models.Group
+	if err := models.DB.First(&group, ctx.GroupID).Error; err != nil {
+		return nil, errors.New("群组不存在")
+	}
+	before := groupInfoSnapshot(&group)
+
+	if err := models.DB.Model(&group).Update("description", ctx.RawArgs).Error; err != nil {
+		return nil, errors.New("更新群组失败")
+	}
+	recordGroupAudit(models.DB, group.ID, ctx.UserID, models.AuditGroupUpdate, group.ID, before, groupInfoSnapshot(&group))
+
+	return &CommandReply{
+		Content: fmt.Sprintf("%s 将群话题修改为：%s", ctx.Username, ctx.RawArgs),
+		Public:  true,
+	}, nil
+}
+
+func cmdKick(ctx *CommandContext) (*CommandReply, error) {
+	if len(ctx.Args) < 1 {
+		return nil, errors.New("用法: /kick @用户")
+	}
+
+	target, err := resolveUserArg(ctx.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if gerr := removeMemberFromGroup(ctx.UserID, ctx.GroupID, target.ID, false, ""); gerr != nil {
+		return nil, gerr
+	}
+
+	// 群内已由系统消息公告，这里仅回复操作者
+	return &CommandReply{Content: fmt.Sprintf("已将 %s 移出群组", target.Username)}, nil
+}
+
+func cmdMute(ctx *CommandContext) (*CommandReply, error) {
+	if len(ctx.Args) < 1 {
+		return nil, errors.New("用法: /mute @用户 [时长，如 10m]")
+	}
+
+	target, err := resolveUserArg(ctx.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	durationArg := "10m"
+	if len(ctx.Args) > 1 {
+		durationArg = ctx.Args[1]
+	}
+	duration, err := parseCommandDuration(durationArg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, gerr := muteGroupMember(ctx.UserID, ctx.GroupID, target.ID, duration); gerr != nil {
+		return nil, gerr
+	}
+
+	return &CommandReply{Content: fmt.Sprintf("已将 %s 禁言 %s", target.Username, durationArg)}, nil
+}
+
+func cmdUnmute(ctx *CommandContext) (*CommandReply, error) {
+	if len(ctx.Args) < 1 {
+		return nil, errors.New("用法: /unmute @用户")
+	}
+
+	target, err := resolveUserArg(ctx.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if gerr := unmuteGroupMember(ctx.UserID, ctx.GroupID, target.ID); gerr != nil {
+		return nil, gerr
+	}
+
+	return &CommandReply{Content: fmt.Sprintf("已解除 %s 的禁言", target.Username)}, nil
+}
+
+func cmdBan(ctx *CommandContext) (*CommandReply, error) {
+	if len(ctx.Args) < 1 {
+		return nil, errors.New("用法: /ban @用户 [原因]")
+	}
+
+	target, err := resolveUserArg(ctx.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	reason := strings.TrimSpace(strings.TrimPrefix(ctx.RawArgs, ctx.Args[0]))
+
+	if gerr := removeMemberFromGroup(ctx.UserID, ctx.GroupID, target.ID, true, reason); gerr != nil {
+		return nil, gerr
+	}
+
+	return &CommandReply{Content: fmt.Sprintf("已将 %s 移出群组并封禁", target.Username)}, nil
+}
+
+func cmdSlowMode(ctx *CommandContext) (*CommandReply, error) {
+	if len(ctx.Args) < 1 {
+		return nil, errors.New("用法: /slowmode <秒数|off>")
+	}
+
+	seconds := 0
+	if ctx.Args[0] != "off" {
+		n, err := strconv.Atoi(ctx.Args[0])
+		if err != nil {
+			return nil, fmt.Errorf("无效的秒数: %s", ctx.Args[0])
+		}
+		seconds = n
+	}
+
+	if gerr := setGroupSlowMode(ctx.UserID, ctx.GroupID, seconds); gerr != nil {
+		return nil, gerr
+	}
+
+	return &CommandReply{Content: "慢速模式已更新"}, nil
+}
+
+func cmdInvite(ctx *CommandContext) (*CommandReply, error) {
+	if len(ctx.Args) < 1 {
+		return nil, errors.New("用法: /invite @用户")
+	}
+
+	target, err := resolveUserArg(ctx.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, gerr := addMemberToGroup(ctx.UserID, ctx.GroupID, target.ID, "member"); gerr != nil {
+		return nil, gerr
+	}
+
+	return &CommandReply{Content: fmt.Sprintf("已邀请 %s 加入群组", target.Username)}, nil
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCommandDuration(t *testing.T) {
+	valid := map[string]time.Duration{
+		"10m":   10 * time.Minute,
+		"2h":    2 * time.Hour,
+		"7d":    7 * 24 * time.Hour,
+		"3650d": maxCommandDuration,
+	}
+	for s, want := range valid {
+		if got, err := parseCommandDuration(s); err != nil || got != want {
+			t.Errorf("parseCommandDuration(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+
+	// 200000d 按天换算会溢出为负数
+	for _, s := range []string{"", "0d", "-1d", "3651d", "200000d", "99999999999d", "0s", "-5m", "87601h", "abc"} {
+		if d, err := parseCommandDuration(s); err == nil {
+			t.Errorf("parseCommandDuration(%q) = %v, want error", s, d)
+		}
+	}
+}
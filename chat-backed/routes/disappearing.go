@@ -0,0 +1,416 @@
+package routes
+
+import (
+	"fmt"
+	"go-chat/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 阅后即焚消息存活时间的取值范围（秒）
+const (
+	minDisappearingTTL = 5
+	maxDisappearingTTL = 7 * 24 * 3600
+)
+
+// 到期消息的检查间隔和每批处理数量
+const (
+	disappearingInterval  = 5 * time.Second
+	disappearingBatchSize = 200
+)
+
+// disappearingRequest 修改阅后即焚设置的请求，ttl_seconds 为 0 表示关闭
+type disappearingRequest struct {
+	Mode       string `json:"mode"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// validate 校验请求参数并补全默认计时方式
+func (r *disappearingRequest) validate() *groupError {
+	if r.TTLSeconds == 0 {
+		return nil
+	}
+	if r.TTLSeconds < minDisappearingTTL || r.TTLSeconds > maxDisappearingTTL {
+		return newGroupError(http.StatusBadRequest, fmt.Sprintf("存活时间需在 %d 秒到 %d 秒之间", minDisappearingTTL, maxDisappearingTTL))
+	}
+	if r.Mode == "" {
+		r.Mode = models.ExpireModeTimer
+	}
+	if !models.IsValidExpireMode(r.Mode) {
+		return newGroupError(http.StatusBadRequest, "mode只允许timer或after_read")
+	}
+	return nil
+}
+
+// findDisappearingSetting 查询会话的阅后即焚设置，未开启时返回 nil。
+// groupID > 0 时查询群组设置，否则查询 userA 与 userB 的私聊设置
+func findDisappearingSetting(groupID, userA, userB uint) *models.DisappearingSetting {
+	low, high := uint(0), uint(0)
+	if groupID == 0 {
+		low, high = models.DirectConversationKey(userA, userB)
+	}
+
+	var setting models.DisappearingSetting
+	if err := models.DB.Where("group_id = ? AND user_low_id = ? AND user_high_id = ?", groupID, low, high).
+		First(&setting).Error; err != nil {
+		return nil
+	}
+	return &setting
+}
+
+// resolveMessageExpiry 根据消息自带的 ttl/expire_mode 和会话设置确定消息的阅后即焚参数。
+// 私聊可以单独为消息设置存活时间；群聊仅在群主开启阅后即焚后可用；全局聊天不支持
+func resolveMessageExpiry(userID, groupID, target uint, ttl int, mode string) (string, int, *groupError) {
+	if groupID == 0 && target == 0 {
+		if ttl != 0 || mode != "" {
+			return "", 0, newGroupError(http.StatusBadRequest, "全局聊天不支持阅后即焚")
+		}
+		return "", 0, nil
+	}
+
+	setting := findDisappearingSetting(groupID, userID, target)
+	if groupID > 0 && setting == nil && (ttl != 0 || mode != "") {
+		return "", 0, newGroupError(http.StatusForbidden, "该群组未开启阅后即焚")
+	}
+
+	if ttl == 0 {
+		if setting == nil {
+			return "", 0, nil
+		}
+		ttl = setting.TTLSeconds
+		if mode == "" {
+			mode = setting.Mode
+		}
+	}
+
+	req := disappearingRequest{Mode: mode, TTLSeconds: ttl}
+	if gerr := req.validate(); gerr != nil {
+		return "", 0, gerr
+	}
+	return req.Mode, req.TTLSeconds, nil
+}
+
+// applyMessageExpiry 设置消息的阅后即焚参数；定时销毁的消息从发送时开始计时
+func applyMessageExpiry(message *models.Message, mode string, ttl int) {
+	if mode == "" {
+		return
+	}
+	message.ExpireMode = mode
+	message.ExpireTTL = ttl
+	if mode == models.ExpireModeTimer {
+		expiresAt := message.CreatedAt.Add(time.Duration(ttl) * time.Second)
+		message.ExpiresAt = &expiresAt
+	}
+}
+
+// notExpired 过滤已到期但尚未被后台任务清理的消息
+func notExpired(db *gorm.DB) *gorm.DB {
+	return db.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
+
+// StartDisappearingWorker 启动后台任务，定期销毁到期的阅后即焚消息
+func StartDisappearingWorker() {
+	go func() {
+		ticker := time.NewTicker(disappearingInterval)
+		defer ticker.Stop()
+
+		for {
+			expireDisappearingMessages()
+			<-ticker.C
+		}
+	}()
+}
+
+// expireDisappearingMessages 删除所有到期的消息及其上传文件，并通知会话中的在线用户
+func expireDisappearingMessages() {
+	for {
+		var messages []models.Message
+		if err := models.DB.Where("expires_at IS NOT NULL AND expires_at <= ?", time.Now()).
+			Order("expires_at asc").Limit(disappearingBatchSize).
+			Find(&messages).Error; err != nil {
+			log.Printf("查询到期消息失败: %v", err)
+			return
+		}
+		if len(messages) == 0 {
+			return
+		}
+
+		ids := make([]uint, 0, len(messages))
+		for _, m := range messages {
+			ids = append(ids, m.ID)
+		}
+
+		err := models.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("message_id IN ?", ids).Delete(&models.PinnedMessage{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("id IN ?", ids).Delete(&models.Message{}).Error
+		})
+		if err != nil {
+			log.Printf("删除到期消息失败: %v", err)
+			return
+		}
+
+		now := time.Now().Format("2006-01-02 15:04:05")
+		for _, m := range messages {
+			if m.FileURL != "" {
+				removeUploadedFile(m.FileURL)
+			}
+			SendBroadcastMessage(BroadcastMessage{
+				Type:      "message_expired",
+				MessageID: m.ID,
+				UserID:    m.UserID,
+				Target:    m.TargetID,
+				GroupID:   m.GroupID,
+				ChannelID: m.ChannelID,
+				CreatedAt: now,
+			})
+		}
+
+		if len(messages) < disappearingBatchSize {
+			return
+		}
+	}
+}
+
+// MarkMessageRead 标记消息已读；阅后计时的消息从接收方首次阅读时开始倒计时
+func MarkMessageRead(c *gin.Context) {
+	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的消息ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	var message models.Message
+	if err := notExpired(models.DB).Where("archived_at IS NULL").First(&message, uint(messageID)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "消息不存在或已销毁"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询消息失败"})
+		}
+		return
+	}
+
+	// 只有会话的接收方可以标记已读
+	if message.GroupID > 0 {
+		if _, _, _, gerr := authorizeChannel(userID, message.GroupID, message.ChannelID, ""); gerr != nil {
+			c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+			return
+		}
+	} else if message.TargetID != userID && message.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该消息"})
+		return
+	}
+
+	if message.ExpireMode == models.ExpireModeAfterRead && message.ExpiresAt == nil && message.UserID != userID {
+		expiresAt := time.Now().Add(time.Duration(message.ExpireTTL) * time.Second)
+		// 条件更新，多个接收方同时阅读时以第一次为准
+		result := models.DB.Model(&models.Message{}).
+			Where("id = ? AND expires_at IS NULL", message.ID).
+			Update("expires_at", expiresAt)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "标记已读失败"})
+			return
+		}
+		if result.RowsAffected > 0 {
+			message.ExpiresAt = &expiresAt
+		} else {
+			models.DB.Select("expires_at").First(&message, message.ID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message_id": message.ID,
+		"expires_at": message.ExpiresAt,
+	})
+}
+
+// getGroupDisappearing 获取群组的阅后即焚设置
+func getGroupDisappearing(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), ""); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	setting := findDisappearingSetting(uint(groupID), 0, 0)
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": setting != nil,
+		"setting": setting,
+	})
+}
+
+// updateGroupDisappearing 开启、修改或关闭群组的阅后即焚（需要设置阅后即焚权限）
+func updateGroupDisappearing(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	var req disappearingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if gerr := req.validate(); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermManageDisappearing); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	before, setting, err := saveDisappearingSetting(uint(groupID), 0, 0, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存阅后即焚设置失败"})
+		return
+	}
+
+	recordGroupAudit(models.DB, uint(groupID), userID, models.AuditDisappearingChange, uint(groupID), before, setting)
+
+	operatorName := lookupUsername(userID)
+	if setting != nil {
+		sendGroupSystemMessage(uint(groupID), fmt.Sprintf("%s 开启了阅后即焚，新消息将在 %s 后销毁", operatorName, describeDisappearing(setting)))
+	} else {
+		sendGroupSystemMessage(uint(groupID), fmt.Sprintf("%s 关闭了阅后即焚", operatorName))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": setting != nil,
+		"setting": setting,
+	})
+}
+
+// GetDirectDisappearing 获取与指定用户私聊的阅后即焚设置
+func GetDirectDisappearing(c *gin.Context) {
+	peerID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	setting := findDisappearingSetting(0, userID, uint(peerID))
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": setting != nil,
+		"setting": setting,
+	})
+}
+
+// UpdateDirectDisappearing 开启、修改或关闭与指定用户私聊的阅后即焚，私聊双方均可设置
+func UpdateDirectDisappearing(c *gin.Context) {
+	peerID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	if uint(peerID) == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能与自己私聊"})
+		return
+	}
+
+	var req disappearingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if gerr := req.validate(); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var peer models.User
+	if err := models.DB.Select("id").First(&peer, uint(peerID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	low, high := models.DirectConversationKey(userID, uint(peerID))
+	_, setting, err := saveDisappearingSetting(0, low, high, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存阅后即焚设置失败"})
+		return
+	}
+
+	content := fmt.Sprintf("%s 关闭了阅后即焚", lookupUsername(userID))
+	if setting != nil {
+		content = fmt.Sprintf("%s 开启了阅后即焚，新消息将在 %s 后销毁", lookupUsername(userID), describeDisappearing(setting))
+	}
+	SendToUsers(BroadcastMessage{
+		Type:      "disappearing_updated",
+		UserID:    userID,
+		Username:  lookupUsername(userID),
+		Content:   content,
+		Target:    uint(peerID),
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}, userID, uint(peerID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": setting != nil,
+		"setting": setting,
+	})
+}
+
+// saveDisappearingSetting 保存或删除（ttl 为 0 时）会话的阅后即焚设置，返回修改前后的设置
+func saveDisappearingSetting(groupID, low, high, operatorID uint, req *disappearingRequest) (*models.DisappearingSetting, *models.DisappearingSetting, error) {
+	var existing models.DisappearingSetting
+	var before *models.DisappearingSetting
+	err := models.DB.Where("group_id = ? AND user_low_id = ? AND user_high_id = ?", groupID, low, high).First(&existing).Error
+	if err == nil {
+		snapshot := existing
+		before = &snapshot
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, nil, err
+	}
+
+	if req.TTLSeconds == 0 {
+		if before != nil {
+			if err := models.DB.Delete(&existing).Error; err != nil {
+				return nil, nil, err
+			}
+		}
+		return before, nil, nil
+	}
+
+	existing.GroupID = groupID
+	existing.UserLowID = low
+	existing.UserHighID = high
+	existing.Mode = req.Mode
+	existing.TTLSeconds = req.TTLSeconds
+	existing.UpdatedBy = operatorID
+	if err := models.DB.Save(&existing).Error; err != nil {
+		return nil, nil, err
+	}
+	return before, &existing, nil
+}
+
+// describeDisappearing 生成阅后即焚设置的文字描述，如 "阅读 30秒"
+func describeDisappearing(setting *models.DisappearingSetting) string {
+	d := (time.Duration(setting.TTLSeconds) * time.Second).String()
+	if setting.Mode == models.ExpireModeAfterRead {
+		return "阅读 " + d
+	}
+	return "发送 " + d
+}
@@ -0,0 +1,584 @@
+package routes
+
+import (
+	"archive/zip"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-chat/export"
+	"go-chat/models"
+	"go-chat/storage"
+	"go-chat/utils"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 导出任务的检查间隔、每批读取的消息数量
+const (
+	exportInterval  = 5 * time.Second
+	exportBatchSize = 500
+)
+
+// 下载链接默认有效期、下载令牌长度和每个用户同时进行中的导出任务上限
+const (
+	defaultExportLinkTTL = 24 * time.Hour
+	exportTokenLength    = 40
+	maxActiveExports     = 3
+)
+
+// exportKeyPrefix 导出压缩包在存储后端中的键前缀
+const exportKeyPrefix = "exports/"
+
+// exportKick 有新任务时唤醒导出协程，避免等待下一个检查周期
+var exportKick = make(chan struct{}, 1)
+
+// exportRequest 创建导出任务的请求
+type exportRequest struct {
+	Scope   string `json:"scope" binding:"required"`  // group, direct, user
+	GroupID uint   `json:"group_id"`                  // scope 为 group 时必填
+	PeerID  uint   `json:"peer_id"`                   // scope 为 direct 时必填
+	Format  string `json:"format" binding:"required"` // json, csv, html
+}
+
+// exportLinkTTL 下载链接有效期（环境变量 EXPORT_LINK_TTL，如 12h、7d）
+func exportLinkTTL() time.Duration {
+	if v := os.Getenv("EXPORT_LINK_TTL"); v != "" {
+		if d, err := parseCommandDuration(v); err == nil {
+			return d
+		}
+		log.Printf("警告: EXPORT_LINK_TTL=%s 无效，使用默认值", v)
+	}
+	return defaultExportLinkTTL
+}
+
+// authorizeExport 校验用户能否导出指定范围：群组需要是群成员，私聊对象需要存在
+func authorizeExport(userID uint, job *models.ExportJob) *groupError {
+	switch job.Scope {
+	case models.ExportScopeGroup:
+		if job.GroupID == 0 {
+			return newGroupError(http.StatusBadRequest, "请指定要导出的群组")
+		}
+		if _, _, gerr := authorizeGroup(userID, job.GroupID, ""); gerr != nil {
+			return gerr
+		}
+	case models.ExportScopeDirect:
+		if job.PeerID == 0 || job.PeerID == userID {
+			return newGroupError(http.StatusBadRequest, "请指定要导出的私聊对象")
+		}
+		var peer models.User
+		if err := models.DB.Select("id").First(&peer, job.PeerID).Error; err != nil {
+			return newGroupError(http.StatusNotFound, "用户不存在")
+		}
+	}
+	return nil
+}
+
+// CreateExport 创建导出任务，任务在后台异步执行
+func CreateExport(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var req exportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if !models.IsValidExportScope(req.Scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope只允许group、direct或user"})
+		return
+	}
+	if !export.IsValidFormat(req.Format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format只允许" + strings.Join(export.Formats(), "、")})
+		return
+	}
+
+	job := models.ExportJob{
+		UserID: userID,
+		Scope:  req.Scope,
+		Format: req.Format,
+		Status: models.ExportPending,
+	}
+	switch req.Scope {
+	case models.ExportScopeGroup:
+		job.GroupID = req.GroupID
+	case models.ExportScopeDirect:
+		job.PeerID = req.PeerID
+	}
+
+	if gerr := authorizeExport(userID, &job); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var active int64
+	models.DB.Model(&models.ExportJob{}).
+		Where("user_id = ? AND status IN ?", userID, []string{models.ExportPending, models.ExportRunning}).
+		Count(&active)
+	if active >= maxActiveExports {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("最多同时进行 %d 个导出任务", maxActiveExports)})
+		return
+	}
+
+	if err := models.DB.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建导出任务失败"})
+		return
+	}
+
+	select {
+	case exportKick <- struct{}{}:
+	default:
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "导出任务已创建",
+		"job":     job,
+	})
+}
+
+// exportJobResponse 导出任务的响应，已完成且未过期的任务附带下载链接
+func exportJobResponse(job *models.ExportJob) gin.H {
+	resp := gin.H{"job": job}
+	if job.Status == models.ExportDone && job.ExpiresAt != nil && time.Now().Before(*job.ExpiresAt) {
+		resp["download_url"] = fmt.Sprintf("/exports/%d/download?token=%s", job.ID, job.DownloadToken)
+	}
+	return resp
+}
+
+// GetExports 获取当前用户的导出任务列表
+func GetExports(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var jobs []models.ExportJob
+	if err := models.DB.Where("user_id = ?", userID).Order("id desc").Limit(50).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取导出任务失败"})
+		return
+	}
+
+	list := make([]gin.H, 0, len(jobs))
+	for i := range jobs {
+		list = append(list, exportJobResponse(&jobs[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"exports": list,
+	})
+}
+
+// GetExport 获取导出任务的状态和进度
+func GetExport(c *gin.Context) {
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	var job models.ExportJob
+	if err := models.DB.Where("id = ? AND user_id = ?", uint(jobID), userID).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "导出任务不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取导出任务失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, exportJobResponse(&job))
+}
+
+// DownloadExport 通过带令牌的链接下载导出文件，链接过期后不可用
+func DownloadExport(c *gin.Context) {
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	var job models.ExportJob
+	if err := models.DB.Where("id = ? AND status = ?", uint(jobID), models.ExportDone).First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "导出文件不存在"})
+		return
+	}
+
+	token := c.Query("token")
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(job.DownloadToken)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "下载链接无效"})
+		return
+	}
+	if job.ExpiresAt == nil || time.Now().After(*job.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "下载链接已过期"})
+		return
+	}
+
+	fileName := fmt.Sprintf("chat-export-%d.zip", job.ID)
+	if job.StorageKey == "" {
+		// 早期版本的导出文件保存在本地导出目录
+		c.FileAttachment(job.FilePath, fileName)
+		return
+	}
+
+	// 存储后端支持预签名时重定向到限时下载地址，有效期不超过下载链接本身
+	if presigner, ok := fileStorage.(storage.Presigner); ok {
+		contentType, disposition := safeFileHeaders("application/zip", fileName)
+		url, err := presigner.PresignGet(job.StorageKey, min(signedFileURLTTL, time.Until(*job.ExpiresAt)), storage.GetOptions{
+			ContentType:        contentType,
+			ContentDisposition: disposition,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取导出文件失败"})
+			return
+		}
+		c.Redirect(http.StatusFound, url)
+		return
+	}
+
+	rc, err := fileStorage.Open(job.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "导出文件不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取导出文件失败"})
+		}
+		return
+	}
+	defer rc.Close()
+
+	setSafeFileHeaders(c, "application/zip", fileName)
+	if rs, ok := rc.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, "", job.CreatedAt, rs)
+		return
+	}
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, rc)
+}
+
+// StartExportWorker 启动导出任务处理协程。任务保存在数据库中，
+// 服务重启时中断的任务会重新执行
+func StartExportWorker() {
+	if err := models.DB.Model(&models.ExportJob{}).
+		Where("status = ?", models.ExportRunning).
+		Update("status", models.ExportPending).Error; err != nil {
+		log.Printf("重置中断的导出任务失败: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(exportInterval)
+		defer ticker.Stop()
+
+		for {
+			processPendingExports()
+			cleanupExpiredExports()
+			select {
+			case <-ticker.C:
+			case <-exportKick:
+			}
+		}
+	}()
+}
+
+// processPendingExports 依次执行等待中的导出任务
+func processPendingExports() {
+	for {
+		var job models.ExportJob
+		if err := models.DB.Where("status = ?", models.ExportPending).Order("id asc").First(&job).Error; err != nil {
+			return
+		}
+
+		// 条件更新领取任务，避免多实例重复执行
+		result := models.DB.Model(&models.ExportJob{}).
+			Where("id = ? AND status = ?", job.ID, models.ExportPending).
+			Update("status", models.ExportRunning)
+		if result.Error != nil {
+			log.Printf("领取导出任务失败: %v", result.Error)
+			return
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		if err := runExportJob(&job); err != nil {
+			log.Printf("导出任务 %d 失败: %v", job.ID, err)
+			models.DB.Model(&job).Updates(map[string]interface{}{
+				"status": models.ExportFailed,
+				"error":  err.Error(),
+			})
+			continue
+		}
+		log.Printf("✅ 导出任务 %d 完成，共 %d 条消息", job.ID, job.MessageCount)
+	}
+}
+
+// cleanupExpiredExports 删除下载链接已过期的导出文件
+func cleanupExpiredExports() {
+	var jobs []models.ExportJob
+	if err := models.DB.Where("status = ? AND expires_at <= ?", models.ExportDone, time.Now()).Find(&jobs).Error; err != nil {
+		return
+	}
+	for _, job := range jobs {
+		var err error
+		if job.StorageKey != "" {
+			err = fileStorage.Delete(job.StorageKey)
+		} else if err = os.Remove(job.FilePath); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			log.Printf("删除过期导出文件失败: %v", err)
+			continue
+		}
+		models.DB.Model(&job).Update("status", models.ExportExpired)
+	}
+}
+
+// exportMessageScope 返回导出范围内的消息查询条件。已归档、已到期和无权访问的私有频道消息不导出
+func exportMessageScope(job *models.ExportJob) *gorm.DB {
+	db := notExpired(models.DB).Where("archived_at IS NULL")
+	switch job.Scope {
+	case models.ExportScopeGroup:
+		db = db.Where("group_id = ?", job.GroupID)
+		if excluded := inaccessibleChannelIDs(job.UserID, []uint{job.GroupID}); len(excluded) > 0 {
+			db = db.Where("channel_id NOT IN ?", excluded)
+		}
+	case models.ExportScopeDirect:
+		db = db.Where("group_id = 0 AND ((user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?))",
+			job.UserID, job.PeerID, job.PeerID, job.UserID)
+	default:
+		// 用户的全部数据：本人发送的所有消息以及收到的私聊
+		db = db.Where("user_id = ? OR (group_id = 0 AND target_id = ?)", job.UserID, job.UserID)
+	}
+	return db
+}
+
+// exportTitle 生成导出文件的标题
+func exportTitle(job *models.ExportJob) string {
+	switch job.Scope {
+	case models.ExportScopeGroup:
+		var group models.Group
+		if err := models.DB.Select("id, name").First(&group, job.GroupID).Error; err == nil {
+			return group.Name
+		}
+		return fmt.Sprintf("群组 %d", job.GroupID)
+	case models.ExportScopeDirect:
+		return fmt.Sprintf("与 %s 的私聊", lookupUsername(job.PeerID))
+	default:
+		return fmt.Sprintf("%s 的全部数据", lookupUsername(job.UserID))
+	}
+}
+
+// runExportJob 生成导出压缩包：聊天记录文件、附件目录，用户数据导出还包含 profile.json
+func runExportJob(job *models.ExportJob) error {
+	// 执行时重新校验权限，期间可能已退出群组
+	if gerr := authorizeExport(job.UserID, job); gerr != nil {
+		return fmt.Errorf("%s", gerr.Message)
+	}
+
+	// 先写入临时文件，完成后再保存到存储后端
+	file, err := os.CreateTemp("", "chat-export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	count, err := writeExportArchive(file, job)
+	if err != nil {
+		return err
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	token, err := utils.GenerateRandomCode(exportTokenLength)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%sexport_%d_%d.zip", exportKeyPrefix, job.ID, time.Now().UnixNano())
+	if err := fileStorage.Put(key, file, size, "application/zip"); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(exportLinkTTL())
+	job.Status = models.ExportDone
+	job.MessageCount = count
+	job.FileSize = size
+	job.StorageKey = key
+	job.DownloadToken = token
+	job.ExpiresAt = &expiresAt
+	job.CompletedAt = &now
+	err = models.DB.Model(job).Updates(map[string]interface{}{
+		"status":         job.Status,
+		"message_count":  job.MessageCount,
+		"file_size":      job.FileSize,
+		"storage_key":    job.StorageKey,
+		"download_token": job.DownloadToken,
+		"expires_at":     job.ExpiresAt,
+		"completed_at":   job.CompletedAt,
+	}).Error
+	if err != nil {
+		fileStorage.Delete(key)
+	}
+	return err
+}
+
+// writeExportArchive 将导出内容写入 zip，返回导出的消息数
+func writeExportArchive(w io.Writer, job *models.ExportJob) (int, error) {
+	zw := zip.NewWriter(w)
+
+	entry, err := zw.Create(export.FileName(job.Format))
+	if err != nil {
+		return 0, err
+	}
+	writer, err := export.New(job.Format, entry)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := writer.Begin(&export.Meta{
+		Title:      exportTitle(job),
+		Scope:      job.Scope,
+		ExportedBy: lookupUsername(job.UserID),
+		ExportedAt: time.Now(),
+	}); err != nil {
+		return 0, err
+	}
+
+	// 分批读取消息，同时记录需要打包的附件
+	attachments := make(map[string]string)
+	order := make([]string, 0)
+	count := 0
+	lastID := uint(0)
+	for {
+		var messages []models.Message
+		if err := models.DB.Where(exportMessageScope(job)).Where("id > ?", lastID).
+			Order("id asc").Limit(exportBatchSize).Find(&messages).Error; err != nil {
+			return count, err
+		}
+
+		for i := range messages {
+			attachment := ""
+			if _, ok := storageKey(messages[i].FileURL); ok {
+				attachment = "attachments/" + path.Base(messages[i].FileURL)
+				if _, seen := attachments[attachment]; !seen {
+					attachments[attachment] = messages[i].FileURL
+					order = append(order, attachment)
+				}
+			}
+			if err := writer.WriteMessage(&messages[i], attachment); err != nil {
+				return count, err
+			}
+		}
+
+		count += len(messages)
+		if len(messages) < exportBatchSize {
+			break
+		}
+		lastID = messages[len(messages)-1].ID
+		// 更新进度，便于客户端轮询
+		models.DB.Model(job).Update("message_count", count)
+	}
+
+	if err := writer.End(); err != nil {
+		return count, err
+	}
+
+	if job.Scope == models.ExportScopeUser {
+		if err := writeProfileEntry(zw, job.UserID); err != nil {
+			return count, err
+		}
+	}
+
+	for _, name := range order {
+		if err := copyIntoZip(zw, name, attachments[name]); err != nil {
+			return count, err
+		}
+	}
+
+	return count, zw.Close()
+}
+
+// writeProfileEntry 写入用户资料、好友关系和群组成员关系（不包含密码）
+func writeProfileEntry(zw *zip.Writer, userID uint) error {
+	var user models.User
+	if err := models.DB.First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	var friendships []models.Friendship
+	models.DB.Where("user_id = ? OR friend_id = ?", userID, userID).Find(&friendships)
+
+	var memberships []struct {
+		GroupID  uint      `json:"group_id"`
+		Name     string    `json:"group_name"`
+		Role     string    `json:"role"`
+		JoinedAt time.Time `json:"joined_at"`
+	}
+	models.DB.Table("group_members").
+		Select("group_members.group_id, `groups`.name, group_members.role, group_members.joined_at").
+		Joins("JOIN `groups` ON `groups`.id = group_members.group_id AND `groups`.deleted_at IS NULL").
+		Where("group_members.user_id = ?", userID).
+		Scan(&memberships)
+
+	entry, err := zw.Create("profile.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(gin.H{
+		"user": gin.H{
+			"id":         user.ID,
+			"username":   user.Username,
+			"avatar":     user.Avatar,
+			"bio":        user.Bio,
+			"status":     user.Status,
+			"last_seen":  user.LastSeen,
+			"created_at": user.CreatedAt,
+		},
+		"friendships": friendships,
+		"groups":      memberships,
+	})
+}
+
+// copyIntoZip 将上传文件复制到压缩包的指定路径，文件已不存在时跳过。
+// 尚未通过安全扫描的文件不打包，在原路径写入一段说明代替
+func copyIntoZip(zw *zip.Writer, name, fileURL string) error {
+	if attachment, err := findAttachment(fileURL); err == nil &&
+		attachment.ScanStatus != "" && attachment.ScanStatus != models.ScanClean {
+		entry, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(entry, "该文件在导出时尚未通过安全扫描，未包含在导出中。\n")
+		return err
+	}
+
+	src, err := openStoredFile(fileURL)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	defer src.Close()
+
+	entry, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, src)
+	return err
+}
@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"go-chat/models"
+	"go-chat/storage"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCopyIntoZipSkipsPendingAttachment(t *testing.T) {
+	setupTestDB(t)
+	attachment, _, _ := createPendingUpload(t)
+
+	read := func() string {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		if err := copyIntoZip(zw, "attachments/photo.png", attachment.FileURL); err != nil {
+			t.Fatalf("copyIntoZip: %v", err)
+		}
+		zw.Close()
+		zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if len(zr.File) != 1 {
+			t.Fatalf("压缩包中有 %d 个文件，want 1", len(zr.File))
+		}
+		rc, _ := zr.File[0].Open()
+		defer rc.Close()
+		data, _ := io.ReadAll(rc)
+		return string(data)
+	}
+
+	if got := read(); strings.Contains(got, "EICAR") || !strings.Contains(got, "安全扫描") {
+		t.Errorf("等待扫描的文件被打包: %q", got)
+	}
+
+	models.DB.Model(attachment).Update("scan_status", models.ScanClean)
+	if got := read(); !strings.Contains(got, "EICAR") {
+		t.Errorf("扫描通过后的文件内容 = %q", got)
+	}
+}
+
+// presignStorage 为本地存储加上预签名能力，模拟对象存储
+type presignStorage struct {
+	storage.Storage
+}
+
+func (presignStorage) PresignGet(key string, ttl time.Duration, opts storage.GetOptions) (string, error) {
+	return "https://bucket.example.com/" + key + "?ttl=" + ttl.String(), nil
+}
+
+func TestExportStoredInFileStorage(t *testing.T) {
+	setupTestDB(t)
+	group, users := createTestGroup(t, "alice", nil)
+	models.DB.Create(&models.Message{UserID: users["alice"].ID, Username: "alice", Content: "你好", GroupID: group.ID})
+
+	job := models.ExportJob{UserID: users["alice"].ID, Scope: models.ExportScopeGroup, GroupID: group.ID,
+		Format: "json", Status: models.ExportRunning}
+	models.DB.Create(&job)
+	if err := runExportJob(&job); err != nil {
+		t.Fatalf("runExportJob: %v", err)
+	}
+	if !strings.HasPrefix(job.StorageKey, exportKeyPrefix) || job.FilePath != "" || job.MessageCount != 1 {
+		t.Fatalf("job = %+v", job)
+	}
+
+	gin.SetMode(gin.TestMode)
+	download := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", fmt.Sprintf("/exports/%d/download?token=%s", job.ID, token), nil)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(job.ID)}}
+		DownloadExport(c)
+		return w
+	}
+
+	if w := download("wrong"); w.Code != http.StatusForbidden {
+		t.Errorf("错误的令牌: status = %d, want 403", w.Code)
+	}
+	w := download(job.DownloadToken)
+	if w.Code != http.StatusOK || int64(w.Body.Len()) != job.FileSize {
+		t.Fatalf("下载: status = %d, %d 字节，want 200 and %d", w.Code, w.Body.Len(), job.FileSize)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil || len(zr.File) == 0 {
+		t.Fatalf("下载的文件不是有效的压缩包: %v", err)
+	}
+
+	// 支持预签名的存储后端重定向到限时下载地址
+	local := fileStorage
+	fileStorage = presignStorage{local}
+	w = download(job.DownloadToken)
+	if loc := w.Header().Get("Location"); w.Code != http.StatusFound || !strings.Contains(loc, job.StorageKey) {
+		t.Errorf("预签名下载: status = %d, Location = %q", w.Code, loc)
+	}
+	fileStorage = local
+
+	// 链接过期后删除存储中的压缩包
+	models.DB.Model(&job).Update("expires_at", time.Now().Add(-time.Minute))
+	cleanupExpiredExports()
+	if _, err := fileStorage.Open(job.StorageKey); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("过期后读取压缩包: err = %v, want ErrNotFound", err)
+	}
+	models.DB.First(&job, job.ID)
+	if job.Status != models.ExportExpired {
+		t.Errorf("status = %q, want expired", job.Status)
+	}
+}
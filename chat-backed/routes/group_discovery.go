@@ -0,0 +1,366 @@
+package routes
+
+import (
+	"errors"
+	"go-chat/models"
+	"go-chat/search"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// discoverGroups 搜索可被发现的群组（公开群组和申请加入群组）
+func discoverGroups(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	keyword := strings.TrimSpace(c.Query("keyword"))
+
+	// 限制长度，避免滥用；按字符截断，避免截断半个中文字符
+	if len([]rune(keyword)) > 50 {
+		keyword = string([]rune(keyword)[:50])
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 50 {
+		pageSize = 50
+	}
+
+	query := models.DB.Model(&models.Group{}).
+		Where("visibility IN ?", []string{models.GroupVisibilityPublic, models.GroupVisibilityRequest})
+
+	// 使用前缀匹配，以便命中 idx_groups_name 索引
+	if keyword != "" {
+		query = query.Where("name LIKE ?", search.EscapeLike(keyword)+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索群组失败"})
+		return
+	}
+
+	var groups []models.Group
+	if err := query.Order("name").Offset((page - 1) * pageSize).Limit(pageSize).Find(&groups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索群组失败"})
+		return
+	}
+
+	// 统计成员数量以及调用者是否已加入
+	groupIDs := make([]uint, 0, len(groups))
+	for _, g := range groups {
+		groupIDs = append(groupIDs, g.ID)
+	}
+
+	memberCounts := make(map[uint]int64)
+	joined := make(map[uint]bool)
+	if len(groupIDs) > 0 {
+		var counts []struct {
+			GroupID uint
+			Count   int64
+		}
+		models.DB.Model(&models.GroupMember{}).
+			Select("group_id, COUNT(*) AS count").
+			Where("group_id IN ?", groupIDs).
+			Group("group_id").
+			Scan(&counts)
+		for _, row := range counts {
+			memberCounts[row.GroupID] = row.Count
+		}
+
+		var joinedIDs []uint
+		models.DB.Model(&models.GroupMember{}).
+			Where("user_id = ? AND group_id IN ?", userID, groupIDs).
+			Pluck("group_id", &joinedIDs)
+		for _, id := range joinedIDs {
+			joined[id] = true
+		}
+	}
+
+	list := make([]gin.H, 0, len(groups))
+	for _, g := range groups {
+		list = append(list, gin.H{
+			"id":           g.ID,
+			"name":         g.Name,
+			"avatar":       g.Avatar,
+			"description":  g.Description,
+			"visibility":   g.Visibility,
+			"member_count": memberCounts[g.ID],
+			"joined":       joined[g.ID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"groups": list,
+		"pagination": gin.H{
+			"page":     page,
+			"pageSize": pageSize,
+			"total":    total,
+			"hasNext":  int64(page*pageSize) < total,
+			"hasPrev":  page > 1,
+		},
+	})
+}
+
+// requestJoinGroup 加入公开群组，或向申请加入群组提交入群申请
+func requestJoinGroup(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	var req struct {
+		Message string `json:"message"`
+	}
+	// 申请附言为可选参数
+	c.ShouldBindJSON(&req)
+
+	var group models.Group
+	if err := models.DB.First(&group, uint(groupID)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "群组不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询群组失败"})
+		}
+		return
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	switch group.Visibility {
+	case models.GroupVisibilityPublic:
+		// 公开群组直接加入
+		member, gerr := joinGroupAs(group.ID, &user, models.RoleMember)
+		if gerr != nil {
+			c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+			return
+		}
+		recordGroupAudit(models.DB, group.ID, userID, models.AuditMemberJoin, userID, nil, gin.H{"via": "public"})
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "加入群组成功",
+			"member":  member,
+		})
+
+	case models.GroupVisibilityRequest:
+		// 被封禁的用户不能申请加入
+		if isBannedFromGroup(group.ID, userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "您已被禁止加入此群组"})
+			return
+		}
+
+		// 已是成员则无需申请
+		var existingMember models.GroupMember
+		if err := models.DB.Where("user_id = ? AND group_id = ?", userID, group.ID).First(&existingMember).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "您已经在群组中"})
+			return
+		}
+
+		// 检查是否已有待处理的申请
+		var existingRequest models.GroupJoinRequest
+		if err := models.DB.Where("group_id = ? AND user_id = ? AND status = ?", group.ID, userID, "pending").First(&existingRequest).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "已提交过入群申请，请等待审批"})
+			return
+		}
+
+		joinRequest := models.GroupJoinRequest{
+			GroupID: group.ID,
+			UserID:  userID,
+			Message: req.Message,
+			Status:  "pending",
+		}
+		if err := models.DB.Create(&joinRequest).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "提交入群申请失败"})
+			return
+		}
+
+		// 通知有审批权限的成员
+		SendToUsers(BroadcastMessage{
+			Type:      "group_join_request",
+			UserID:    userID,
+			Username:  user.Username,
+			Content:   user.Username + " 申请加入群组 " + group.Name,
+			GroupID:   group.ID,
+			CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+		}, groupMemberIDsWithPermission(group.ID, models.PermManageInvites)...)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "入群申请已提交",
+			"request": joinRequest,
+		})
+
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "该群组为私有群组，只能通过邀请加入"})
+	}
+}
+
+// getJoinRequests 获取群组的入群申请列表（需要管理邀请权限）
+func getJoinRequests(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermManageInvites); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	status := c.DefaultQuery("status", "pending")
+
+	var requests []models.GroupJoinRequest
+	if err := models.DB.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, username, avatar, status")
+	}).Where("group_id = ? AND status = ?", uint(groupID), status).
+		Order("created_at asc").
+		Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取入群申请失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"requests": requests,
+	})
+}
+
+// handleJoinRequest 审批入群申请（需要管理邀请权限）
+func handleJoinRequest(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	requestID, err := strconv.ParseUint(c.Param("requestId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的申请ID"})
+		return
+	}
+
+	operatorUserID := c.MustGet("userID").(uint)
+
+	var req struct {
+		Action string `json:"action" binding:"required"` // approve or reject
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if req.Action != "approve" && req.Action != "reject" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action只允许approve或reject"})
+		return
+	}
+
+	if _, _, gerr := authorizeGroup(operatorUserID, uint(groupID), models.PermManageInvites); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var joinRequest models.GroupJoinRequest
+	if err := models.DB.Preload("User").Where("id = ? AND group_id = ?", uint(requestID), uint(groupID)).First(&joinRequest).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "入群申请不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询入群申请失败"})
+		}
+		return
+	}
+
+	if joinRequest.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该申请已处理"})
+		return
+	}
+
+	status := "approved"
+	if req.Action == "reject" {
+		status = "rejected"
+	}
+
+	if status == "approved" && isBannedFromGroup(uint(groupID), joinRequest.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "该用户已被禁止加入此群组"})
+		return
+	}
+
+	// 更新申请状态、记录审计日志和加入群组在同一事务中完成，任一步失败时申请保持待处理。
+	// 仅更新仍处于待处理状态的申请，避免多个管理员重复审批
+	joined := false
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.GroupJoinRequest{}).
+			Where("id = ? AND status = ?", joinRequest.ID, "pending").
+			Updates(map[string]interface{}{
+				"status":      status,
+				"reviewer_id": operatorUserID,
+				"updated_at":  time.Now(),
+			})
+		if result.Error != nil {
+			return newGroupError(http.StatusInternalServerError, "处理入群申请失败")
+		}
+		if result.RowsAffected == 0 {
+			return newGroupError(http.StatusConflict, "该申请已被其他管理员处理")
+		}
+
+		recordGroupAudit(tx, uint(groupID), operatorUserID, models.AuditJoinRequestReview, joinRequest.UserID,
+			gin.H{"request_id": joinRequest.ID, "status": "pending"},
+			gin.H{"request_id": joinRequest.ID, "status": status})
+
+		if status == "approved" {
+			// 申请人已经在群组中时仅更新申请状态
+			_, gerr := joinGroupTx(tx, uint(groupID), &joinRequest.User, models.RoleMember)
+			if gerr != nil && gerr.Status != http.StatusBadRequest {
+				return gerr
+			}
+			joined = gerr == nil
+		}
+		return nil
+	})
+	if err != nil {
+		var gerr *groupError
+		if !errors.As(err, &gerr) {
+			gerr = newGroupError(http.StatusInternalServerError, "处理入群申请失败")
+		}
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+	joinRequest.Status = status
+	joinRequest.ReviewerID = operatorUserID
+	if joined {
+		broadcastMemberJoined(uint(groupID), &joinRequest.User)
+	}
+
+	// 通知申请人审批结果
+	content := "您的入群申请已通过"
+	if status == "rejected" {
+		content = "您的入群申请已被拒绝"
+	}
+	SendToUsers(BroadcastMessage{
+		Type:      "group_join_request_" + status,
+		UserID:    joinRequest.UserID,
+		Username:  joinRequest.User.Username,
+		Content:   content,
+		GroupID:   uint(groupID),
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}, joinRequest.UserID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "入群申请已处理",
+		"request": joinRequest,
+	})
+}
@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"go-chat/models"
+	"net/http"
+	"testing"
+)
+
+func TestHandleJoinRequestIsAtomic(t *testing.T) {
+	setupTestDB(t)
+	group, users := createTestGroup(t, "alice", nil)
+	carol := &models.User{Username: "carol"}
+	models.DB.Create(carol)
+	request := models.GroupJoinRequest{GroupID: group.ID, UserID: carol.ID, Status: "pending"}
+	models.DB.Create(&request)
+
+	// 添加成员失败时申请保持待处理，也不留下审计记录
+	models.DB.Exec(`CREATE TRIGGER reject_member BEFORE INSERT ON group_members
+		BEGIN SELECT RAISE(ABORT, 'rejected'); END`)
+	w := callHandler(handleJoinRequest, users["alice"].ID, `{"action": "approve"}`, "id", group.ID, "requestId", request.ID)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("添加成员失败: status = %d, want 500", w.Code)
+	}
+	models.DB.First(&request, request.ID)
+	var audits int64
+	models.DB.Model(&models.GroupAuditLog{}).Where("action = ?", models.AuditJoinRequestReview).Count(&audits)
+	if request.Status != "pending" || audits != 0 {
+		t.Errorf("回滚后 status = %q, 审计记录 %d 条，want pending and 0", request.Status, audits)
+	}
+
+	models.DB.Exec(`DROP TRIGGER reject_member`)
+	if w := callHandler(handleJoinRequest, users["alice"].ID, `{"action": "approve"}`, "id", group.ID, "requestId", request.ID); w.Code != http.StatusOK {
+		t.Fatalf("重新审批: status = %d, body = %s", w.Code, w.Body)
+	}
+	models.DB.First(&request, request.ID)
+	models.DB.Model(&models.GroupAuditLog{}).Where("action = ?", models.AuditJoinRequestReview).Count(&audits)
+	if request.Status != "approved" || audits != 1 {
+		t.Errorf("审批后 status = %q, 审计记录 %d 条，want approved and 1", request.Status, audits)
+	}
+	if _, gerr := getGroupMember(carol.ID, group.ID); gerr != nil {
+		t.Errorf("审批通过后不是群成员: %s", gerr.Message)
+	}
+}
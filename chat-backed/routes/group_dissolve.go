@@ -0,0 +1,238 @@
+package routes
+
+import (
+	"fmt"
+	"go-chat/models"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 解散群组后的默认恢复期，超过后群组数据会被永久清除
+const defaultGroupPurgeGracePeriod = 30 * 24 * time.Hour
+
+// 清除任务的执行间隔
+const groupPurgeInterval = time.Hour
+
+// 每批删除的消息数量，避免单条语句锁表过久
+const groupPurgeBatchSize = 1000
+
+// groupOwnedModels 群组永久清除时按 group_id 一并删除的关联数据
+var groupOwnedModels = []interface{}{
+	&models.GroupMember{},
+	&models.GroupJoinRequest{},
+	&models.GroupInvite{},
+	&models.GroupBan{},
+	&models.GroupRole{},
+	&models.PinnedMessage{},
+	&models.GroupChannel{},
+	&models.GroupRetentionPolicy{},
+	&models.DisappearingSetting{},
+}
+
+// groupPurgeGracePeriod 读取解散群组的恢复期（环境变量 GROUP_PURGE_GRACE_PERIOD，如 72h、30d）
+func groupPurgeGracePeriod() time.Duration {
+	if v := os.Getenv("GROUP_PURGE_GRACE_PERIOD"); v != "" {
+		if d, err := parseCommandDuration(v); err == nil {
+			return d
+		}
+		log.Printf("警告: GROUP_PURGE_GRACE_PERIOD=%s 无效，使用默认值", v)
+	}
+	return defaultGroupPurgeGracePeriod
+}
+
+// dissolveGroup 解散群组：通知成员、归档消息并软删除群组。
+// 成员关系和消息会保留到恢复期结束，期间群主可以恢复群组
+func dissolveGroup(operatorUserID uint, group *models.Group) *groupError {
+	now := time.Now()
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Message{}).
+			Where("group_id = ? AND archived_at IS NULL", group.ID).
+			Update("archived_at", now).Error; err != nil {
+			return err
+		}
+		recordGroupAudit(tx, group.ID, operatorUserID, models.AuditGroupDelete, group.ID, groupInfoSnapshot(group), nil)
+		return tx.Delete(group).Error
+	})
+	if err != nil {
+		return newGroupError(http.StatusInternalServerError, "解散群组失败")
+	}
+
+	// 成员记录仍保留，可以通过 broadcastToGroupMembers 通知到所有在线成员
+	SendBroadcastMessage(BroadcastMessage{
+		Type:      "group_dissolved",
+		UserID:    operatorUserID,
+		Username:  lookupUsername(operatorUserID),
+		Content:   fmt.Sprintf("群组 %s 已被解散", group.Name),
+		GroupID:   group.ID,
+		CreatedAt: now.Format("2006-01-02 15:04:05"),
+	})
+	return nil
+}
+
+// getDissolvedGroups 获取当前用户作为群主、仍在恢复期内的已解散群组
+func getDissolvedGroups(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	grace := groupPurgeGracePeriod()
+
+	var groups []models.Group
+	if err := models.DB.Unscoped().
+		Where("owner_id = ? AND deleted_at IS NOT NULL AND deleted_at > ?", userID, time.Now().Add(-grace)).
+		Order("deleted_at desc").
+		Find(&groups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取已解散群组失败"})
+		return
+	}
+
+	list := make([]gin.H, 0, len(groups))
+	for i := range groups {
+		list = append(list, gin.H{
+			"group":            groups[i],
+			"dissolved_at":     groups[i].DeletedAt.Time,
+			"restore_deadline": groups[i].DeletedAt.Time.Add(grace),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"groups": list,
+	})
+}
+
+// restoreGroup 在恢复期内恢复已解散的群组（仅群主）
+func restoreGroup(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	var group models.Group
+	if err := models.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", uint(groupID)).First(&group).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "群组不存在或未被解散"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询群组失败"})
+		}
+		return
+	}
+
+	if group.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足，仅群主可以恢复群组"})
+		return
+	}
+
+	if time.Since(group.DeletedAt.Time) > groupPurgeGracePeriod() {
+		c.JSON(http.StatusGone, gin.H{"error": "已超过恢复期，群组无法恢复"})
+		return
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&group).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Message{}).
+			Where("group_id = ? AND archived_at IS NOT NULL", group.ID).
+			Update("archived_at", nil).Error; err != nil {
+			return err
+		}
+		recordGroupAudit(tx, group.ID, userID, models.AuditGroupRestore, group.ID, nil, groupInfoSnapshot(&group))
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复群组失败"})
+		return
+	}
+	group.DeletedAt = gorm.DeletedAt{}
+
+	SendBroadcastMessage(BroadcastMessage{
+		Type:      "group_restored",
+		UserID:    userID,
+		Username:  lookupUsername(userID),
+		Content:   fmt.Sprintf("群组 %s 已恢复", group.Name),
+		GroupID:   group.ID,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "群组恢复成功",
+		"group":   group,
+	})
+}
+
+// StartGroupPurgeWorker 启动后台任务，定期永久清除超过恢复期的已解散群组
+func StartGroupPurgeWorker() {
+	go func() {
+		ticker := time.NewTicker(groupPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			purgeExpiredGroups()
+			<-ticker.C
+		}
+	}()
+}
+
+// purgeExpiredGroups 清除所有超过恢复期的已解散群组
+func purgeExpiredGroups() {
+	var groupIDs []uint
+	cutoff := time.Now().Add(-groupPurgeGracePeriod())
+	if err := models.DB.Unscoped().Model(&models.Group{}).
+		Where("deleted_at IS NOT NULL AND deleted_at <= ?", cutoff).
+		Pluck("id", &groupIDs).Error; err != nil {
+		log.Printf("查询待清除群组失败: %v", err)
+		return
+	}
+
+	for _, id := range groupIDs {
+		if err := purgeGroup(id); err != nil {
+			log.Printf("清除群组 %d 失败: %v", id, err)
+			continue
+		}
+		log.Printf("✅ 已永久清除群组 %d", id)
+	}
+}
+
+// purgeGroup 永久删除群组及其成员、消息和其他关联数据。审计日志只追加，不随群组删除
+func purgeGroup(groupID uint) error {
+	// 消息数量可能很大，分批删除
+	for {
+		result := models.DB.Where("group_id = ?", groupID).Limit(groupPurgeBatchSize).Delete(&models.Message{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < groupPurgeBatchSize {
+			break
+		}
+	}
+
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		// 频道成员需在删除频道之前按频道清除
+		channelIDs := tx.Model(&models.GroupChannel{}).Select("id").Where("group_id = ?", groupID)
+		if err := tx.Where("channel_id IN (?)", channelIDs).Delete(&models.GroupChannelMember{}).Error; err != nil {
+			return err
+		}
+
+		for _, model := range groupOwnedModels {
+			if err := tx.Where("group_id = ?", groupID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		announcementIDs := tx.Model(&models.GroupAnnouncement{}).Select("id").Where("group_id = ?", groupID)
+		if err := tx.Where("announcement_id IN (?)", announcementIDs).Delete(&models.AnnouncementAck{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", groupID).Delete(&models.GroupAnnouncement{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&models.Group{}, groupID).Error
+	})
+}
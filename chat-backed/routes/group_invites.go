@@ -0,0 +1,230 @@
+package routes
+
+import (
+	"go-chat/models"
+	"go-chat/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 邀请码长度
+const inviteCodeLength = 10
+
+// 邀请链接的最长有效期（秒），避免过大的值在计算过期时间时溢出
+const maxInviteExpiresIn = 365 * 24 * 60 * 60
+
+// createGroupInvite 创建群组邀请链接（需要管理邀请权限）
+func createGroupInvite(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	var req struct {
+		ExpiresIn int64 `json:"expires_in"` // 有效期（秒），0表示永不过期
+		MaxUses   int   `json:"max_uses"`   // 最大使用次数，0表示不限
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if req.ExpiresIn < 0 || req.MaxUses < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "有效期和最大使用次数不能为负数"})
+		return
+	}
+	if req.ExpiresIn > maxInviteExpiresIn {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "有效期不能超过365天"})
+		return
+	}
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermManageInvites); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	code, err := utils.GenerateRandomCode(inviteCodeLength)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成邀请码失败"})
+		return
+	}
+
+	invite := models.GroupInvite{
+		GroupID:   uint(groupID),
+		Code:      code,
+		CreatorID: userID,
+		MaxUses:   req.MaxUses,
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	if err := models.DB.Create(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建邀请链接失败"})
+		return
+	}
+
+	recordGroupAudit(models.DB, uint(groupID), userID, models.AuditInviteCreate, invite.ID, nil, gin.H{
+		"code":       invite.Code,
+		"expires_at": invite.ExpiresAt,
+		"max_uses":   invite.MaxUses,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "邀请链接创建成功",
+		"invite":  invite,
+	})
+}
+
+// getGroupInvites 获取群组的邀请链接列表（需要管理邀请权限）
+func getGroupInvites(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermManageInvites); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var invites []models.GroupInvite
+	if err := models.DB.Where("group_id = ?", uint(groupID)).Order("created_at desc").Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取邀请链接失败"})
+		return
+	}
+
+	list := make([]gin.H, 0, len(invites))
+	for i := range invites {
+		list = append(list, gin.H{
+			"invite": invites[i],
+			"usable": invites[i].IsUsable(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invites": list,
+	})
+}
+
+// revokeGroupInvite 撤销邀请链接（需要管理邀请权限）
+func revokeGroupInvite(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	inviteID, err := strconv.ParseUint(c.Param("inviteId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的邀请ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermManageInvites); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	result := models.DB.Model(&models.GroupInvite{}).
+		Where("id = ? AND group_id = ? AND revoked_at IS NULL", uint(inviteID), uint(groupID)).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销邀请链接失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "邀请链接不存在或已撤销"})
+		return
+	}
+
+	recordGroupAudit(models.DB, uint(groupID), userID, models.AuditInviteRevoke, uint(inviteID), nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "邀请链接已撤销",
+	})
+}
+
+// joinGroupByInvite 通过邀请码加入群组
+func joinGroupByInvite(c *gin.Context) {
+	code := c.Param("code")
+	userID := c.MustGet("userID").(uint)
+
+	var invite models.GroupInvite
+	if err := models.DB.Where("code = ?", code).First(&invite).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "邀请链接不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询邀请链接失败"})
+		}
+		return
+	}
+
+	if !invite.IsUsable() {
+		c.JSON(http.StatusGone, gin.H{"error": "邀请链接已失效"})
+		return
+	}
+
+	// 群组已解散时邀请链接随之失效
+	var group models.Group
+	if err := models.DB.First(&group, invite.GroupID).Error; err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "群组不存在"})
+		return
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	var existingMember models.GroupMember
+	if err := models.DB.Where("user_id = ? AND group_id = ?", userID, invite.GroupID).First(&existingMember).Error; err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "您已经在群组中"})
+		return
+	}
+
+	// 原子地占用一次使用次数，避免并发加入超出上限
+	result := models.DB.Model(&models.GroupInvite{}).
+		Where("id = ? AND revoked_at IS NULL AND (max_uses = 0 OR uses < max_uses)", invite.ID).
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "使用邀请链接失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusGone, gin.H{"error": "邀请链接已失效"})
+		return
+	}
+
+	member, gerr := joinGroupAs(invite.GroupID, &user, models.RoleMember)
+	if gerr != nil {
+		// 加入失败时归还使用次数
+		models.DB.Model(&models.GroupInvite{}).Where("id = ?", invite.ID).Update("uses", gorm.Expr("uses - 1"))
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	recordGroupAudit(models.DB, invite.GroupID, userID, models.AuditMemberJoin, userID, nil, gin.H{
+		"via":       "invite",
+		"invite_id": invite.ID,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "加入群组成功",
+		"group":   group,
+		"member":  member,
+	})
+}
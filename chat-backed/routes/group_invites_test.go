@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"go-chat/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGroupInviteMaxUses(t *testing.T) {
+	setupTestDB(t)
+	group, users := createTestGroup(t, "alice", map[string]string{"bob": models.RoleMember})
+	for _, name := range []string{"carol", "dave"} {
+		users[name] = &models.User{Username: name}
+		models.DB.Create(users[name])
+	}
+
+	// 普通成员没有管理邀请的权限
+	if w := callHandler(createGroupInvite, users["bob"].ID, `{}`, "id", group.ID); w.Code != http.StatusForbidden {
+		t.Errorf("成员创建邀请: status = %d, want 403", w.Code)
+	}
+
+	w := callHandler(createGroupInvite, users["alice"].ID, `{"expires_in": 3600, "max_uses": 1}`, "id", group.ID)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建邀请: status = %d, body = %s", w.Code, w.Body)
+	}
+	var invite models.GroupInvite
+	models.DB.First(&invite)
+	if invite.MaxUses != 1 || invite.ExpiresAt == nil || invite.ExpiresAt.Sub(time.Now()) > time.Hour {
+		t.Errorf("invite = %+v", invite)
+	}
+
+	if w := callHandler(joinGroupByInvite, users["carol"].ID, "", "code", invite.Code); w.Code != http.StatusCreated {
+		t.Fatalf("第一次使用邀请: status = %d, body = %s", w.Code, w.Body)
+	}
+	// 达到使用次数上限后失效
+	if w := callHandler(joinGroupByInvite, users["dave"].ID, "", "code", invite.Code); w.Code != http.StatusGone {
+		t.Errorf("超出使用次数: status = %d, want 410", w.Code)
+	}
+	models.DB.First(&invite, invite.ID)
+	if invite.Uses != 1 {
+		t.Errorf("uses = %d, want 1", invite.Uses)
+	}
+	var count int64
+	models.DB.Model(&models.GroupMember{}).Where("group_id = ?", group.ID).Count(&count)
+	if count != 3 {
+		t.Errorf("群成员 %d 人，want 3", count)
+	}
+}
+
+func TestGroupInviteExpiredOrRevoked(t *testing.T) {
+	setupTestDB(t)
+	group, users := createTestGroup(t, "alice", nil)
+	carol := &models.User{Username: "carol"}
+	models.DB.Create(carol)
+
+	past := time.Now().Add(-time.Minute)
+	expired := models.GroupInvite{GroupID: group.ID, Code: "expired001", CreatorID: users["alice"].ID, ExpiresAt: &past}
+	revoked := models.GroupInvite{GroupID: group.ID, Code: "revoked001", CreatorID: users["alice"].ID, RevokedAt: &past}
+	models.DB.Create(&expired)
+	models.DB.Create(&revoked)
+
+	for _, code := range []string{expired.Code, revoked.Code} {
+		if w := callHandler(joinGroupByInvite, carol.ID, "", "code", code); w.Code != http.StatusGone {
+			t.Errorf("邀请 %s: status = %d, want 410", code, w.Code)
+		}
+	}
+	if w := callHandler(joinGroupByInvite, carol.ID, "", "code", "missing001"); w.Code != http.StatusNotFound {
+		t.Errorf("不存在的邀请: status = %d, want 404", w.Code)
+	}
+
+	// 撤销接口只对未撤销的邀请生效
+	future := time.Now().Add(time.Hour)
+	active := models.GroupInvite{GroupID: group.ID, Code: "active0001", CreatorID: users["alice"].ID, ExpiresAt: &future}
+	models.DB.Create(&active)
+	if w := callHandler(revokeGroupInvite, users["alice"].ID, "", "id", group.ID, "inviteId", active.ID); w.Code != http.StatusOK {
+		t.Fatalf("撤销邀请: status = %d, body = %s", w.Code, w.Body)
+	}
+	if w := callHandler(joinGroupByInvite, carol.ID, "", "code", active.Code); w.Code != http.StatusGone {
+		t.Errorf("撤销后使用邀请: status = %d, want 410", w.Code)
+	}
+	if w := callHandler(revokeGroupInvite, users["alice"].ID, "", "id", group.ID, "inviteId", active.ID); w.Code != http.StatusNotFound {
+		t.Errorf("重复撤销: status = %d, want 404", w.Code)
+	}
+}
+
+func TestCreateGroupInviteExpiresIn(t *testing.T) {
+	setupTestDB(t)
+	group, users := createTestGroup(t, "alice", nil)
+
+	tests := map[string]int{
+		`{"expires_in": -1}`:                  http.StatusBadRequest,
+		`{"expires_in": 31536001}`:            http.StatusBadRequest,
+		`{"expires_in": 9223372036854775807}`: http.StatusBadRequest,
+		`{"expires_in": 31536000}`:            http.StatusCreated,
+		`{"expires_in": 0}`:                   http.StatusCreated,
+	}
+	for body, want := range tests {
+		if w := callHandler(createGroupInvite, users["alice"].ID, body, "id", group.ID); w.Code != want {
+			t.Errorf("%s: status = %d, want %d", body, w.Code, want)
+		}
+	}
+}
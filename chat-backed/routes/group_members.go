@@ -0,0 +1,227 @@
+package routes
+
+import (
+	"fmt"
+	"go-chat/models"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// groupError 群组操作失败时返回给调用方的错误（携带HTTP状态码）
+type groupError struct {
+	Status  int
+	Message string
+}
+
+func (e *groupError) Error() string {
+	return e.Message
+}
+
+func newGroupError(status int, message string) *groupError {
+	return &groupError{Status: status, Message: message}
+}
+
+// getGroupMember 查询用户在群组中的成员记录，已解散的群组视为不存在
+func getGroupMember(userID, groupID uint) (*models.GroupMember, *groupError) {
+	var member models.GroupMember
+	if err := models.DB.Joins("JOIN `groups` ON `groups`.id = group_members.group_id AND `groups`.deleted_at IS NULL").
+		Where("group_members.user_id = ? AND group_members.group_id = ?", userID, groupID).
+		First(&member).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, newGroupError(http.StatusForbidden, "您不是该群组成员")
+		}
+		return nil, newGroupError(http.StatusInternalServerError, "查询权限失败")
+	}
+	return &member, nil
+}
+
+// addMemberToGroup 由邀请者将目标用户加入群组，并广播成员加入通知
+func addMemberToGroup(inviterUserID, groupID, targetUserID uint, role string) (*models.GroupMember, *groupError) {
+	// 校验邀请者拥有邀请权限
+	_, inviterRole, gerr := authorizeGroup(inviterUserID, groupID, models.PermInvite)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	// 设置默认角色
+	if role == "" {
+		role = models.RoleMember
+	}
+
+	// 以非普通成员角色邀请时，需要角色管理权限，且只能授予低于自己等级的角色
+	if role != models.RoleMember {
+		if gerr := checkAssignRole(inviterRole, groupID, role); gerr != nil {
+			return nil, gerr
+		}
+	}
+
+	// 检查被邀请用户是否存在
+	var targetUser models.User
+	if err := models.DB.First(&targetUser, targetUserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, newGroupError(http.StatusBadRequest, "被邀请的用户不存在")
+		}
+		return nil, newGroupError(http.StatusInternalServerError, "查询用户失败")
+	}
+
+	member, gerr := joinGroupAs(groupID, &targetUser, role)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	recordGroupAudit(models.DB, groupID, inviterUserID, models.AuditMemberAdd, targetUserID, nil, map[string]interface{}{"role": role})
+	return member, nil
+}
+
+// joinGroupAs 将用户以指定角色加入群组，并广播成员加入通知
+func joinGroupAs(groupID uint, user *models.User, role string) (*models.GroupMember, *groupError) {
+	member, gerr := joinGroupTx(models.DB, groupID, user, role)
+	if gerr != nil {
+		return nil, gerr
+	}
+	broadcastMemberJoined(groupID, user)
+	return member, nil
+}
+
+// joinGroupTx 在给定的数据库会话（可以是事务）中将用户以指定角色加入群组，
+// 不发送通知，调用方在事务提交后调用 broadcastMemberJoined
+func joinGroupTx(tx *gorm.DB, groupID uint, user *models.User, role string) (*models.GroupMember, *groupError) {
+	// 被封禁的用户不能重新加入
+	var banned int64
+	tx.Model(&models.GroupBan{}).Where("group_id = ? AND user_id = ?", groupID, user.ID).Count(&banned)
+	if banned > 0 {
+		return nil, newGroupError(http.StatusForbidden, "该用户已被禁止加入此群组")
+	}
+
+	// 检查用户是否已经在群组中
+	var existingMember models.GroupMember
+	if err := tx.Where("user_id = ? AND group_id = ?", user.ID, groupID).First(&existingMember).Error; err == nil {
+		return nil, newGroupError(http.StatusBadRequest, "用户已经在群组中")
+	}
+
+	// 创建群成员记录
+	member := models.GroupMember{
+		UserID:  user.ID,
+		GroupID: groupID,
+		Role:    role,
+	}
+
+	if err := tx.Create(&member).Error; err != nil {
+		return nil, newGroupError(http.StatusInternalServerError, "添加群成员失败")
+	}
+	return &member, nil
+}
+
+// broadcastMemberJoined 广播群成员加入通知
+func broadcastMemberJoined(groupID uint, user *models.User) {
+	memberJoinedMsg := BroadcastMessage{
+		Type:      "group_member_joined",
+		UserID:    user.ID,
+		Username:  user.Username,
+		Content:   user.Username + " 加入了群组",
+		GroupID:   groupID,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	SendBroadcastMessage(memberJoinedMsg)
+}
+
+// removeMemberFromGroup 由操作者将目标用户移出群组（ban 为 true 时同时封禁），并广播成员离开通知
+func removeMemberFromGroup(operatorUserID, groupID, targetUserID uint, ban bool, reason string) *groupError {
+	// 检查操作者权限
+	_, operatorRole, gerr := authorizeGroup(operatorUserID, groupID, models.PermKick)
+	if gerr != nil {
+		return gerr
+	}
+
+	// 查找要移除的群成员记录并预加载用户信息用于通知
+	var member models.GroupMember
+	if err := models.DB.Preload("User").Where("user_id = ? AND group_id = ?", targetUserID, groupID).First(&member).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return newGroupError(http.StatusNotFound, "成员不存在")
+		}
+		return newGroupError(http.StatusInternalServerError, "查询成员失败")
+	}
+
+	// 检查是否是群主，群主不能被移除（需要先转让群组）
+	if member.Role == models.RoleOwner {
+		return newGroupError(http.StatusBadRequest, "群主不能被移除，请先转让群组")
+	}
+
+	// 只能移除角色等级低于自己的成员
+	if gerr := checkManageTarget(operatorRole, &member); gerr != nil {
+		return gerr
+	}
+
+	// 开始数据库事务
+	tx := models.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Delete(&member).Error; err != nil {
+		tx.Rollback()
+		return newGroupError(http.StatusInternalServerError, "移除成员失败")
+	}
+
+	// 同时移出该群组的私有频道
+	groupChannels := tx.Model(&models.GroupChannel{}).Select("id").Where("group_id = ?", groupID)
+	if err := tx.Where("user_id = ? AND channel_id IN (?)", targetUserID, groupChannels).Delete(&models.GroupChannelMember{}).Error; err != nil {
+		tx.Rollback()
+		return newGroupError(http.StatusInternalServerError, "移除成员失败")
+	}
+
+	// 封禁后无法通过邀请、申请等方式重新加入
+	if ban {
+		groupBan := models.GroupBan{
+			GroupID:    groupID,
+			UserID:     targetUserID,
+			OperatorID: operatorUserID,
+			Reason:     reason,
+		}
+		if err := tx.Save(&groupBan).Error; err != nil {
+			tx.Rollback()
+			return newGroupError(http.StatusInternalServerError, "封禁成员失败")
+		}
+		recordGroupAudit(tx, groupID, operatorUserID, models.AuditMemberBan, targetUserID,
+			map[string]interface{}{"role": member.Role}, map[string]interface{}{"reason": reason})
+	} else {
+		recordGroupAudit(tx, groupID, operatorUserID, models.AuditMemberRemove, targetUserID,
+			map[string]interface{}{"role": member.Role}, nil)
+	}
+
+	// 提交事务
+	tx.Commit()
+
+	// 发送群成员离开通知
+	memberLeftMsg := BroadcastMessage{
+		Type:      "group_member_left",
+		UserID:    targetUserID,
+		Username:  member.User.Username,
+		Content:   member.User.Username + " 离开了群组",
+		GroupID:   groupID,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	SendBroadcastMessage(memberLeftMsg)
+
+	operatorName := lookupUsername(operatorUserID)
+	if ban {
+		sendGroupSystemMessage(groupID, fmt.Sprintf("%s 被 %s 移出群组并封禁", member.User.Username, operatorName))
+	} else {
+		sendGroupSystemMessage(groupID, fmt.Sprintf("%s 被 %s 移出了群组", member.User.Username, operatorName))
+	}
+
+	return nil
+}
+
+// lookupUsername 查询用户名，查询失败时返回空字符串
+func lookupUsername(userID uint) string {
+	var user models.User
+	if err := models.DB.Select("id, username").First(&user, userID).Error; err != nil {
+		return ""
+	}
+	return user.Username
+}
@@ -0,0 +1,304 @@
+package routes
+
+import (
+	"go-chat/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// roleRequest 创建或修改自定义角色的请求参数
+type roleRequest struct {
+	Name        string   `json:"name"`
+	Level       int      `json:"level"`
+	Permissions []string `json:"permissions"`
+}
+
+// validateRoleRequest 校验自定义角色参数：名称不能与内置角色冲突，
+// 等级需低于操作者，权限只能是操作者自身拥有的可分配权限
+func validateRoleRequest(operatorRole *models.RoleInfo, req *roleRequest) *groupError {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len([]rune(req.Name)) > 32 {
+		return newGroupError(http.StatusBadRequest, "角色名长度需在1到32个字符之间")
+	}
+	if models.IsBuiltinRole(req.Name) {
+		return newGroupError(http.StatusBadRequest, "角色名不能与内置角色相同")
+	}
+	if req.Level < 1 || req.Level >= models.RoleLevelOwner {
+		return newGroupError(http.StatusBadRequest, "角色等级需在1到99之间")
+	}
+	if req.Level >= operatorRole.Level {
+		return newGroupError(http.StatusForbidden, "只能创建等级低于自己的角色")
+	}
+	for _, p := range req.Permissions {
+		if !models.IsAssignablePermission(p) {
+			return newGroupError(http.StatusBadRequest, "无效的权限: "+p)
+		}
+		if !operatorRole.Has(p) {
+			return newGroupError(http.StatusForbidden, "不能授予自己没有的权限: "+p)
+		}
+	}
+	return nil
+}
+
+// roleResponse 构造角色的响应数据
+func roleResponse(info *models.RoleInfo, id uint) gin.H {
+	return gin.H{
+		"id":          id,
+		"name":        info.Name,
+		"level":       info.Level,
+		"builtin":     info.Builtin,
+		"permissions": info.PermissionList(),
+	}
+}
+
+// roleSnapshot 自定义角色快照，用于审计日志记录修改前后的值
+func roleSnapshot(role *models.GroupRole) gin.H {
+	return gin.H{
+		"name":        role.Name,
+		"level":       role.Level,
+		"permissions": role.PermissionList(),
+	}
+}
+
+// getGroupRoles 获取群组的角色列表（内置角色和自定义角色）以及当前用户的权限
+func getGroupRoles(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	_, myRole, gerr := authorizeGroup(userID, uint(groupID), "")
+	if gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var customRoles []models.GroupRole
+	if err := models.DB.Where("group_id = ?", uint(groupID)).Order("level desc, id asc").Find(&customRoles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色列表失败"})
+		return
+	}
+
+	roles := []gin.H{
+		roleResponse(models.BuiltinRoleInfo(models.RoleOwner), 0),
+		roleResponse(models.BuiltinRoleInfo(models.RoleAdmin), 0),
+	}
+	for i := range customRoles {
+		roles = append(roles, roleResponse(models.ResolveRole(uint(groupID), customRoles[i].Name), customRoles[i].ID))
+	}
+	roles = append(roles, roleResponse(models.BuiltinRoleInfo(models.RoleMember), 0))
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles":   roles,
+		"my_role": roleResponse(myRole, 0),
+	})
+}
+
+// createGroupRole 创建自定义角色（需要管理角色权限）
+func createGroupRole(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	_, operatorRole, gerr := authorizeGroup(userID, uint(groupID), models.PermManageRoles)
+	if gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	if gerr := validateRoleRequest(operatorRole, &req); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var count int64
+	models.DB.Model(&models.GroupRole{}).Where("group_id = ? AND name = ?", uint(groupID), req.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "角色名已存在"})
+		return
+	}
+
+	role := models.GroupRole{
+		GroupID: uint(groupID),
+		Name:    req.Name,
+		Level:   req.Level,
+	}
+	role.SetPermissions(req.Permissions)
+
+	if err := models.DB.Create(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建角色失败"})
+		return
+	}
+
+	recordGroupAudit(models.DB, role.GroupID, userID, models.AuditRoleCreate, role.ID, nil, roleSnapshot(&role))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "角色创建成功",
+		"role":    roleResponse(models.ResolveRole(role.GroupID, role.Name), role.ID),
+	})
+}
+
+// updateGroupRole 修改自定义角色（需要管理角色权限）
+func updateGroupRole(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	roleID, err := strconv.ParseUint(c.Param("roleId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	_, operatorRole, gerr := authorizeGroup(userID, uint(groupID), models.PermManageRoles)
+	if gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var role models.GroupRole
+	if err := models.DB.Where("id = ? AND group_id = ?", uint(roleID), uint(groupID)).First(&role).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询角色失败"})
+		}
+		return
+	}
+
+	// 只能修改等级低于自己的角色
+	if role.Level >= operatorRole.Level {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权修改等级不低于自己的角色"})
+		return
+	}
+
+	if gerr := validateRoleRequest(operatorRole, &req); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	before := roleSnapshot(&role)
+	oldName := role.Name
+	if req.Name != oldName {
+		var count int64
+		models.DB.Model(&models.GroupRole{}).Where("group_id = ? AND name = ?", uint(groupID), req.Name).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "角色名已存在"})
+			return
+		}
+	}
+
+	role.Name = req.Name
+	role.Level = req.Level
+	role.SetPermissions(req.Permissions)
+
+	// 角色重命名时同步更新成员记录
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&role).Error; err != nil {
+			return err
+		}
+		if oldName != role.Name {
+			return tx.Model(&models.GroupMember{}).
+				Where("group_id = ? AND role = ?", uint(groupID), oldName).
+				Update("role", role.Name).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改角色失败"})
+		return
+	}
+
+	recordGroupAudit(models.DB, role.GroupID, userID, models.AuditRoleUpdate, role.ID, before, roleSnapshot(&role))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "角色修改成功",
+		"role":    roleResponse(models.ResolveRole(role.GroupID, role.Name), role.ID),
+	})
+}
+
+// deleteGroupRole 删除自定义角色，拥有该角色的成员恢复为普通成员（需要管理角色权限）
+func deleteGroupRole(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	roleID, err := strconv.ParseUint(c.Param("roleId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	_, operatorRole, gerr := authorizeGroup(userID, uint(groupID), models.PermManageRoles)
+	if gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var role models.GroupRole
+	if err := models.DB.Where("id = ? AND group_id = ?", uint(roleID), uint(groupID)).First(&role).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询角色失败"})
+		}
+		return
+	}
+
+	if role.Level >= operatorRole.Level {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权删除等级不低于自己的角色"})
+		return
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.GroupMember{}).
+			Where("group_id = ? AND role = ?", uint(groupID), role.Name).
+			Update("role", models.RoleMember).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&role).Error; err != nil {
+			return err
+		}
+		recordGroupAudit(tx, role.GroupID, userID, models.AuditRoleDelete, role.ID, roleSnapshot(&role), nil)
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除角色失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "角色已删除",
+	})
+}
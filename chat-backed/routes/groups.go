@@ -28,6 +28,10 @@ func GroupsRoutes(r *gin.Engine) {
 		groups.PUT("/:id", updateGroup)     // 更新特定群组的信息
 		groups.DELETE("/:id", deleteGroup)  // 解散特定群组
 
+		// 已解散群组的查看与恢复
+		groups.GET("/dissolved", getDissolvedGroups) // 获取恢复期内的已解散群组
+		groups.POST("/:id/restore", restoreGroup)    // 恢复已解散的群组
+
 		// 群成员管理路由
 		groups.GET("/:id/members", getGroupMembers)               // 获取群成员列表
 		groups.POST("/:id/members", addGroupMember)               // 添加（邀请）新成员
@@ -35,9 +39,64 @@ func GroupsRoutes(r *gin.Engine) {
 		groups.PUT("/:id/members/:userId/role", updateMemberRole) // 修改成员角色
 		groups.POST("/:id/transfer-owner", transferOwner)         // 转让群主
 
+		// 自定义角色路由
+		groups.GET("/:id/roles", getGroupRoles)              // 获取角色列表及当前用户权限
+		groups.POST("/:id/roles", createGroupRole)           // 创建自定义角色
+		groups.PUT("/:id/roles/:roleId", updateGroupRole)    // 修改自定义角色
+		groups.DELETE("/:id/roles/:roleId", deleteGroupRole) // 删除自定义角色
+
+		// 审计日志路由
+		groups.GET("/:id/audit-log", getGroupAuditLog) // 获取群组审计日志
+
+		// 消息保留策略路由
+		groups.GET("/:id/retention", getGroupRetention)       // 获取群组消息保留策略
+		groups.PUT("/:id/retention", updateGroupRetention)    // 设置群组消息保留策略
+		groups.DELETE("/:id/retention", deleteGroupRetention) // 删除群组策略，恢复使用全局策略
+
+		// 阅后即焚路由
+		groups.GET("/:id/disappearing", getGroupDisappearing)    // 获取群组阅后即焚设置
+		groups.PUT("/:id/disappearing", updateGroupDisappearing) // 开启、修改或关闭群组阅后即焚（仅群主）
+
+		// 置顶消息与群公告路由
+		groups.POST("/:id/pins", pinGroupMessage)                                      // 置顶消息
+		groups.GET("/:id/pins", getPinnedMessages)                                     // 获取置顶消息列表
+		groups.DELETE("/:id/pins/:messageId", unpinGroupMessage)                       // 取消置顶
+		groups.POST("/:id/announcements", createAnnouncement)                          // 发布群公告
+		groups.GET("/:id/announcements", getAnnouncements)                             // 获取群公告列表
+		groups.POST("/:id/announcements/:announcementId/ack", acknowledgeAnnouncement) // 确认群公告
+		groups.GET("/:id/announcements/:announcementId/acks", getAnnouncementAcks)     // 查看公告确认情况
+
+		// 群管理（禁言、封禁、慢速模式）路由
+		groups.POST("/:id/members/:userId/mute", muteMember)     // 禁言成员
+		groups.DELETE("/:id/members/:userId/mute", unmuteMember) // 解除禁言
+		groups.GET("/:id/bans", getGroupBans)                    // 获取封禁列表
+		groups.DELETE("/:id/bans/:userId", unbanMember)          // 解除封禁
+		groups.PUT("/:id/slow-mode", updateSlowMode)             // 设置慢速模式
+
 		// 群消息管理路由
 		groups.GET("/:id/messages", getGroupMessages)       // 获取群聊消息历史
 		groups.GET("/:id/online-members", getOnlineMembers) // 获取群在线成员
+
+		// 频道路由
+		groups.GET("/:id/channels", getGroupChannels)                                  // 获取频道列表
+		groups.POST("/:id/channels", createGroupChannel)                               // 创建频道
+		groups.PUT("/:id/channels/:channelId", updateGroupChannel)                     // 修改频道
+		groups.DELETE("/:id/channels/:channelId", deleteGroupChannel)                  // 删除频道
+		groups.GET("/:id/channels/:channelId/members", getChannelMembers)              // 获取私有频道成员
+		groups.POST("/:id/channels/:channelId/members", addChannelMember)              // 添加私有频道成员
+		groups.DELETE("/:id/channels/:channelId/members/:userId", removeChannelMember) // 移除私有频道成员
+
+		// 群组发现与入群申请路由
+		groups.GET("/discover", discoverGroups)                                // 搜索公开群组
+		groups.POST("/:id/join", requestJoinGroup)                             // 加入或申请加入群组
+		groups.GET("/:id/join-requests", getJoinRequests)                      // 获取入群申请列表
+		groups.POST("/:id/join-requests/:requestId/action", handleJoinRequest) // 审批入群申请
+
+		// 邀请链接路由
+		groups.POST("/:id/invites", createGroupInvite)             // 创建邀请链接
+		groups.GET("/:id/invites", getGroupInvites)                // 获取邀请链接列表
+		groups.DELETE("/:id/invites/:inviteId", revokeGroupInvite) // 撤销邀请链接
+		groups.POST("/join/:code", joinGroupByInvite)              // 通过邀请码加入群组
 	}
 }
 
@@ -47,6 +106,7 @@ func createGroup(c *gin.Context) {
 		Name        string `json:"name" binding:"required"`
 		Description string `json:"description"`
 		Avatar      string `json:"avatar"`
+		Visibility  string `json:"visibility"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -54,6 +114,15 @@ func createGroup(c *gin.Context) {
 		return
 	}
 
+	// 默认创建私有群组
+	if req.Visibility == "" {
+		req.Visibility = models.GroupVisibilityPrivate
+	}
+	if !models.IsValidGroupVisibility(req.Visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "可见性只允许public、request或private"})
+		return
+	}
+
 	// 从上下文获取用户ID
 	userID := c.MustGet("userID").(uint)
 
@@ -71,6 +140,7 @@ func createGroup(c *gin.Context) {
 		OwnerID:     userID,
 		Avatar:      req.Avatar,
 		Description: req.Description,
+		Visibility:  req.Visibility,
 	}
 
 	if err := tx.Create(&group).Error; err != nil {
@@ -83,7 +153,7 @@ func createGroup(c *gin.Context) {
 	groupMember := models.GroupMember{
 		UserID:  userID,
 		GroupID: group.ID,
-		Role:    "owner",
+		Role:    models.RoleOwner,
 	}
 
 	if err := tx.Create(&groupMember).Error; err != nil {
@@ -92,6 +162,8 @@ func createGroup(c *gin.Context) {
 		return
 	}
 
+	recordGroupAudit(tx, group.ID, userID, models.AuditGroupCreate, group.ID, nil, groupInfoSnapshot(&group))
+
 	// 提交事务
 	tx.Commit()
 
@@ -142,6 +214,14 @@ func getGroupDetails(c *gin.Context) {
 		return
 	}
 
+	// 私有群组仅成员可查看详情
+	if group.Visibility == models.GroupVisibilityPrivate {
+		if _, _, gerr := authorizeGroup(c.MustGet("userID").(uint), group.ID, ""); gerr != nil {
+			c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"group": group,
 	})
@@ -159,6 +239,7 @@ func updateGroup(c *gin.Context) {
 		Name        string `json:"name"`
 		Description string `json:"description"`
 		Avatar      string `json:"avatar"`
+		Visibility  string `json:"visibility"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -166,6 +247,11 @@ func updateGroup(c *gin.Context) {
 		return
 	}
 
+	if req.Visibility != "" && !models.IsValidGroupVisibility(req.Visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "可见性只允许public、request或private"})
+		return
+	}
+
 	// 从上下文获取用户ID
 	userID := c.MustGet("userID").(uint)
 
@@ -181,20 +267,12 @@ func updateGroup(c *gin.Context) {
 	}
 
 	// 检查操作者权限（仅群主和管理员可以更新群组信息）
-	var member models.GroupMember
-	if err := models.DB.Where("user_id = ? AND group_id = ?", userID, uint(groupID)).First(&member).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该群组成员"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询权限失败"})
-		}
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermEditInfo); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
 		return
 	}
 
-	if member.Role != "owner" && member.Role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足，仅群主和管理员可以更新群组信息"})
-		return
-	}
+	before := groupInfoSnapshot(&group)
 
 	// 更新群组信息
 	updateData := models.Group{}
@@ -207,12 +285,17 @@ func updateGroup(c *gin.Context) {
 	if req.Avatar != "" {
 		updateData.Avatar = req.Avatar
 	}
+	if req.Visibility != "" {
+		updateData.Visibility = req.Visibility
+	}
 
 	if err := models.DB.Model(&group).Updates(updateData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新群组失败"})
 		return
 	}
 
+	recordGroupAudit(models.DB, group.ID, userID, models.AuditGroupUpdate, group.ID, before, groupInfoSnapshot(&group))
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "群组更新成功",
 		"group":   group,
@@ -230,18 +313,15 @@ func deleteGroup(c *gin.Context) {
 	// 从上下文获取用户ID
 	userID := c.MustGet("userID").(uint)
 
-	// 开始数据库事务
-	tx := models.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+	// 检查操作者权限（仅群主拥有解散权限）
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermDissolve); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
 
 	// 检查群组是否存在
 	var group models.Group
-	if err := tx.First(&group, uint(groupID)).Error; err != nil {
-		tx.Rollback()
+	if err := models.DB.First(&group, uint(groupID)).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "群组不存在"})
 		} else {
@@ -250,43 +330,15 @@ func deleteGroup(c *gin.Context) {
 		return
 	}
 
-	// 检查操作者权限（仅群主可以解散群组）
-	var member models.GroupMember
-	if err := tx.Where("user_id = ? AND group_id = ?", userID, uint(groupID)).First(&member).Error; err != nil {
-		tx.Rollback()
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该群组成员"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询权限失败"})
-		}
+	// 解散后保留成员和消息，恢复期内群主可以恢复群组
+	if gerr := dissolveGroup(userID, &group); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
 		return
 	}
 
-	if member.Role != "owner" {
-		tx.Rollback()
-		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足，仅群主可以解散群组"})
-		return
-	}
-
-	// 删除所有群成员记录
-	if err := tx.Where("group_id = ?", uint(groupID)).Delete(&models.GroupMember{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除群成员失败"})
-		return
-	}
-
-	// 删除群组记录
-	if err := tx.Delete(&group).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除群组失败"})
-		return
-	}
-
-	// 提交事务
-	tx.Commit()
-
 	c.JSON(http.StatusOK, gin.H{
-		"message": "群组解散成功",
+		"message":          "群组解散成功",
+		"restore_deadline": time.Now().Add(groupPurgeGracePeriod()),
 	})
 }
 
@@ -298,6 +350,15 @@ func getGroupMembers(c *gin.Context) {
 		return
 	}
 
+	// 从上下文获取用户ID
+	userID := c.MustGet("userID").(uint)
+
+	// 校验当前用户是否为该群成员
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), ""); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
 	var members []models.GroupMember
 	err = models.DB.Preload("User").Where("group_id = ?", uint(groupID)).Find(&members).Error
 	if err != nil {
@@ -321,17 +382,6 @@ func addGroupMember(c *gin.Context) {
 	// 从上下文获取邀请者用户ID
 	inviterUserID := c.MustGet("userID").(uint)
 
-	// 校验邀请者必须是该群成员
-	var inviterMember models.GroupMember
-	if err := models.DB.Where("user_id = ? AND group_id = ?", inviterUserID, uint(groupID)).First(&inviterMember).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该群组成员，无权邀请新成员"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "验证邀请者权限失败"})
-		}
-		return
-	}
-
 	var req struct {
 		UserID uint   `json:"user_id" binding:"required"`
 		Role   string `json:"role"`
@@ -342,58 +392,12 @@ func addGroupMember(c *gin.Context) {
 		return
 	}
 
-	// 设置默认角色
-	if req.Role == "" {
-		req.Role = "member"
-	}
-
-	// 邀请成员权限强化：当req.Role == "admin"时，仅允许当前操作者角色为owner
-	if req.Role == "admin" && inviterMember.Role != "owner" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "仅群主可授予管理员"})
-		return
-	}
-
-	// 检查被邀请用户是否存在
-	var targetUser models.User
-	if err := models.DB.First(&targetUser, req.UserID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "被邀请的用户不存在"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
-		}
-		return
-	}
-
-	// 检查用户是否已经在群组中
-	var existingMember models.GroupMember
-	if err := models.DB.Where("user_id = ? AND group_id = ?", req.UserID, uint(groupID)).First(&existingMember).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "用户已经在群组中"})
+	member, gerr := addMemberToGroup(inviterUserID, uint(groupID), req.UserID, req.Role)
+	if gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
 		return
 	}
 
-	// 创建群成员记录
-	member := models.GroupMember{
-		UserID:  req.UserID,
-		GroupID: uint(groupID),
-		Role:    req.Role,
-	}
-
-	if err := models.DB.Create(&member).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加群成员失败"})
-		return
-	}
-
-	// 发送群成员加入通知
-	memberJoinedMsg := BroadcastMessage{
-		Type:      "group_member_joined",
-		UserID:    req.UserID,
-		Username:  targetUser.Username,
-		Content:   targetUser.Username + " 加入了群组",
-		GroupID:   uint(groupID),
-		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
-	}
-	SendBroadcastMessage(memberJoinedMsg)
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "成员添加成功",
 		"member":  member,
@@ -417,62 +421,15 @@ func removeGroupMember(c *gin.Context) {
 	// 从上下文获取操作者用户ID
 	operatorUserID := c.MustGet("userID").(uint)
 
-	// 查找操作者在群组中的权限
-	var operatorMember models.GroupMember
-	if err := models.DB.Where("user_id = ? AND group_id = ?", operatorUserID, uint(groupID)).First(&operatorMember).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该群组成员"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询权限失败"})
-		}
-		return
-	}
+	// ban=true 时同时封禁，阻止其通过邀请或申请重新加入
+	ban := c.Query("ban") == "true"
+	reason := c.Query("reason")
 
-	// 检查操作者权限（仅群主和管理员可以移除成员）
-	if operatorMember.Role != "owner" && operatorMember.Role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足，仅群主和管理员可以移除成员"})
+	if gerr := removeMemberFromGroup(operatorUserID, uint(groupID), uint(targetUserID), ban, reason); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
 		return
 	}
 
-	// 查找要移除的群成员记录并预加载用户信息用于通知
-	var member models.GroupMember
-	if err := models.DB.Preload("User").Where("user_id = ? AND group_id = ?", uint(targetUserID), uint(groupID)).First(&member).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "成员不存在"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询成员失败"})
-		}
-		return
-	}
-
-	// 检查是否是群主，群主不能被移除（需要先转让群组）
-	if member.Role == "owner" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "群主不能被移除，请先转让群组"})
-		return
-	}
-
-	// 管理员不能移除其他管理员，只有群主可以
-	if operatorMember.Role == "admin" && member.Role == "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "管理员无权移除其他管理员"})
-		return
-	}
-
-	if err := models.DB.Delete(&member).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "移除成员失败"})
-		return
-	}
-
-	// 发送群成员离开通知
-	memberLeftMsg := BroadcastMessage{
-		Type:      "group_member_left",
-		UserID:    uint(targetUserID),
-		Username:  member.User.Username,
-		Content:   member.User.Username + " 离开了群组",
-		GroupID:   uint(groupID),
-		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
-	}
-	SendBroadcastMessage(memberLeftMsg)
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "成员移除成功",
 	})
@@ -489,14 +446,16 @@ func getGroupMessages(c *gin.Context) {
 	// 从上下文获取用户ID
 	userID := c.MustGet("userID").(uint)
 
-	// 校验当前用户是否为该群成员
-	var member models.GroupMember
-	if err := models.DB.Where("user_id = ? AND group_id = ?", userID, uint(groupID)).First(&member).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该群组成员"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "验证群组成员身份失败"})
-		}
+	// 频道ID，缺省为默认频道
+	channelID, err := strconv.ParseUint(c.DefaultQuery("channel_id", "0"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的频道ID"})
+		return
+	}
+
+	// 校验当前用户是否为该群成员，且能访问该频道
+	if _, _, _, gerr := authorizeChannel(userID, uint(groupID), uint(channelID), ""); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
 		return
 	}
 
@@ -526,13 +485,13 @@ func getGroupMessages(c *gin.Context) {
 	var total int64
 
 	// 获取群组消息总数
-	if err := models.DB.Model(&models.Message{}).Where("group_id = ?", uint(groupID)).Count(&total).Error; err != nil {
+	if err := notExpired(models.DB.Model(&models.Message{})).Where("group_id = ? AND channel_id = ?", uint(groupID), uint(channelID)).Count(&total).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取消息总数失败"})
 		return
 	}
 
 	// 按创建时间降序获取群组消息
-	if err := models.DB.Where("group_id = ?", uint(groupID)).Order("created_at desc").Offset(offset).Limit(pageSize).Find(&messages).Error; err != nil {
+	if err := notExpired(models.DB).Where("group_id = ? AND channel_id = ?", uint(groupID), uint(channelID)).Order("created_at desc").Offset(offset).Limit(pageSize).Find(&messages).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取群组消息失败"})
 		return
 	}
@@ -574,13 +533,8 @@ func getOnlineMembers(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
 
 	// 校验当前用户是否为该群成员
-	var member models.GroupMember
-	if err := models.DB.Where("user_id = ? AND group_id = ?", userID, uint(groupID)).First(&member).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该群组成员"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "验证群组成员身份失败"})
-		}
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), ""); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
 		return
 	}
 
@@ -642,26 +596,10 @@ func updateMemberRole(c *gin.Context) {
 		return
 	}
 
-	// 校验role参数
-	if req.Role != "admin" && req.Role != "member" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "角色只允许admin或member"})
-		return
-	}
-
 	// 查找操作者在群组中的权限
-	var operatorMember models.GroupMember
-	if err := models.DB.Where("user_id = ? AND group_id = ?", operatorUserID, uint(groupID)).First(&operatorMember).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该群组成员"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询权限失败"})
-		}
-		return
-	}
-
-	// 仅群主可操作
-	if operatorMember.Role != "owner" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足，仅群主可操作"})
+	_, operatorRole, gerr := authorizeGroup(operatorUserID, uint(groupID), models.PermManageRoles)
+	if gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
 		return
 	}
 
@@ -676,18 +614,26 @@ func updateMemberRole(c *gin.Context) {
 		return
 	}
 
-	// 不可修改群主
-	if targetMember.Role == "owner" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "不可修改群主角色"})
+	// 只能修改等级低于自己的成员，并分配等级低于自己的角色
+	if gerr := checkManageTarget(operatorRole, &targetMember); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+	if gerr := checkAssignRole(operatorRole, uint(groupID), req.Role); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
 		return
 	}
 
 	// 更新成员角色
+	oldRole := targetMember.Role
 	if err := models.DB.Model(&targetMember).Update("role", req.Role).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新成员角色失败"})
 		return
 	}
 
+	recordGroupAudit(models.DB, uint(groupID), operatorUserID, models.AuditMemberRoleChange, uint(targetUserID),
+		gin.H{"role": oldRole}, gin.H{"role": req.Role})
+
 	// 重新加载更新后的数据
 	if err := models.DB.Preload("User").Where("user_id = ? AND group_id = ?", uint(targetUserID), uint(groupID)).First(&targetMember).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取更新后成员信息失败"})
@@ -720,6 +666,13 @@ func transferOwner(c *gin.Context) {
 		return
 	}
 
+	// 查找操作者在群组中的权限（仅群主拥有转让权限）
+	operatorMember, _, gerr := authorizeGroup(operatorUserID, uint(groupID), models.PermTransferOwner)
+	if gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
 	// 开始数据库事务
 	tx := models.DB.Begin()
 	defer func() {
@@ -728,25 +681,6 @@ func transferOwner(c *gin.Context) {
 		}
 	}()
 
-	// 查找操作者在群组中的权限
-	var operatorMember models.GroupMember
-	if err := tx.Where("user_id = ? AND group_id = ?", operatorUserID, uint(groupID)).First(&operatorMember).Error; err != nil {
-		tx.Rollback()
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该群组成员"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询权限失败"})
-		}
-		return
-	}
-
-	// 仅群主可操作
-	if operatorMember.Role != "owner" {
-		tx.Rollback()
-		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足，仅群主可操作"})
-		return
-	}
-
 	// 查找目标用户在群组中的记录
 	var targetMember models.GroupMember
 	if err := tx.Where("user_id = ? AND group_id = ?", req.TargetUserID, uint(groupID)).First(&targetMember).Error; err != nil {
@@ -767,19 +701,22 @@ func transferOwner(c *gin.Context) {
 	}
 
 	// 将目标成员设置为owner
-	if err := tx.Model(&targetMember).Update("role", "owner").Error; err != nil {
+	if err := tx.Model(&targetMember).Update("role", models.RoleOwner).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新目标成员角色失败"})
 		return
 	}
 
 	// 将原群主设置为admin
-	if err := tx.Model(&operatorMember).Update("role", "admin").Error; err != nil {
+	if err := tx.Model(operatorMember).Update("role", models.RoleAdmin).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新原群主角色失败"})
 		return
 	}
 
+	recordGroupAudit(tx, uint(groupID), operatorUserID, models.AuditOwnerTransfer, req.TargetUserID,
+		gin.H{"owner_id": operatorUserID}, gin.H{"owner_id": req.TargetUserID})
+
 	// 提交事务
 	tx.Commit()
 
@@ -787,3 +724,13 @@ func transferOwner(c *gin.Context) {
 		"message": "群主转让成功",
 	})
 }
+
+// groupInfoSnapshot 群组基本信息快照，用于审计日志记录修改前后的值
+func groupInfoSnapshot(group *models.Group) gin.H {
+	return gin.H{
+		"name":        group.Name,
+		"description": group.Description,
+		"avatar":      group.Avatar,
+		"visibility":  group.Visibility,
+	}
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-chat/imaging"
+	"go-chat/models"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errImageTooLarge = errors.New("图片文件太大")
+
+// processUploadedImage 处理暂存的图片：去除 EXIF 等元数据后覆盖暂存文件，记录尺寸和占位图，
+// 并将缩略图按内容写入存储后端。返回待保存的缩略图附件记录；非图片直接返回，图片无法解析时返回错误
+func processUploadedImage(stagedPath, contentType string, attachment *models.Attachment) ([]models.Attachment, error) {
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, nil
+	}
+	// 图片需要整个读入内存处理，先按图片的大小限制检查，避免读入超大文件
+	info, err := os.Stat(stagedPath)
+	if err != nil {
+		return nil, err
+	}
+	if max := loadUploadPolicy().maxSizeFor(contentType); info.Size() > max {
+		return nil, errImageTooLarge
+	}
+	data, err := os.ReadFile(stagedPath)
+	if err != nil {
+		return nil, err
+	}
+	result, err := imaging.Process(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(stagedPath, result.Data, 0644); err != nil {
+		return nil, err
+	}
+	attachment.Size = int64(len(result.Data))
+	attachment.Width = result.Width
+	attachment.Height = result.Height
+	attachment.Blurhash = result.Blurhash
+
+	var records []models.Attachment
+	stem := strings.TrimSuffix(filepath.Base(attachment.FileURL), filepath.Ext(attachment.FileURL))
+	for _, t := range result.Thumbnails {
+		name := fmt.Sprintf("%s_%s%s", stem, t.Size, t.Ext)
+		hash, size, err := models.PutBlob(fileStorage, bytes.NewReader(t.Data), t.ContentType)
+		if err != nil {
+			releaseAttachmentBlobs(records)
+			return nil, err
+		}
+		thumb := models.Thumbnail{Size: t.Size, URL: "/uploads/thumbs/" + name, Width: t.Width, Height: t.Height}
+		attachment.Thumbnails = append(attachment.Thumbnails, thumb)
+		records = append(records, models.Attachment{
+			FileURL:     thumb.URL,
+			Size:        size,
+			ContentType: t.ContentType,
+			BlobHash:    hash,
+			Kind:        models.AttachmentKindThumbnail,
+			UploaderID:  attachment.UploaderID,
+			Width:       t.Width,
+			Height:      t.Height,
+		})
+	}
+	return records, nil
+}
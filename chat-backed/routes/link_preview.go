@@ -0,0 +1,171 @@
+package routes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go-chat/models"
+	"go-chat/unfurl"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	linkPreviewTimeout     = 5 * time.Second
+	linkPreviewCacheTTL    = 24 * time.Hour // 成功抓取的预览缓存时间
+	linkPreviewFailureTTL  = time.Hour      // 抓取失败的缓存时间
+	linkPreviewConcurrency = 4              // 抓取预览的协程数
+	linkPreviewQueueSize   = 256            // 等待抓取的消息数上限，队列满时不再生成预览
+)
+
+// linkPreviewJob 待抓取预览的消息及其中的链接
+type linkPreviewJob struct {
+	message *models.Message
+	url     string
+}
+
+var (
+	linkFetcher      = unfurl.NewFetcher(linkPreviewTimeout)
+	linkPreviewQueue = make(chan linkPreviewJob, linkPreviewQueueSize)
+
+	// 正在抓取的链接，同一链接同时只抓取一次
+	linkPreviewMu       sync.Mutex
+	linkPreviewInflight = make(map[string]chan struct{})
+)
+
+// linkPreviewEnabled 是否抓取链接预览（环境变量 LINK_PREVIEW_ENABLED，默认开启）
+func linkPreviewEnabled() bool {
+	return os.Getenv("LINK_PREVIEW_ENABLED") != "false"
+}
+
+// unfurlMessage 将文本消息中第一个链接加入预览队列，由 StartLinkPreviewWorker 启动的协程抓取。
+// 队列已满时直接丢弃，链接预览不影响消息发送
+func unfurlMessage(message *models.Message) {
+	if !linkPreviewEnabled() || message.ID == 0 || message.MessageType != "text" {
+		return
+	}
+	rawURL := unfurl.FirstURL(message.Content)
+	if rawURL == "" {
+		return
+	}
+
+	select {
+	case linkPreviewQueue <- linkPreviewJob{message: message, url: rawURL}:
+	default:
+		log.Printf("链接预览队列已满，跳过消息 %d", message.ID)
+	}
+}
+
+// StartLinkPreviewWorker 启动固定数量的链接预览抓取协程
+func StartLinkPreviewWorker() {
+	for i := 0; i < linkPreviewConcurrency; i++ {
+		go func() {
+			for job := range linkPreviewQueue {
+				applyLinkPreview(job.message, job.url)
+			}
+		}()
+	}
+}
+
+// applyLinkPreview 抓取链接预览并保存到消息，通过 message_updated 事件通知会话中的客户端
+func applyLinkPreview(message *models.Message, rawURL string) {
+	preview := loadLinkPreview(rawURL)
+	if preview == nil {
+		return
+	}
+	// 消息可能已被删除或销毁
+	result := models.DB.Model(&models.Message{ID: message.ID}).Select("link_preview").
+		Updates(&models.Message{LinkPreview: preview})
+	if result.Error != nil {
+		log.Printf("保存链接预览失败: %v", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	SendBroadcastMessage(BroadcastMessage{
+		Type:        "message_updated",
+		MessageID:   message.ID,
+		UserID:      message.UserID,
+		LinkPreview: preview,
+		Target:      message.TargetID,
+		GroupID:     message.GroupID,
+		ChannelID:   message.ChannelID,
+	})
+}
+
+// loadLinkPreview 读取链接预览，优先使用缓存。没有预览信息或抓取失败时返回 nil
+func loadLinkPreview(rawURL string) *models.LinkPreview {
+	sum := sha256.Sum256([]byte(rawURL))
+	hash := hex.EncodeToString(sum[:])
+
+	for {
+		var cache models.LinkPreviewCache
+		if err := models.DB.Where("url_hash = ?", hash).First(&cache).Error; err == nil {
+			ttl := linkPreviewCacheTTL
+			if cache.Preview == nil {
+				ttl = linkPreviewFailureTTL
+			}
+			if time.Since(cache.FetchedAt) < ttl {
+				return cache.Preview
+			}
+		}
+
+		linkPreviewMu.Lock()
+		if done, ok := linkPreviewInflight[hash]; ok {
+			// 其他消息正在抓取同一链接，等待完成后读取缓存
+			linkPreviewMu.Unlock()
+			<-done
+			continue
+		}
+		done := make(chan struct{})
+		linkPreviewInflight[hash] = done
+		linkPreviewMu.Unlock()
+
+		preview := fetchLinkPreview(rawURL)
+		saveLinkPreview(hash, rawURL, preview)
+
+		linkPreviewMu.Lock()
+		delete(linkPreviewInflight, hash)
+		linkPreviewMu.Unlock()
+		close(done)
+		return preview
+	}
+}
+
+// fetchLinkPreview 抓取链接预览，同时进行的抓取数量由抓取协程数限制
+func fetchLinkPreview(rawURL string) *models.LinkPreview {
+	ctx, cancel := context.WithTimeout(context.Background(), linkPreviewTimeout)
+	defer cancel()
+	p, err := linkFetcher.Fetch(ctx, rawURL)
+	if err != nil {
+		if !errors.Is(err, unfurl.ErrNoPreview) {
+			log.Printf("抓取链接预览 %s 失败: %v", rawURL, err)
+		}
+		return nil
+	}
+	return &models.LinkPreview{
+		URL:         p.URL,
+		Title:       p.Title,
+		Description: p.Description,
+		Image:       p.Image,
+		SiteName:    p.SiteName,
+	}
+}
+
+// saveLinkPreview 写入或刷新缓存
+func saveLinkPreview(hash, rawURL string, preview *models.LinkPreview) {
+	cache := models.LinkPreviewCache{URLHash: hash, URL: rawURL, Preview: preview, FetchedAt: time.Now()}
+	if err := models.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "url_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"preview", "fetched_at"}),
+	}).Create(&cache).Error; err != nil {
+		log.Printf("缓存链接预览失败: %v", err)
+	}
+}
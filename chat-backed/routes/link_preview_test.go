@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"go-chat/models"
+	"testing"
+	"time"
+)
+
+// 没有空闲的抓取协程时，超出队列容量的消息直接跳过，不阻塞发送
+func TestUnfurlMessageDropsWhenQueueFull(t *testing.T) {
+	t.Setenv("LINK_PREVIEW_ENABLED", "true")
+	t.Cleanup(func() {
+		for len(linkPreviewQueue) > 0 {
+			<-linkPreviewQueue
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= linkPreviewQueueSize+10; i++ {
+			unfurlMessage(&models.Message{ID: uint(i), MessageType: "text", Content: "看看 https://example.com/page"})
+		}
+		// 没有链接的消息不入队
+		unfurlMessage(&models.Message{ID: 1, MessageType: "text", Content: "没有链接"})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("队列已满时 unfurlMessage 阻塞")
+	}
+	if n := len(linkPreviewQueue); n != linkPreviewQueueSize {
+		t.Errorf("队列中有 %d 条消息，want %d", n, linkPreviewQueueSize)
+	}
+	if job := <-linkPreviewQueue; job.message.ID != 1 || job.url != "https://example.com/page" {
+		t.Errorf("job = %+v", job)
+	}
+}
@@ -0,0 +1,395 @@
+package routes
+
+import (
+	"fmt"
+	"go-chat/models"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 慢速模式的最大间隔（秒）
+const maxSlowModeSeconds = 6 * 60 * 60
+
+// 成员在各群组中的最近发言时间，用于慢速模式限流
+var slowModeTracker = struct {
+	sync.Mutex
+	lastPost map[[2]uint]time.Time
+}{lastPost: make(map[[2]uint]time.Time)}
+
+// isBannedFromGroup 判断用户是否被群组封禁
+func isBannedFromGroup(groupID, userID uint) bool {
+	var count int64
+	models.DB.Model(&models.GroupBan{}).Where("group_id = ? AND user_id = ?", groupID, userID).Count(&count)
+	return count > 0
+}
+
+// sendGroupSystemMessage 保存并广播一条群组系统消息
+func sendGroupSystemMessage(groupID uint, content string) {
+	message := models.Message{
+		Username:    "系统",
+		Content:     content,
+		MessageType: "system",
+		GroupID:     groupID,
+		CreatedAt:   time.Now(),
+	}
+	if err := models.DB.Create(&message).Error; err != nil {
+		fmt.Printf("保存系统消息失败: %v\n", err)
+	}
+
+	SendBroadcastMessage(BroadcastMessage{
+		Type:        "message",
+		MessageID:   message.ID,
+		Username:    message.Username,
+		Content:     content,
+		MessageType: "system",
+		GroupID:     groupID,
+		CreatedAt:   message.CreatedAt.Format("2006-01-02 15:04:05"),
+	})
+}
+
+// slowModeInterval 查询群组的慢速模式间隔，未开启时返回 0
+func slowModeInterval(groupID uint) time.Duration {
+	var seconds int
+	if err := models.DB.Model(&models.Group{}).Select("slow_mode").Where("id = ?", groupID).Scan(&seconds).Error; err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// reserveSlowMode 慢速模式下为成员预占一次发言：检查发言间隔和记录发言时间在同一次加锁中完成，
+// 同时发送的多条消息只有一条能通过。拥有禁言权限的成员不受限制。
+// 消息保存失败时调用返回的 release 归还发言机会，被拒绝的消息不占用发言间隔
+func reserveSlowMode(groupID, userID uint) (func(), *groupError) {
+	release := func() {}
+	interval := slowModeInterval(groupID)
+	if interval <= 0 {
+		return release, nil
+	}
+	if _, role, gerr := authorizeGroup(userID, groupID, ""); gerr == nil && role.Has(models.PermMute) {
+		return release, nil
+	}
+
+	slowModeTracker.Lock()
+	defer slowModeTracker.Unlock()
+
+	key := [2]uint{groupID, userID}
+	now := time.Now()
+	last, hadLast := slowModeTracker.lastPost[key]
+	if hadLast {
+		if wait := interval - now.Sub(last); wait > 0 {
+			return release, newGroupError(http.StatusTooManyRequests, fmt.Sprintf("慢速模式已开启，请 %d 秒后再发言", int(wait.Seconds())+1))
+		}
+	}
+	slowModeTracker.lastPost[key] = now
+
+	// 记录过多时清理已超过最大间隔的条目
+	if len(slowModeTracker.lastPost) > 10000 {
+		for k, t := range slowModeTracker.lastPost {
+			if now.Sub(t) > maxSlowModeSeconds*time.Second {
+				delete(slowModeTracker.lastPost, k)
+			}
+		}
+	}
+
+	release = func() {
+		slowModeTracker.Lock()
+		defer slowModeTracker.Unlock()
+		// 之后又有新的发言记录时不再回退
+		if t, ok := slowModeTracker.lastPost[key]; !ok || !t.Equal(now) {
+			return
+		}
+		if hadLast {
+			slowModeTracker.lastPost[key] = last
+		} else {
+			delete(slowModeTracker.lastPost, key)
+		}
+	}
+	return release, nil
+}
+
+// maxMuteDuration 单次禁言的最长时长
+const maxMuteDuration = 365 * 24 * time.Hour
+
+// muteGroupMember 由操作者将目标成员禁言 duration 时长，最长 365 天
+func muteGroupMember(operatorUserID, groupID, targetUserID uint, duration time.Duration) (*models.GroupMember, *groupError) {
+	if duration <= 0 || duration > maxMuteDuration {
+		return nil, newGroupError(http.StatusBadRequest, "禁言时长最长为 365 天")
+	}
+
+	// 检查操作者权限
+	_, operatorRole, gerr := authorizeGroup(operatorUserID, groupID, models.PermMute)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	var member models.GroupMember
+	if err := models.DB.Preload("User").Where("user_id = ? AND group_id = ?", targetUserID, groupID).First(&member).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, newGroupError(http.StatusNotFound, "成员不存在")
+		}
+		return nil, newGroupError(http.StatusInternalServerError, "查询成员失败")
+	}
+
+	if gerr := checkManageTarget(operatorRole, &member); gerr != nil {
+		return nil, gerr
+	}
+
+	previous := member.MutedUntil
+	mutedUntil := time.Now().Add(duration)
+	if err := models.DB.Model(&member).Update("muted_until", mutedUntil).Error; err != nil {
+		return nil, newGroupError(http.StatusInternalServerError, "禁言成员失败")
+	}
+	recordGroupAudit(models.DB, groupID, operatorUserID, models.AuditMemberMute, targetUserID,
+		map[string]interface{}{"muted_until": previous}, map[string]interface{}{"muted_until": mutedUntil})
+	member.MutedUntil = &mutedUntil
+
+	sendGroupSystemMessage(groupID, fmt.Sprintf("%s 被 %s 禁言至 %s",
+		member.User.Username, lookupUsername(operatorUserID), mutedUntil.Format("2006-01-02 15:04:05")))
+
+	return &member, nil
+}
+
+// unmuteGroupMember 由操作者解除目标成员的禁言
+func unmuteGroupMember(operatorUserID, groupID, targetUserID uint) *groupError {
+	_, operatorRole, gerr := authorizeGroup(operatorUserID, groupID, models.PermMute)
+	if gerr != nil {
+		return gerr
+	}
+
+	var member models.GroupMember
+	if err := models.DB.Preload("User").Where("user_id = ? AND group_id = ?", targetUserID, groupID).First(&member).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return newGroupError(http.StatusNotFound, "成员不存在")
+		}
+		return newGroupError(http.StatusInternalServerError, "查询成员失败")
+	}
+
+	if gerr := checkManageTarget(operatorRole, &member); gerr != nil {
+		return gerr
+	}
+
+	if !member.IsMuted() {
+		return newGroupError(http.StatusBadRequest, "该成员未被禁言")
+	}
+
+	if err := models.DB.Model(&member).Update("muted_until", nil).Error; err != nil {
+		return newGroupError(http.StatusInternalServerError, "解除禁言失败")
+	}
+	recordGroupAudit(models.DB, groupID, operatorUserID, models.AuditMemberUnmute, targetUserID,
+		map[string]interface{}{"muted_until": member.MutedUntil}, map[string]interface{}{"muted_until": nil})
+
+	sendGroupSystemMessage(groupID, fmt.Sprintf("%s 被 %s 解除了禁言", member.User.Username, lookupUsername(operatorUserID)))
+	return nil
+}
+
+// unbanGroupUser 由操作者解除对目标用户的封禁
+func unbanGroupUser(operatorUserID, groupID, targetUserID uint) *groupError {
+	if _, _, gerr := authorizeGroup(operatorUserID, groupID, models.PermKick); gerr != nil {
+		return gerr
+	}
+
+	var ban models.GroupBan
+	if err := models.DB.Where("group_id = ? AND user_id = ?", groupID, targetUserID).First(&ban).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return newGroupError(http.StatusNotFound, "该用户未被封禁")
+		}
+		return newGroupError(http.StatusInternalServerError, "查询封禁记录失败")
+	}
+
+	result := models.DB.Where("group_id = ? AND user_id = ?", groupID, targetUserID).Delete(&models.GroupBan{})
+	if result.Error != nil {
+		return newGroupError(http.StatusInternalServerError, "解除封禁失败")
+	}
+	if result.RowsAffected == 0 {
+		return newGroupError(http.StatusNotFound, "该用户未被封禁")
+	}
+	recordGroupAudit(models.DB, groupID, operatorUserID, models.AuditMemberUnban, targetUserID,
+		map[string]interface{}{"operator_id": ban.OperatorID, "reason": ban.Reason}, nil)
+
+	sendGroupSystemMessage(groupID, fmt.Sprintf("%s 被 %s 解除了封禁", lookupUsername(targetUserID), lookupUsername(operatorUserID)))
+	return nil
+}
+
+// setGroupSlowMode 设置群组慢速模式间隔（秒），0表示关闭
+func setGroupSlowMode(operatorUserID, groupID uint, seconds int) *groupError {
+	if seconds < 0 || seconds > maxSlowModeSeconds {
+		return newGroupError(http.StatusBadRequest, fmt.Sprintf("慢速模式间隔需在0到%d秒之间", maxSlowModeSeconds))
+	}
+
+	if _, _, gerr := authorizeGroup(operatorUserID, groupID, models.PermMute); gerr != nil {
+		return gerr
+	}
+
+	var previous int
+	models.DB.Model(&models.Group{}).Select("slow_mode").Where("id = ?", groupID).Scan(&previous)
+
+	if err := models.DB.Model(&models.Group{}).Where("id = ?", groupID).Update("slow_mode", seconds).Error; err != nil {
+		return newGroupError(http.StatusInternalServerError, "设置慢速模式失败")
+	}
+	recordGroupAudit(models.DB, groupID, operatorUserID, models.AuditSlowModeChange, groupID,
+		map[string]interface{}{"slow_mode": previous}, map[string]interface{}{"slow_mode": seconds})
+
+	operatorName := lookupUsername(operatorUserID)
+	if seconds == 0 {
+		sendGroupSystemMessage(groupID, fmt.Sprintf("%s 关闭了慢速模式", operatorName))
+	} else {
+		sendGroupSystemMessage(groupID, fmt.Sprintf("%s 开启了慢速模式，成员每 %d 秒只能发言一次", operatorName, seconds))
+	}
+	return nil
+}
+
+// muteMember 禁言群成员
+func muteMember(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	targetUserID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	operatorUserID := c.MustGet("userID").(uint)
+
+	var req struct {
+		Duration string `json:"duration" binding:"required"` // 禁言时长，如 10m、2h、1d
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	duration, err := parseCommandDuration(req.Duration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, gerr := muteGroupMember(operatorUserID, uint(groupID), uint(targetUserID), duration)
+	if gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "成员已禁言",
+		"member":  member,
+	})
+}
+
+// unmuteMember 解除群成员禁言
+func unmuteMember(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	targetUserID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	operatorUserID := c.MustGet("userID").(uint)
+
+	if gerr := unmuteGroupMember(operatorUserID, uint(groupID), uint(targetUserID)); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已解除禁言",
+	})
+}
+
+// getGroupBans 获取群组封禁列表（需要移出成员权限）
+func getGroupBans(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermKick); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var bans []models.GroupBan
+	if err := models.DB.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, username, avatar")
+	}).Where("group_id = ?", uint(groupID)).Order("created_at desc").Find(&bans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取封禁列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bans": bans,
+	})
+}
+
+// unbanMember 解除封禁
+func unbanMember(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	targetUserID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	operatorUserID := c.MustGet("userID").(uint)
+
+	if gerr := unbanGroupUser(operatorUserID, uint(groupID), uint(targetUserID)); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已解除封禁",
+	})
+}
+
+// updateSlowMode 设置群组慢速模式
+func updateSlowMode(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	operatorUserID := c.MustGet("userID").(uint)
+
+	var req struct {
+		Seconds *int `json:"seconds" binding:"required"` // 发言间隔（秒），0表示关闭
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	if gerr := setGroupSlowMode(operatorUserID, uint(groupID), *req.Seconds); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "慢速模式已更新",
+		"slow_mode": *req.Seconds,
+	})
+}
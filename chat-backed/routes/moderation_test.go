@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"go-chat/models"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// 慢速模式的检查和记录在一次加锁中完成，归还后不占用发言间隔
+func TestReserveSlowMode(t *testing.T) {
+	setupTestDB(t)
+	group, users := createTestGroup(t, "alice", map[string]string{"bob": models.RoleMember, "carol": models.RoleMember})
+	models.DB.Model(group).Update("slow_mode", 30)
+	bob, carol := users["bob"].ID, users["carol"].ID
+
+	release, gerr := reserveSlowMode(group.ID, bob)
+	if gerr != nil {
+		t.Fatalf("第一次发言: %s", gerr.Message)
+	}
+	if _, gerr := reserveSlowMode(group.ID, bob); gerr == nil || gerr.Status != http.StatusTooManyRequests {
+		t.Errorf("间隔内再次发言: gerr = %v, want 429", gerr)
+	}
+	// 保存失败时归还，可以立即重新发言
+	release()
+	if _, gerr := reserveSlowMode(group.ID, bob); gerr != nil {
+		t.Errorf("归还后发言: %s", gerr.Message)
+	}
+
+	// 其他成员不受影响，拥有禁言权限的成员不受限制
+	if _, gerr := reserveSlowMode(group.ID, carol); gerr != nil {
+		t.Errorf("其他成员: %s", gerr.Message)
+	}
+	for i := 0; i < 2; i++ {
+		if _, gerr := reserveSlowMode(group.ID, users["alice"].ID); gerr != nil {
+			t.Errorf("群主第 %d 次发言: %s", i+1, gerr.Message)
+		}
+	}
+}
+
+// 同时发送的多条消息只有一条能通过慢速模式
+func TestReserveSlowModeConcurrent(t *testing.T) {
+	setupTestDB(t)
+	group, users := createTestGroup(t, "alice", map[string]string{"bob": models.RoleMember})
+	models.DB.Model(group).Update("slow_mode", 30)
+
+	var wg sync.WaitGroup
+	var passed atomic.Int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, gerr := reserveSlowMode(group.ID, users["bob"].ID); gerr == nil {
+				passed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := passed.Load(); n != 1 {
+		t.Errorf("%d 条消息通过了慢速模式，want 1", n)
+	}
+}
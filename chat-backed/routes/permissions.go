@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"fmt"
+	"go-chat/models"
+	"net/http"
+)
+
+// 权限的中文名称，用于错误提示
+var permissionNames = map[string]string{
+	models.PermSend:               "发送消息",
+	models.PermSendFiles:          "发送文件",
+	models.PermInvite:             "邀请成员",
+	models.PermManageInvites:      "管理邀请与入群申请",
+	models.PermKick:               "移出成员",
+	models.PermMute:               "禁言成员",
+	models.PermPin:                "置顶消息",
+	models.PermEditInfo:           "修改群信息",
+	models.PermManageRoles:        "管理角色",
+	models.PermViewAuditLog:       "查看审计日志",
+	models.PermManageChannel:      "管理频道",
+	models.PermManageDisappearing: "设置阅后即焚",
+	models.PermDissolve:           "解散群组",
+	models.PermTransferOwner:      "转让群主",
+}
+
+// authorizeGroup 群组统一鉴权：校验用户是群成员，且（perm 非空时）其角色拥有指定权限。
+// 返回成员记录及解析后的角色信息
+func authorizeGroup(userID, groupID uint, perm string) (*models.GroupMember, *models.RoleInfo, *groupError) {
+	member, gerr := getGroupMember(userID, groupID)
+	if gerr != nil {
+		return nil, nil, gerr
+	}
+
+	role := models.ResolveRole(groupID, member.Role)
+	if perm != "" && !role.Has(perm) {
+		return nil, nil, permissionDenied(perm)
+	}
+	return member, role, nil
+}
+
+// permissionDenied 构造缺少指定权限时的错误
+func permissionDenied(perm string) *groupError {
+	name := permissionNames[perm]
+	if name == "" {
+		name = perm
+	}
+	return newGroupError(http.StatusForbidden, fmt.Sprintf("权限不足，需要「%s」权限", name))
+}
+
+// checkManageTarget 校验操作者能否管理目标成员：只能管理角色等级低于自己的成员
+func checkManageTarget(operatorRole *models.RoleInfo, target *models.GroupMember) *groupError {
+	if target.Role == models.RoleOwner {
+		return newGroupError(http.StatusBadRequest, "不能对群主执行此操作")
+	}
+	targetRole := models.ResolveRole(target.GroupID, target.Role)
+	if operatorRole.Level <= targetRole.Level {
+		return newGroupError(http.StatusForbidden, "无权管理角色等级不低于自己的成员")
+	}
+	return nil
+}
+
+// checkAssignRole 校验操作者能否把成员设为指定角色：需要管理角色权限，
+// 角色必须存在且等级低于操作者
+func checkAssignRole(operatorRole *models.RoleInfo, groupID uint, role string) *groupError {
+	if !operatorRole.Has(models.PermManageRoles) {
+		return permissionDenied(models.PermManageRoles)
+	}
+	if role == models.RoleOwner {
+		return newGroupError(http.StatusBadRequest, "请使用转让群主功能")
+	}
+
+	var level int
+	if models.IsBuiltinRole(role) {
+		level = models.BuiltinRoleInfo(role).Level
+	} else {
+		var custom models.GroupRole
+		if err := models.DB.Where("group_id = ? AND name = ?", groupID, role).First(&custom).Error; err != nil {
+			return newGroupError(http.StatusBadRequest, "角色不存在")
+		}
+		level = custom.Level
+	}
+
+	if level >= operatorRole.Level {
+		return newGroupError(http.StatusForbidden, "只能分配等级低于自己的角色")
+	}
+	return nil
+}
+
+// groupMemberIDsWithPermission 获取群组中拥有指定权限的成员ID列表
+func groupMemberIDsWithPermission(groupID uint, perm string) []uint {
+	var members []models.GroupMember
+	models.DB.Select("user_id, group_id, role").Where("group_id = ?", groupID).Find(&members)
+
+	roles := make(map[string]*models.RoleInfo)
+	ids := make([]uint, 0)
+	for _, m := range members {
+		role, ok := roles[m.Role]
+		if !ok {
+			role = models.ResolveRole(groupID, m.Role)
+			roles[m.Role] = role
+		}
+		if role.Has(perm) {
+			ids = append(ids, m.UserID)
+		}
+	}
+	return ids
+}
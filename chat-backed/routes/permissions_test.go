@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"go-chat/models"
+	"net/http"
+	"testing"
+)
+
+func TestAuthorizeGroupRoleMatrix(t *testing.T) {
+	setupTestDB(t)
+	group, users := createTestGroup(t, "owner", map[string]string{
+		"admin": models.RoleAdmin, "member": models.RoleMember, "mod": "moderator", "ghost": "deleted_role",
+	})
+	outsider := &models.User{Username: "outsider"}
+	models.DB.Create(outsider)
+
+	mod := models.GroupRole{GroupID: group.ID, Name: "moderator", Level: 10}
+	mod.SetPermissions([]string{models.PermSend, models.PermMute, models.PermPin, models.PermManageDisappearing})
+	models.DB.Create(&mod)
+
+	allPerms := append(append([]string{}, models.AllPermissions...), models.PermDissolve, models.PermTransferOwner)
+	granted := map[string][]string{
+		"owner": allPerms,
+		"admin": {models.PermSend, models.PermSendFiles, models.PermInvite, models.PermManageInvites, models.PermKick,
+			models.PermMute, models.PermPin, models.PermEditInfo, models.PermViewAuditLog, models.PermManageChannel},
+		"member": {models.PermSend, models.PermSendFiles, models.PermInvite},
+		"mod":    {models.PermSend, models.PermMute, models.PermPin, models.PermManageDisappearing},
+		// 已删除的自定义角色按普通成员处理
+		"ghost": {models.PermSend, models.PermSendFiles, models.PermInvite},
+	}
+
+	for name, perms := range granted {
+		has := make(map[string]bool)
+		for _, p := range perms {
+			has[p] = true
+		}
+		for _, perm := range allPerms {
+			_, _, gerr := authorizeGroup(users[name].ID, group.ID, perm)
+			if has[perm] && gerr != nil {
+				t.Errorf("%s 缺少权限 %s: %s", name, perm, gerr.Message)
+			}
+			if !has[perm] && (gerr == nil || gerr.Status != http.StatusForbidden) {
+				t.Errorf("%s 拥有不应有的权限 %s", name, perm)
+			}
+		}
+	}
+
+	if _, _, gerr := authorizeGroup(outsider.ID, group.ID, ""); gerr == nil || gerr.Status != http.StatusForbidden {
+		t.Errorf("非成员: gerr = %v, want 403", gerr)
+	}
+	// 已解散的群组中原成员也没有任何权限
+	models.DB.Delete(group)
+	if _, _, gerr := authorizeGroup(users["owner"].ID, group.ID, ""); gerr == nil || gerr.Status != http.StatusForbidden {
+		t.Errorf("已解散的群组: gerr = %v, want 403", gerr)
+	}
+}
+
+func TestCheckManageTargetAndAssignRole(t *testing.T) {
+	setupTestDB(t)
+	group, users := createTestGroup(t, "owner", map[string]string{
+		"admin": models.RoleAdmin, "admin2": models.RoleAdmin, "member": models.RoleMember, "mod": "moderator",
+	})
+	mod := models.GroupRole{GroupID: group.ID, Name: "moderator", Level: 10}
+	mod.SetPermissions([]string{models.PermKick})
+	models.DB.Create(&mod)
+	senior := models.GroupRole{GroupID: group.ID, Name: "senior", Level: 60}
+	models.DB.Create(&senior)
+
+	roleOf := func(name string) *models.RoleInfo {
+		_, role, gerr := authorizeGroup(users[name].ID, group.ID, "")
+		if gerr != nil {
+			t.Fatalf("%s: %s", name, gerr.Message)
+		}
+		return role
+	}
+	memberOf := func(name string) *models.GroupMember {
+		member, _, _ := authorizeGroup(users[name].ID, group.ID, "")
+		return member
+	}
+
+	// 只能管理等级低于自己的成员，任何人都不能管理群主
+	manage := []struct {
+		operator, target string
+		ok               bool
+	}{
+		{"owner", "admin", true},
+		{"admin", "member", true},
+		{"admin", "mod", true},
+		{"admin", "admin2", false},
+		{"admin", "owner", false},
+		{"mod", "member", true},
+		{"mod", "admin", false},
+		{"member", "mod", false},
+	}
+	for _, tt := range manage {
+		gerr := checkManageTarget(roleOf(tt.operator), memberOf(tt.target))
+		if (gerr == nil) != tt.ok {
+			t.Errorf("%s 管理 %s: gerr = %v, want ok = %v", tt.operator, tt.target, gerr, tt.ok)
+		}
+	}
+
+	// 分配角色需要管理角色权限，且只能分配等级低于自己的角色
+	assign := []struct {
+		operator, role string
+		status         int // 0 表示允许
+	}{
+		{"owner", models.RoleAdmin, 0},
+		{"owner", "moderator", 0},
+		{"owner", "senior", 0},
+		{"owner", models.RoleOwner, http.StatusBadRequest},
+		{"owner", "missing", http.StatusBadRequest},
+		{"admin", models.RoleMember, http.StatusForbidden},
+	}
+	for _, tt := range assign {
+		gerr := checkAssignRole(roleOf(tt.operator), group.ID, tt.role)
+		switch {
+		case tt.status == 0 && gerr != nil:
+			t.Errorf("%s 分配 %s: %s", tt.operator, tt.role, gerr.Message)
+		case tt.status != 0 && (gerr == nil || gerr.Status != tt.status):
+			t.Errorf("%s 分配 %s: gerr = %v, want %d", tt.operator, tt.role, gerr, tt.status)
+		}
+	}
+
+	// 拥有管理角色权限的自定义角色不能分配等级不低于自己的角色
+	manager := &models.RoleInfo{Name: "manager", Level: 60, Permissions: map[string]bool{models.PermManageRoles: true}}
+	if gerr := checkAssignRole(manager, group.ID, "senior"); gerr == nil || gerr.Status != http.StatusForbidden {
+		t.Errorf("分配同等级角色: gerr = %v, want 403", gerr)
+	}
+	if gerr := checkAssignRole(manager, group.ID, models.RoleAdmin); gerr != nil {
+		t.Errorf("分配低等级角色: %s", gerr.Message)
+	}
+}
@@ -0,0 +1,442 @@
+package routes
+
+import (
+	"errors"
+	"go-chat/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 每个群组最多置顶的消息数量
+const maxPinnedMessages = 10
+
+// 群公告的最大长度（字符）
+const maxAnnouncementLength = 2000
+
+// pinGroupMessage 置顶群消息（需要置顶权限）
+func pinGroupMessage(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	var req struct {
+		MessageID uint `json:"message_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	_, role, gerr := authorizeGroup(userID, uint(groupID), models.PermPin)
+	if gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	// 只能置顶本群的消息
+	var message models.Message
+	if err := models.DB.Where("id = ? AND group_id = ?", req.MessageID, uint(groupID)).First(&message).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询消息失败"})
+		}
+		return
+	}
+
+	// 私有频道的消息只能由能访问该频道的成员置顶
+	if message.ChannelID > 0 {
+		channel, gerr := getGroupChannel(uint(groupID), message.ChannelID)
+		if gerr != nil || !canAccessChannel(userID, role, channel) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+			return
+		}
+	}
+
+	pin := models.PinnedMessage{
+		GroupID:   uint(groupID),
+		MessageID: message.ID,
+		PinnedBy:  userID,
+	}
+
+	// 锁定群组记录后再检查数量上限，避免并发置顶超出上限
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Group{}, uint(groupID)).Error; err != nil {
+			return newGroupError(http.StatusNotFound, "群组不存在")
+		}
+
+		var count int64
+		if err := tx.Model(&models.PinnedMessage{}).Where("group_id = ?", uint(groupID)).Count(&count).Error; err != nil {
+			return newGroupError(http.StatusInternalServerError, "查询置顶消息失败")
+		}
+
+		var exists int64
+		tx.Model(&models.PinnedMessage{}).Where("group_id = ? AND message_id = ?", uint(groupID), message.ID).Count(&exists)
+		if exists > 0 {
+			return newGroupError(http.StatusBadRequest, "该消息已置顶")
+		}
+		if count >= maxPinnedMessages {
+			return newGroupError(http.StatusBadRequest, "置顶消息数量已达上限，请先取消其他置顶")
+		}
+
+		if err := tx.Create(&pin).Error; err != nil {
+			return newGroupError(http.StatusInternalServerError, "置顶消息失败")
+		}
+		recordGroupAudit(tx, uint(groupID), userID, models.AuditMessagePin, message.ID, nil, gin.H{"content": message.Content})
+		return nil
+	})
+	if err != nil {
+		// 提交事务或加锁查询失败时返回的是普通错误
+		var gerr *groupError
+		if !errors.As(err, &gerr) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "置顶消息失败"})
+			return
+		}
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+	pin.Message = message
+
+	// 通知群成员
+	SendBroadcastMessage(BroadcastMessage{
+		Type:        "message_pinned",
+		MessageID:   message.ID,
+		UserID:      userID,
+		Username:    lookupUsername(userID),
+		Content:     message.Content,
+		MessageType: message.MessageType,
+		GroupID:     uint(groupID),
+		ChannelID:   message.ChannelID,
+		CreatedAt:   pin.CreatedAt.Format("2006-01-02 15:04:05"),
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "消息已置顶",
+		"pin":     pin,
+	})
+}
+
+// getPinnedMessages 获取群组置顶消息列表
+func getPinnedMessages(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), ""); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var pins []models.PinnedMessage
+	if err := models.DB.Preload("Message").Where("group_id = ?", uint(groupID)).Order("created_at desc").Find(&pins).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取置顶消息失败"})
+		return
+	}
+
+	// 过滤掉无权访问的私有频道中的置顶消息
+	hidden := make(map[uint]bool)
+	for _, id := range inaccessibleChannelIDs(userID, []uint{uint(groupID)}) {
+		hidden[id] = true
+	}
+	visible := make([]models.PinnedMessage, 0, len(pins))
+	for _, pin := range pins {
+		if !hidden[pin.Message.ChannelID] {
+			visible = append(visible, pin)
+		}
+	}
+	pins = visible
+
+	c.JSON(http.StatusOK, gin.H{
+		"pins":  pins,
+		"limit": maxPinnedMessages,
+	})
+}
+
+// unpinGroupMessage 取消置顶群消息（需要置顶权限）
+func unpinGroupMessage(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	messageID, err := strconv.ParseUint(c.Param("messageId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的消息ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermPin); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	result := models.DB.Where("group_id = ? AND message_id = ?", uint(groupID), uint(messageID)).Delete(&models.PinnedMessage{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取消置顶失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该消息未置顶"})
+		return
+	}
+
+	recordGroupAudit(models.DB, uint(groupID), userID, models.AuditMessageUnpin, uint(messageID), nil, nil)
+
+	SendBroadcastMessage(BroadcastMessage{
+		Type:      "message_unpinned",
+		MessageID: uint(messageID),
+		UserID:    userID,
+		Username:  lookupUsername(userID),
+		GroupID:   uint(groupID),
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已取消置顶",
+	})
+}
+
+// createAnnouncement 发布群公告（需要置顶权限），在线成员会收到 announcement 事件
+func createAnnouncement(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	var req struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" || len([]rune(req.Content)) > maxAnnouncementLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "公告内容长度需在1到2000个字符之间"})
+		return
+	}
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermPin); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	announcement := models.GroupAnnouncement{
+		GroupID:  uint(groupID),
+		AuthorID: userID,
+		Content:  req.Content,
+	}
+	if err := models.DB.Create(&announcement).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发布公告失败"})
+		return
+	}
+
+	// 发布者视为已确认
+	models.DB.Create(&models.AnnouncementAck{AnnouncementID: announcement.ID, UserID: userID})
+
+	recordGroupAudit(models.DB, uint(groupID), userID, models.AuditAnnouncement, announcement.ID, nil, gin.H{"content": announcement.Content})
+
+	SendBroadcastMessage(BroadcastMessage{
+		Type:      "announcement",
+		MessageID: announcement.ID,
+		UserID:    userID,
+		Username:  lookupUsername(userID),
+		Content:   announcement.Content,
+		GroupID:   uint(groupID),
+		CreatedAt: announcement.CreatedAt.Format("2006-01-02 15:04:05"),
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "公告发布成功",
+		"announcement": announcement,
+	})
+}
+
+// getAnnouncements 获取群公告列表，附带当前用户的确认状态。
+// pending=true 时仅返回当前用户尚未确认的公告
+func getAnnouncements(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), ""); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	query := models.DB.Preload("Author", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, username, avatar")
+	}).Where("group_id = ?", uint(groupID))
+	if c.Query("pending") == "true" {
+		query = query.Where("id NOT IN (?)", models.DB.Model(&models.AnnouncementAck{}).Select("announcement_id").Where("user_id = ?", userID))
+	}
+
+	var announcements []models.GroupAnnouncement
+	if err := query.Order("created_at desc").Limit(50).Find(&announcements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取群公告失败"})
+		return
+	}
+
+	ids := make([]uint, 0, len(announcements))
+	for _, a := range announcements {
+		ids = append(ids, a.ID)
+	}
+
+	acked := make(map[uint]bool)
+	ackCounts := make(map[uint]int64)
+	if len(ids) > 0 {
+		var ackedIDs []uint
+		models.DB.Model(&models.AnnouncementAck{}).Where("user_id = ? AND announcement_id IN ?", userID, ids).Pluck("announcement_id", &ackedIDs)
+		for _, id := range ackedIDs {
+			acked[id] = true
+		}
+
+		var counts []struct {
+			AnnouncementID uint
+			Count          int64
+		}
+		models.DB.Model(&models.AnnouncementAck{}).
+			Select("announcement_id, COUNT(*) AS count").
+			Where("announcement_id IN ?", ids).
+			Group("announcement_id").
+			Scan(&counts)
+		for _, row := range counts {
+			ackCounts[row.AnnouncementID] = row.Count
+		}
+	}
+
+	list := make([]gin.H, 0, len(announcements))
+	for i := range announcements {
+		list = append(list, gin.H{
+			"announcement": announcements[i],
+			"acknowledged": acked[announcements[i].ID],
+			"ack_count":    ackCounts[announcements[i].ID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"announcements": list,
+	})
+}
+
+// acknowledgeAnnouncement 确认已读群公告
+func acknowledgeAnnouncement(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	announcementID, err := strconv.ParseUint(c.Param("announcementId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的公告ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), ""); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var announcement models.GroupAnnouncement
+	if err := models.DB.Where("id = ? AND group_id = ?", uint(announcementID), uint(groupID)).First(&announcement).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "公告不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询公告失败"})
+		}
+		return
+	}
+
+	// 重复确认不报错
+	ack := models.AnnouncementAck{AnnouncementID: announcement.ID, UserID: userID}
+	if err := models.DB.Where(&ack).FirstOrCreate(&ack).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "确认公告失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "公告已确认",
+		"ack":     ack,
+	})
+}
+
+// getAnnouncementAcks 查看公告的确认情况（需要置顶权限）
+func getAnnouncementAcks(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	announcementID, err := strconv.ParseUint(c.Param("announcementId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的公告ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermPin); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var count int64
+	models.DB.Model(&models.GroupAnnouncement{}).Where("id = ? AND group_id = ?", uint(announcementID), uint(groupID)).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "公告不存在"})
+		return
+	}
+
+	var acks []models.AnnouncementAck
+	if err := models.DB.Where("announcement_id = ?", uint(announcementID)).Order("acked_at asc").Find(&acks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取确认记录失败"})
+		return
+	}
+
+	// 统计尚未确认的成员
+	ackedUsers := make(map[uint]bool, len(acks))
+	for _, a := range acks {
+		ackedUsers[a.UserID] = true
+	}
+	var memberIDs []uint
+	models.DB.Model(&models.GroupMember{}).Where("group_id = ?", uint(groupID)).Pluck("user_id", &memberIDs)
+	pending := make([]uint, 0)
+	for _, id := range memberIDs {
+		if !ackedUsers[id] {
+			pending = append(pending, id)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"acks":    acks,
+		"pending": pending,
+	})
+}
@@ -5,7 +5,6 @@ import (
 	"go-chat/models"
 	"go-chat/utils"
 	"net/http"
-	"os"
 	"path/filepath"
 	"time"
 
@@ -70,44 +69,72 @@ func UploadAvatar(c *gin.Context) {
 		return
 	}
 
-	// 检查文件类型
-	ext := filepath.Ext(file.Filename)
-	allowedExts := map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true}
-	if !allowedExts[ext] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的文件格式"})
+	// 根据文件头检查文件类型，扩展名只用于生成文件名
+	contentType, gerr := checkUploadType(file, avatarTypes, nil)
+	if gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": "不支持的文件格式，仅支持 PNG、JPEG、GIF 和 WebP 图片"})
 		return
 	}
+	if file.Size > maxAvatarSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "头像太大，最大支持5MB"})
+		return
+	}
+	// 头像同样计入用户的存储用量
+	if gerr := checkStorageQuota(models.StorageOwnerUser, userID, file.Size); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+	ext := avatarExtensions[contentType]
 
-	// 生成文件名
+	// 生成文件名，先保存到暂存目录
 	fileName := fmt.Sprintf("avatar_%d_%d%s", userID, time.Now().UnixNano(), ext)
-	filePath := filepath.Join("./uploads/avatars", fileName)
-
-	// 确保目录存在
-	if err := os.MkdirAll("./uploads/avatars", os.ModePerm); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建目录失败"})
+	stagedPath := filepath.Join(uploadTempDir(), fileName)
+	if err := c.SaveUploadedFile(file, stagedPath); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存头像失败"})
 		return
 	}
 
-	// 保存文件
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存头像失败"})
+	// 去除 EXIF 等元数据（手机拍摄的照片可能包含位置信息），生成缩略图后写入存储
+	avatarURL := "/uploads/avatars/" + fileName
+	attachment := models.Attachment{
+		FileURL:      avatarURL,
+		OriginalName: file.Filename,
+		Size:         file.Size,
+		ContentType:  contentType,
+		Kind:         models.AttachmentKindAvatar,
+		UploaderID:   userID,
+	}
+	if gerr := saveAttachment(&attachment, stagedPath); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
 		return
 	}
 
 	// 更新用户头像
-	avatarURL := "/uploads/avatars/" + fileName
+	var user models.User
+	models.DB.Select("avatar").First(&user, userID)
 	if err := models.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
 		"avatar":     avatarURL,
 		"updated_at": time.Now(),
 	}).Error; err != nil {
+		deleteAttachment(&attachment)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新头像失败"})
 		return
 	}
 
+	// 删除不再使用的旧头像
+	if user.Avatar != "" {
+		removeUploadedFile(user.Avatar)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"avatar":  avatarURL,
-		"message": "头像上传成功",
+		"success":     true,
+		"avatar":      avatarURL,
+		"width":       attachment.Width,
+		"height":      attachment.Height,
+		"blurhash":    attachment.Blurhash,
+		"thumbnails":  attachment.Thumbnails,
+		"scan_status": attachment.ScanStatus,
+		"message":     "头像上传成功",
 	})
 }
 
@@ -0,0 +1,425 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go-chat/models"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 断点续传的默认配置
+const (
+	defaultResumableMaxSize = 500 * 1024 * 1024 // 单个文件上限，环境变量 UPLOAD_RESUMABLE_MAX_SIZE 覆盖
+	defaultUploadSessionTTL = 24 * time.Hour    // 会话闲置多久后过期，环境变量 UPLOAD_SESSION_TTL 覆盖
+	maxChunkSize            = 16 * 1024 * 1024  // 单个分片上限
+	recommendedChunkSize    = 5 * 1024 * 1024   // 建议客户端使用的分片大小
+	uploadSessionInterval   = 10 * time.Minute  // 清理过期会话的间隔
+)
+
+// createUploadSessionRequest 创建断点续传会话的请求
+type createUploadSessionRequest struct {
+	FileName string `json:"file_name" binding:"required"`
+	Size     int64  `json:"size" binding:"required"`
+	Checksum string `json:"checksum" binding:"required"` // 整个文件的 SHA-256（十六进制）
+}
+
+// resumableMaxSize 断点续传的单个文件上限（环境变量 UPLOAD_RESUMABLE_MAX_SIZE，如 2GB）
+func resumableMaxSize() int64 {
+	if v := os.Getenv("UPLOAD_RESUMABLE_MAX_SIZE"); v != "" {
+		if n, err := parseByteSize(v); err == nil {
+			return n
+		}
+		log.Printf("警告: UPLOAD_RESUMABLE_MAX_SIZE=%s 无效，使用默认值", v)
+	}
+	return defaultResumableMaxSize
+}
+
+// uploadSessionTTL 会话闲置过期时间（环境变量 UPLOAD_SESSION_TTL，如 12h、2d）
+func uploadSessionTTL() time.Duration {
+	if v := os.Getenv("UPLOAD_SESSION_TTL"); v != "" {
+		if d, err := parseCommandDuration(v); err == nil {
+			return d
+		}
+		log.Printf("警告: UPLOAD_SESSION_TTL=%s 无效，使用默认值", v)
+	}
+	return defaultUploadSessionTTL
+}
+
+// uploadSessionResponse 会话信息，offset 为下一个分片应从哪个字节开始
+func uploadSessionResponse(session *models.UploadSession) gin.H {
+	return gin.H{
+		"upload_id":  session.ID,
+		"file_name":  session.FileName,
+		"size":       session.Size,
+		"offset":     session.Offset,
+		"status":     session.Status,
+		"file_url":   session.FileURL,
+		"chunk_size": recommendedChunkSize,
+		"expires_at": session.ExpiresAt,
+	}
+}
+
+// CreateUploadSession 创建断点续传会话：POST /upload/sessions，随后按偏移量上传分片，最后调用 complete
+func CreateUploadSession(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var req createUploadSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	req.FileName = filepath.Base(strings.TrimSpace(req.FileName))
+	req.Checksum = strings.ToLower(req.Checksum)
+	if checksum, err := hex.DecodeString(req.Checksum); err != nil || len(checksum) != sha256.Size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "checksum 必须是 SHA-256 的十六进制值"})
+		return
+	}
+	if gerr := checkUploadExtension(req.FileName); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+	// 同时受断点续传上限和分类型大小限制约束
+	if max := min(resumableMaxSize(), loadUploadPolicy().maxSizeForName(req.FileName)); req.Size <= 0 || req.Size > max {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件大小无效，最大支持%s", formatByteSize(max))})
+		return
+	}
+	if gerr := checkStorageQuota(models.StorageOwnerUser, userID, req.Size); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	if err := os.MkdirAll(uploadTempDir(), os.ModePerm); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建上传会话失败"})
+		return
+	}
+
+	session := models.UploadSession{
+		UserID:    userID,
+		FileName:  req.FileName,
+		Size:      req.Size,
+		Checksum:  req.Checksum,
+		Status:    models.UploadSessionActive,
+		ExpiresAt: time.Now().Add(uploadSessionTTL()),
+	}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		session.TempPath = filepath.Join(uploadTempDir(), fmt.Sprintf("%d.part", session.ID))
+		f, err := os.Create(session.TempPath)
+		if err != nil {
+			return err
+		}
+		f.Close()
+		return tx.Model(&session).Update("temp_path", session.TempPath).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建上传会话失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, uploadSessionResponse(&session))
+}
+
+// loadUploadSession 查询当前用户的上传会话
+func loadUploadSession(c *gin.Context) (*models.UploadSession, bool) {
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的上传会话ID"})
+		return nil, false
+	}
+
+	userID := c.MustGet("userID").(uint)
+	var session models.UploadSession
+	if err := models.DB.Where("id = ? AND user_id = ?", uint(sessionID), userID).First(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "上传会话不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取上传会话失败"})
+		}
+		return nil, false
+	}
+	return &session, true
+}
+
+// loadActiveUploadSession 查询仍可继续上传的会话
+func loadActiveUploadSession(c *gin.Context) (*models.UploadSession, bool) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return nil, false
+	}
+	if session.Status != models.UploadSessionActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "上传正在完成或已完成"})
+		return nil, false
+	}
+	if time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "上传会话已过期，请重新上传"})
+		return nil, false
+	}
+	return session, true
+}
+
+// GetUploadSession 查询上传进度，客户端中断后据此从 offset 处继续上传
+func GetUploadSession(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, uploadSessionResponse(session))
+}
+
+// UploadChunk 上传一个分片：PATCH /upload/sessions/:id，请求体为分片内容，
+// Upload-Offset 请求头为分片在文件中的起始位置，必须等于已接收的字节数；
+// 可选的 Upload-Checksum 请求头为分片的 SHA-256（十六进制），不一致时拒绝
+func UploadChunk(c *gin.Context) {
+	session, ok := loadActiveUploadSession(c)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少或无效的 Upload-Offset"})
+		return
+	}
+	if offset != session.Offset {
+		c.JSON(http.StatusConflict, gin.H{"error": "分片偏移量不匹配", "offset": session.Offset})
+		return
+	}
+
+	chunk, err := io.ReadAll(io.LimitReader(c.Request.Body, maxChunkSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取分片失败"})
+		return
+	}
+	if len(chunk) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分片内容为空"})
+		return
+	}
+	if len(chunk) > maxChunkSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("分片太大，最大支持%s", formatByteSize(maxChunkSize))})
+		return
+	}
+	if offset+int64(len(chunk)) > session.Size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分片超出文件大小"})
+		return
+	}
+	if expected := c.GetHeader("Upload-Checksum"); expected != "" {
+		sum := sha256.Sum256(chunk)
+		if !strings.EqualFold(expected, hex.EncodeToString(sum[:])) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "分片校验失败，请重新上传该分片", "offset": session.Offset})
+			return
+		}
+	}
+
+	f, err := os.OpenFile(session.TempPath, os.O_WRONLY, 0644)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存分片失败"})
+		return
+	}
+	_, err = f.WriteAt(chunk, offset)
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存分片失败"})
+		return
+	}
+
+	// 按旧的偏移量条件更新，避免并发上传同一位置的分片时重复推进
+	newOffset := offset + int64(len(chunk))
+	result := models.DB.Model(&models.UploadSession{}).
+		Where("id = ? AND upload_offset = ?", session.ID, offset).
+		Updates(map[string]interface{}{
+			"upload_offset": newOffset,
+			"expires_at":    time.Now().Add(uploadSessionTTL()),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存分片失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		models.DB.First(session, session.ID)
+		c.JSON(http.StatusConflict, gin.H{"error": "分片偏移量不匹配", "offset": session.Offset})
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	c.JSON(http.StatusOK, gin.H{"offset": newOffset, "size": session.Size})
+}
+
+// CompleteUploadSession 所有分片上传后合并为附件：校验整个文件的 SHA-256、文件类型和该类型的大小限制，
+// 返回与 /upload 相同的文件信息
+func CompleteUploadSession(c *gin.Context) {
+	session, ok := loadActiveUploadSession(c)
+	if !ok {
+		return
+	}
+	if session.Offset != session.Size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件尚未上传完成", "offset": session.Offset})
+		return
+	}
+
+	// 先按状态条件占用会话，与分片上传的偏移量条件一样，避免并发的完成请求重复生成附件
+	result := models.DB.Model(&models.UploadSession{}).
+		Where("id = ? AND status = ?", session.ID, models.UploadSessionActive).
+		Updates(map[string]interface{}{
+			"status":     models.UploadSessionCompleting,
+			"expires_at": time.Now().Add(uploadSessionTTL()),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "完成上传失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "上传正在完成或已完成"})
+		return
+	}
+	// release 可重试的错误时把会话恢复为上传中，客户端可以再次请求完成
+	release := func() {
+		models.DB.Model(&models.UploadSession{}).
+			Where("id = ? AND status = ?", session.ID, models.UploadSessionCompleting).
+			Update("status", models.UploadSessionActive)
+	}
+
+	sum, err := fileChecksum(session.TempPath)
+	if err != nil {
+		release()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取文件失败"})
+		return
+	}
+	if sum != session.Checksum {
+		// 内容已损坏，只能重新上传
+		abortUploadSession(session)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件校验失败，请重新上传"})
+		return
+	}
+
+	policy := loadUploadPolicy()
+	f, err := os.Open(session.TempPath)
+	if err != nil {
+		release()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取文件失败"})
+		return
+	}
+	contentType, gerr := checkContentType(f, session.FileName, policy.allowed, policy.denied)
+	f.Close()
+	if gerr == nil {
+		if max := policy.maxSizeFor(contentType); session.Size > max {
+			gerr = newGroupError(http.StatusRequestEntityTooLarge, fmt.Sprintf("文件太大，该类型最大支持%s", formatByteSize(max)))
+		}
+	}
+	if gerr != nil {
+		abortUploadSession(session)
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	// 创建会话后可能又上传了其他文件，完成前再检查一次配额，会话保留以便清理空间后重试
+	if gerr := checkStorageQuota(models.StorageOwnerUser, session.UserID, session.Size); gerr != nil {
+		release()
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	// 临时文件即暂存文件，处理后写入存储后端，文件名规则与 /upload 一致
+	fileName := fmt.Sprintf("%d_%d%s", session.UserID, time.Now().UnixNano(), filepath.Ext(session.FileName))
+	attachment := models.Attachment{
+		FileURL:      "/uploads/" + fileName,
+		OriginalName: session.FileName,
+		Size:         session.Size,
+		ContentType:  contentType,
+		Kind:         models.AttachmentKindFile,
+		UploaderID:   session.UserID,
+	}
+	if gerr := saveAttachment(&attachment, session.TempPath); gerr != nil {
+		models.DB.Delete(session)
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	now := time.Now()
+	models.DB.Model(session).Updates(map[string]interface{}{
+		"status":       models.UploadSessionCompleted,
+		"file_url":     attachment.FileURL,
+		"temp_path":    "",
+		"completed_at": now,
+	})
+
+	c.JSON(http.StatusOK, uploadResponse(&attachment))
+}
+
+// AbortUploadSession 取消上传并删除已接收的分片
+func AbortUploadSession(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+	if session.Status != models.UploadSessionActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "上传正在完成或已完成"})
+		return
+	}
+	abortUploadSession(session)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "已取消上传"})
+}
+
+// abortUploadSession 删除会话和临时文件
+func abortUploadSession(session *models.UploadSession) {
+	if session.TempPath != "" {
+		if err := os.Remove(session.TempPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除上传临时文件失败: %v", err)
+		}
+	}
+	models.DB.Delete(session)
+}
+
+// fileChecksum 计算文件的 SHA-256
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// StartUploadSessionWorker 启动后台任务，定期清理过期的上传会话：
+// 未完成的会话删除已接收的分片，已完成的会话只删除记录
+func StartUploadSessionWorker() {
+	go func() {
+		ticker := time.NewTicker(uploadSessionInterval)
+		defer ticker.Stop()
+
+		for {
+			cleanupUploadSessions()
+			<-ticker.C
+		}
+	}()
+}
+
+func cleanupUploadSessions() {
+	var sessions []models.UploadSession
+	if err := models.DB.Where("expires_at < ?", time.Now()).Limit(500).Find(&sessions).Error; err != nil {
+		log.Printf("查询过期上传会话失败: %v", err)
+		return
+	}
+	for i := range sessions {
+		abortUploadSession(&sessions[i])
+	}
+	if len(sessions) > 0 {
+		log.Printf("已清理 %d 个过期的上传会话", len(sessions))
+	}
+}
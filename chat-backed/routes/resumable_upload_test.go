@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"go-chat/models"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createUploadedSession 创建所有分片都已上传的会话
+func createUploadedSession(t *testing.T, userID uint, content string) *models.UploadSession {
+	t.Helper()
+	sum := sha256.Sum256([]byte(content))
+	session := &models.UploadSession{
+		UserID: userID, FileName: "notes.txt", Size: int64(len(content)), Offset: int64(len(content)),
+		Checksum: hex.EncodeToString(sum[:]), Status: models.UploadSessionActive,
+		TempPath: filepath.Join(t.TempDir(), "1.part"), ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := os.WriteFile(session.TempPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.DB.Create(session).Error; err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestCompleteUploadSessionOnce(t *testing.T) {
+	setupTestDB(t)
+	session := createUploadedSession(t, 1, "meeting notes\n")
+
+	// 并发的完成请求只有一个能生成附件
+	codes := make([]int, 4)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = callHandler(CompleteUploadSession, 1, "", "id", session.ID).Code
+		}()
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusOK:
+			ok++
+		case http.StatusConflict:
+		default:
+			t.Errorf("完成上传: status = %d", code)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("成功完成 %d 次，want 1（%v）", ok, codes)
+	}
+	var count int64
+	models.DB.Model(&models.Attachment{}).Where("uploader_id = ?", 1).Count(&count)
+	if count != 1 {
+		t.Errorf("生成了 %d 个附件，want 1", count)
+	}
+	models.DB.First(session, session.ID)
+	if session.Status != models.UploadSessionCompleted || session.FileURL == "" {
+		t.Errorf("session = %+v, want completed", session)
+	}
+
+	if w := callHandler(CompleteUploadSession, 1, "", "id", session.ID); w.Code != http.StatusConflict {
+		t.Errorf("重复完成: status = %d, want 409", w.Code)
+	}
+}
+
+func TestCompleteUploadSessionReleasesOnQuota(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("STORAGE_QUOTA_USER", "10")
+	session := createUploadedSession(t, 1, "more than ten bytes")
+
+	if w := callHandler(CompleteUploadSession, 1, "", "id", session.ID); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("超出配额: status = %d, body = %s", w.Code, w.Body)
+	}
+	// 配额不足可以清理空间后重试，会话恢复为上传中
+	models.DB.First(session, session.ID)
+	if session.Status != models.UploadSessionActive {
+		t.Errorf("status = %q, want uploading", session.Status)
+	}
+}
@@ -0,0 +1,350 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-chat/models"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 保留策略执行间隔
+const retentionInterval = time.Hour
+
+// 每批处理的消息数量以及批次之间的停顿，避免长时间占用数据库
+const (
+	retentionBatchSize  = 500
+	retentionBatchPause = 100 * time.Millisecond
+)
+
+// 归档文件写入锁，保证同一文件的追加不会交错
+var archiveFileMutex sync.Mutex
+
+// globalRetentionPolicy 读取全局消息保留策略：
+// MESSAGE_RETENTION_DAYS（删除早于 N 天的消息）、MESSAGE_RETENTION_MAX_MESSAGES（每个会话保留最近 N 条）、
+// MESSAGE_RETENTION_ACTION（delete 或 archive，默认 delete）
+func globalRetentionPolicy() models.RetentionPolicy {
+	policy := models.RetentionPolicy{Action: models.RetentionActionDelete}
+	if v, err := strconv.Atoi(os.Getenv("MESSAGE_RETENTION_DAYS")); err == nil && v > 0 {
+		policy.MaxAgeDays = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("MESSAGE_RETENTION_MAX_MESSAGES")); err == nil && v > 0 {
+		policy.MaxMessages = v
+	}
+	if action := os.Getenv("MESSAGE_RETENTION_ACTION"); models.IsValidRetentionAction(action) {
+		policy.Action = action
+	}
+	return policy
+}
+
+// messageArchiveDir 过期消息归档目录（环境变量 MESSAGE_ARCHIVE_DIR，默认 ./archives）
+func messageArchiveDir() string {
+	if dir := os.Getenv("MESSAGE_ARCHIVE_DIR"); dir != "" {
+		return dir
+	}
+	return "./archives"
+}
+
+// StartRetentionWorker 启动后台任务，定期按全局和群组的保留策略清理消息
+func StartRetentionWorker() {
+	go func() {
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+
+		for {
+			enforceRetention()
+			<-ticker.C
+		}
+	}()
+}
+
+// enforceRetention 执行一轮保留策略
+func enforceRetention() {
+	global := globalRetentionPolicy()
+
+	var groupPolicies []models.GroupRetentionPolicy
+	if err := models.DB.Find(&groupPolicies).Error; err != nil {
+		log.Printf("查询群组保留策略失败: %v", err)
+		return
+	}
+	overridden := make([]uint, 0, len(groupPolicies))
+	for _, p := range groupPolicies {
+		overridden = append(overridden, p.GroupID)
+	}
+
+	total := 0
+
+	// 全局策略：按时间清理所有未设置群组策略的消息（全局聊天、私聊和群聊）
+	if global.MaxAgeDays > 0 {
+		scope := models.DB.Where("created_at < ?", time.Now().AddDate(0, 0, -global.MaxAgeDays))
+		if len(overridden) > 0 {
+			scope = scope.Where("group_id NOT IN ?", overridden)
+		}
+		total += expireMessages(scope, global.Action, "global")
+	}
+
+	// 全局策略：按条数清理全局聊天、私聊以及未设置群组策略的群组
+	if global.MaxMessages > 0 {
+		total += expireBeyondCount(models.DB.Where("group_id = 0 AND target_id = 0"), global.MaxMessages, global.Action, "global")
+
+		// 私聊按双方（与 models.DirectConversationKey 相同的排序）分别计数，只处理超出条数的会话
+		var pairs []struct{ Low, High uint }
+		if err := models.DB.Model(&models.Message{}).
+			Select("LEAST(user_id, target_id) AS low, GREATEST(user_id, target_id) AS high").
+			Where("group_id = 0 AND target_id > 0").
+			Group("low, high").Having("COUNT(*) > ?", global.MaxMessages).
+			Scan(&pairs).Error; err != nil {
+			log.Printf("查询私聊会话失败: %v", err)
+		}
+		for _, p := range pairs {
+			scope := models.DB.Where("group_id = 0 AND ((user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?))",
+				p.Low, p.High, p.High, p.Low)
+			total += expireBeyondCount(scope, global.MaxMessages, global.Action, fmt.Sprintf("direct-%d-%d", p.Low, p.High))
+		}
+
+		var groupIDs []uint
+		query := models.DB.Model(&models.Group{})
+		if len(overridden) > 0 {
+			query = query.Where("id NOT IN ?", overridden)
+		}
+		query.Pluck("id", &groupIDs)
+		for _, id := range groupIDs {
+			total += expireBeyondCount(models.DB.Where("group_id = ?", id), global.MaxMessages, global.Action, fmt.Sprintf("group-%d", id))
+		}
+	}
+
+	// 群组策略
+	for _, gp := range groupPolicies {
+		policy := gp.Policy()
+		label := fmt.Sprintf("group-%d", gp.GroupID)
+		if policy.MaxAgeDays > 0 {
+			scope := models.DB.Where("group_id = ? AND created_at < ?", gp.GroupID, time.Now().AddDate(0, 0, -policy.MaxAgeDays))
+			total += expireMessages(scope, policy.Action, label)
+		}
+		if policy.MaxMessages > 0 {
+			total += expireBeyondCount(models.DB.Where("group_id = ?", gp.GroupID), policy.MaxMessages, policy.Action, label)
+		}
+	}
+
+	if total > 0 {
+		log.Printf("✅ 消息保留策略执行完成，共清理 %d 条消息", total)
+	}
+}
+
+// expireBeyondCount 仅保留 scope 范围内最近的 keep 条消息，其余按 action 处理
+func expireBeyondCount(scope *gorm.DB, keep int, action, label string) int {
+	// 找到第 keep 条最新消息的ID，比它更早的消息都超出保留条数
+	var cutoffIDs []uint
+	if err := models.DB.Model(&models.Message{}).Where(scope).
+		Order("id desc").Offset(keep-1).Limit(1).
+		Pluck("id", &cutoffIDs).Error; err != nil || len(cutoffIDs) == 0 {
+		return 0
+	}
+	return expireMessages(models.DB.Where(scope).Where("id < ?", cutoffIDs[0]), action, label)
+}
+
+// expireMessages 分批处理 scope 范围内的过期消息：跳过置顶消息，按主键分批归档或删除，避免长时间锁表
+func expireMessages(scope *gorm.DB, action, label string) int {
+	pinned := models.DB.Model(&models.PinnedMessage{}).Select("message_id")
+	total := 0
+	lastID := uint(0)
+
+	for {
+		var messages []models.Message
+		if err := models.DB.Where(scope).
+			Where("id > ? AND id NOT IN (?)", lastID, pinned).
+			Order("id asc").Limit(retentionBatchSize).
+			Find(&messages).Error; err != nil {
+			log.Printf("查询过期消息失败: %v", err)
+			return total
+		}
+		if len(messages) == 0 {
+			return total
+		}
+
+		if action == models.RetentionActionArchive {
+			if err := archiveMessages(label, messages); err != nil {
+				// 归档失败时不删除，等待下一轮重试
+				log.Printf("归档过期消息失败: %v", err)
+				return total
+			}
+		}
+
+		ids := make([]uint, 0, len(messages))
+		for _, m := range messages {
+			ids = append(ids, m.ID)
+		}
+		if err := models.DB.Where("id IN ?", ids).Delete(&models.Message{}).Error; err != nil {
+			log.Printf("删除过期消息失败: %v", err)
+			return total
+		}
+
+		total += len(messages)
+		lastID = ids[len(ids)-1]
+		if len(messages) < retentionBatchSize {
+			return total
+		}
+		time.Sleep(retentionBatchPause)
+	}
+}
+
+// archiveMessages 将消息以 JSON Lines 格式追加到归档文件（按来源和日期分文件）
+func archiveMessages(label string, messages []models.Message) error {
+	archiveFileMutex.Lock()
+	defer archiveFileMutex.Unlock()
+
+	dir := messageArchiveDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("messages-%s-%s.jsonl", label, time.Now().Format("2006-01-02"))
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	for i := range messages {
+		if err := encoder.Encode(&messages[i]); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// getGroupRetention 获取群组的消息保留策略（群组未设置时返回全局策略）
+func getGroupRetention(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), ""); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var policy models.GroupRetentionPolicy
+	if err := models.DB.Where("group_id = ?", uint(groupID)).First(&policy).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取保留策略失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"source": "global",
+			"policy": globalRetentionPolicy(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"source": "group",
+		"policy": policy,
+	})
+}
+
+// updateGroupRetention 设置群组的消息保留策略（需要修改群信息权限）
+func updateGroupRetention(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	var req models.RetentionPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if req.MaxAgeDays < 0 || req.MaxMessages < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "保留天数和保留条数不能为负数"})
+		return
+	}
+	if req.Action == "" {
+		req.Action = models.RetentionActionDelete
+	}
+	if !models.IsValidRetentionAction(req.Action) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action只允许delete或archive"})
+		return
+	}
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermEditInfo); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	policy := models.GroupRetentionPolicy{GroupID: uint(groupID)}
+	var before interface{}
+	if err := models.DB.Where("group_id = ?", uint(groupID)).First(&policy).Error; err == nil {
+		before = policy.Policy()
+	}
+
+	policy.MaxAgeDays = req.MaxAgeDays
+	policy.MaxMessages = req.MaxMessages
+	policy.Action = req.Action
+	policy.UpdatedBy = userID
+	if err := models.DB.Save(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存保留策略失败"})
+		return
+	}
+
+	recordGroupAudit(models.DB, uint(groupID), userID, models.AuditRetentionChange, uint(groupID), before, policy.Policy())
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "保留策略已更新",
+		"policy":  policy,
+	})
+}
+
+// deleteGroupRetention 删除群组的保留策略，恢复使用全局策略（需要修改群信息权限）
+func deleteGroupRetention(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的群组ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	if _, _, gerr := authorizeGroup(userID, uint(groupID), models.PermEditInfo); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	var policy models.GroupRetentionPolicy
+	if err := models.DB.Where("group_id = ?", uint(groupID)).First(&policy).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "群组未设置保留策略"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取保留策略失败"})
+		}
+		return
+	}
+
+	if err := models.DB.Delete(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除保留策略失败"})
+		return
+	}
+
+	recordGroupAudit(models.DB, uint(groupID), userID, models.AuditRetentionChange, uint(groupID), policy.Policy(), nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已恢复使用全局保留策略",
+		"policy":  globalRetentionPolicy(),
+	})
+}
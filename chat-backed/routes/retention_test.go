@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"go-chat/models"
+	"testing"
+)
+
+// MESSAGE_RETENTION_MAX_MESSAGES 对每个私聊会话分别生效
+func TestRetentionMaxMessagesTrimsDirectMessages(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("MESSAGE_RETENTION_MAX_MESSAGES", "3")
+
+	send := func(from, to uint, n int) {
+		for i := 0; i < n; i++ {
+			models.DB.Create(&models.Message{UserID: from, TargetID: to, Content: "hi", MessageType: "text"})
+		}
+	}
+	send(1, 2, 3)
+	send(2, 1, 2) // 用户 1 和 2 的会话共 5 条
+	send(1, 3, 2) // 用户 1 和 3 的会话未超出
+
+	enforceRetention()
+
+	count := func(a, b uint) int64 {
+		var n int64
+		models.DB.Model(&models.Message{}).
+			Where("group_id = 0 AND ((user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?))", a, b, b, a).
+			Count(&n)
+		return n
+	}
+	if n := count(1, 2); n != 3 {
+		t.Errorf("用户 1 和 2 的会话剩余 %d 条，want 3", n)
+	}
+	if n := count(1, 3); n != 2 {
+		t.Errorf("用户 1 和 3 的会话剩余 %d 条，want 2", n)
+	}
+
+	// 保留的是最近的消息
+	var oldest models.Message
+	models.DB.Where("target_id IN ?", []uint{1, 2}).Order("id").First(&oldest)
+	if oldest.ID != 3 {
+		t.Errorf("最早保留的消息 ID = %d, want 3", oldest.ID)
+	}
+}
@@ -0,0 +1,243 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"go-chat/models"
+	"go-chat/scanner"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	scanInterval    = time.Minute
+	scanConcurrency = 2
+	scanBatchSize   = 100
+	maxScanBackoff  = time.Hour // 扫描失败后重试间隔的上限
+)
+
+var (
+	// fileScanner 上传文件的恶意软件扫描器，为空表示不扫描
+	fileScanner scanner.Scanner
+
+	scanSlots   = make(chan struct{}, scanConcurrency)
+	scanningMu  sync.Mutex
+	scanningIDs = make(map[uint]bool) // 正在扫描的附件，避免重复扫描
+)
+
+// InitScanner 根据环境变量（SCANNER 等，见 scanner.FromEnv）初始化扫描器
+func InitScanner() {
+	s, err := scanner.FromEnv()
+	if err != nil {
+		log.Fatalf("初始化文件扫描器失败: %v", err)
+	}
+	fileScanner = s
+}
+
+// initialScanStatus 新上传文件的扫描状态：未启用扫描时为空；相同内容已扫描通过时直接通过，否则等待扫描
+func initialScanStatus(blobHash string) string {
+	if fileScanner == nil {
+		return ""
+	}
+	return models.BlobScanStatus(models.DB, blobHash)
+}
+
+// StartScanWorker 启动扫描任务，定期重试等待扫描的附件（如扫描器暂时不可用或服务重启时未完成的扫描）
+func StartScanWorker() {
+	if fileScanner == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(scanInterval)
+		defer ticker.Stop()
+
+		for {
+			scanPendingAttachments()
+			<-ticker.C
+		}
+	}()
+}
+
+// scanPendingAttachments 按ID分批扫描到了重试时间的附件，扫描失败的附件推迟到下次重试时间，不会阻塞后面的附件
+func scanPendingAttachments() {
+	var lastID uint
+	for {
+		var ids []uint
+		if err := models.DB.Model(&models.Attachment{}).
+			Where("scan_status = ? AND kind <> ? AND id > ?", models.ScanPending, models.AttachmentKindThumbnail, lastID).
+			Where("next_scan_at IS NULL OR next_scan_at <= ?", time.Now()).
+			Order("id").Limit(scanBatchSize).Pluck("id", &ids).Error; err != nil {
+			log.Printf("查询待扫描的附件失败: %v", err)
+			return
+		}
+		for _, id := range ids {
+			scanAttachment(id)
+		}
+		if len(ids) < scanBatchSize {
+			return
+		}
+		lastID = ids[len(ids)-1]
+	}
+}
+
+// scanRetryDelay 第 attempts 次扫描失败后的重试间隔，从 scanInterval 开始翻倍，最长 maxScanBackoff
+func scanRetryDelay(attempts int) time.Duration {
+	delay := scanInterval
+	for i := 1; i < attempts && delay < maxScanBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxScanBackoff)
+}
+
+// scanAttachment 扫描附件，通过后解除隔离，检测到恶意软件时删除文件，超出扫描器的大小限制时标记为无法扫描。
+// 其他扫描失败时保持等待状态，由扫描任务退避重试
+func scanAttachment(id uint) {
+	scanningMu.Lock()
+	if scanningIDs[id] {
+		scanningMu.Unlock()
+		return
+	}
+	scanningIDs[id] = true
+	scanningMu.Unlock()
+	defer func() {
+		scanningMu.Lock()
+		delete(scanningIDs, id)
+		scanningMu.Unlock()
+	}()
+
+	scanSlots <- struct{}{}
+	defer func() { <-scanSlots }()
+
+	var attachment models.Attachment
+	if err := models.DB.First(&attachment, id).Error; err != nil || attachment.ScanStatus != models.ScanPending {
+		return
+	}
+	key, _ := attachmentStorageKey(&attachment)
+	rc, err := fileStorage.Open(key)
+	if err != nil {
+		log.Printf("读取待扫描文件 %s 失败: %v", attachment.FileURL, err)
+		deferScan(&attachment)
+		return
+	}
+	result, err := fileScanner.Scan(context.Background(), rc)
+	rc.Close()
+	if errors.Is(err, scanner.ErrUnscannable) {
+		log.Printf("文件 %s 无法扫描: %v", attachment.FileURL, err)
+		markAttachmentUnscannable(&attachment)
+		return
+	}
+	if err != nil {
+		log.Printf("扫描文件 %s 失败: %v", attachment.FileURL, err)
+		deferScan(&attachment)
+		return
+	}
+
+	if result.Infected {
+		rejectInfectedAttachment(&attachment, result.Signature)
+		return
+	}
+	markAttachmentClean(&attachment)
+}
+
+// deferScan 记录一次扫描失败，按失败次数推迟下次重试
+func deferScan(attachment *models.Attachment) {
+	attempts := attachment.ScanAttempts + 1
+	if err := models.DB.Model(&models.Attachment{}).Where("id = ?", attachment.ID).Updates(map[string]interface{}{
+		"scan_attempts": attempts,
+		"next_scan_at":  time.Now().Add(scanRetryDelay(attempts)),
+	}).Error; err != nil {
+		log.Printf("记录附件扫描失败次数失败: %v", err)
+	}
+}
+
+// markAttachmentClean 解除附件及其缩略图的隔离，并通知引用该文件的消息所在会话
+func markAttachmentClean(attachment *models.Attachment) {
+	finishScan(attachment, models.ScanClean, "")
+}
+
+// markAttachmentUnscannable 将无法扫描的附件及其缩略图保持隔离，不再重试，并通知引用该文件的消息所在会话
+func markAttachmentUnscannable(attachment *models.Attachment) {
+	finishScan(attachment, models.ScanUnscannable, models.FileStatusUnscannable)
+}
+
+// finishScan 更新附件及其缩略图的扫描结果和引用该文件的消息的文件状态，并通知消息所在会话
+func finishScan(attachment *models.Attachment, scanStatus, fileStatus string) {
+	var messages []models.Message
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Attachment{}).
+			Where("(id = ? OR parent_id = ?) AND scan_status = ?", attachment.ID, attachment.ID, models.ScanPending).
+			Update("scan_status", scanStatus).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_url = ? AND file_status = ?", attachment.FileURL, models.FileStatusScanning).Find(&messages).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Message{}).
+			Where("file_url = ? AND file_status = ?", attachment.FileURL, models.FileStatusScanning).
+			Update("file_status", fileStatus).Error
+	})
+	if err != nil {
+		log.Printf("更新附件扫描状态失败: %v", err)
+		return
+	}
+	notifyFileStatus(attachment, messages, fileStatus)
+}
+
+// fileStatusForScan 引用附件的消息应显示的文件状态
+func fileStatusForScan(scanStatus string) string {
+	switch scanStatus {
+	case models.ScanPending:
+		return models.FileStatusScanning
+	case models.ScanUnscannable:
+		return models.FileStatusUnscannable
+	default:
+		return ""
+	}
+}
+
+// rejectInfectedAttachment 记录检测结果，将引用该文件的消息标记为未通过扫描并删除文件
+func rejectInfectedAttachment(attachment *models.Attachment, signature string) {
+	log.Printf("⚠️ 检测到恶意文件: 附件 %d（%s，%s）上传者 %d，特征 %s",
+		attachment.ID, attachment.FileURL, attachment.OriginalName, attachment.UploaderID, signature)
+
+	var messages []models.Message
+	models.DB.Where("file_url = ?", attachment.FileURL).Find(&messages)
+	if err := models.DB.Model(&models.Message{}).Where("file_url = ?", attachment.FileURL).
+		Update("file_status", models.FileStatusInfected).Error; err != nil {
+		log.Printf("更新消息文件状态失败: %v", err)
+		return
+	}
+	if err := deleteAttachment(attachment); err != nil {
+		log.Printf("删除恶意文件失败: %v", err)
+	}
+	notifyFileStatus(attachment, messages, models.FileStatusInfected)
+}
+
+// notifyFileStatus 扫描完成后通知引用该文件的消息所在会话；文件尚未发送时只通知上传者
+func notifyFileStatus(attachment *models.Attachment, messages []models.Message, status string) {
+	if len(messages) == 0 {
+		SendBroadcastMessage(BroadcastMessage{
+			Type:       "file_scanned",
+			UserID:     attachment.UploaderID,
+			FileURL:    attachment.FileURL,
+			FileStatus: status,
+			Recipients: []uint{attachment.UploaderID},
+		})
+		return
+	}
+	for _, m := range messages {
+		SendBroadcastMessage(BroadcastMessage{
+			Type:       "file_scanned",
+			MessageID:  m.ID,
+			UserID:     m.UserID,
+			FileURL:    m.FileURL,
+			FileStatus: status,
+			Target:     m.TargetID,
+			GroupID:    m.GroupID,
+			ChannelID:  m.ChannelID,
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-chat/models"
+	"go-chat/scanner"
+	"go-chat/storage"
+	"testing"
+	"time"
+)
+
+// createPendingUpload 保存一个等待扫描的文件、它的缩略图，以及引用该文件的群聊消息
+func createPendingUpload(t *testing.T) (*models.Attachment, *models.Attachment, *models.Message) {
+	t.Helper()
+	hash, size, err := models.PutBlob(fileStorage, bytes.NewReader([]byte("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR")), "image/png")
+	if err != nil {
+		t.Fatalf("PutBlob: %v", err)
+	}
+	thumbHash, thumbSize, err := models.PutBlob(fileStorage, bytes.NewReader([]byte("thumbnail")), "image/jpeg")
+	if err != nil {
+		t.Fatalf("PutBlob: %v", err)
+	}
+
+	attachment := &models.Attachment{
+		FileURL: "/uploads/1_1700000000.png", Size: size, ContentType: "image/png", BlobHash: hash,
+		ScanStatus: models.ScanPending, Kind: models.AttachmentKindFile, UploaderID: 1, GroupID: 7,
+	}
+	if err := models.DB.Create(attachment).Error; err != nil {
+		t.Fatal(err)
+	}
+	thumb := &models.Attachment{
+		FileURL: "/uploads/thumbs/1_1700000000_small.jpg", Size: thumbSize, BlobHash: thumbHash,
+		ScanStatus: models.ScanPending, Kind: models.AttachmentKindThumbnail, ParentID: attachment.ID, UploaderID: 1,
+	}
+	if err := models.DB.Create(thumb).Error; err != nil {
+		t.Fatal(err)
+	}
+	message := &models.Message{
+		UserID: 1, Username: "alice", Content: "photo.png", MessageType: "image", GroupID: 7,
+		FileURL: attachment.FileURL, FileName: "photo.png", FileStatus: models.FileStatusScanning,
+	}
+	if err := models.DB.Create(message).Error; err != nil {
+		t.Fatal(err)
+	}
+	attachment.MessageID = message.ID
+	models.DB.Model(attachment).Update("message_id", message.ID)
+	return attachment, thumb, message
+}
+
+func withScanner(t *testing.T, s scanner.Scanner) {
+	old := fileScanner
+	fileScanner = s
+	t.Cleanup(func() { fileScanner = old })
+}
+
+func TestScanAttachmentClean(t *testing.T) {
+	drain := setupTestDB(t)
+	fake := &scanner.Fake{}
+	withScanner(t, fake)
+	attachment, thumb, message := createPendingUpload(t)
+
+	scanAttachment(attachment.ID)
+
+	if fake.Calls() != 1 {
+		t.Fatalf("扫描次数 = %d, want 1", fake.Calls())
+	}
+	for _, id := range []uint{attachment.ID, thumb.ID} {
+		var a models.Attachment
+		models.DB.First(&a, id)
+		if a.ScanStatus != models.ScanClean {
+			t.Errorf("附件 %d scan_status = %q, want clean", id, a.ScanStatus)
+		}
+	}
+	var m models.Message
+	models.DB.First(&m, message.ID)
+	if m.FileStatus != "" {
+		t.Errorf("file_status = %q, want empty", m.FileStatus)
+	}
+
+	msgs := drain()
+	if len(msgs) != 1 || msgs[0].Type != "file_scanned" || msgs[0].MessageID != message.ID || msgs[0].FileStatus != "" {
+		t.Errorf("广播 = %+v, want one file_scanned for message %d", msgs, message.ID)
+	}
+
+	// 已扫描通过的附件不再重复扫描
+	scanAttachment(attachment.ID)
+	if fake.Calls() != 1 {
+		t.Errorf("扫描次数 = %d, want 1", fake.Calls())
+	}
+}
+
+func TestScanAttachmentInfected(t *testing.T) {
+	drain := setupTestDB(t)
+	withScanner(t, &scanner.Fake{Result: scanner.Result{Infected: true, Signature: "Eicar-Signature"}})
+	attachment, thumb, message := createPendingUpload(t)
+
+	scanAttachment(attachment.ID)
+
+	var count int64
+	models.DB.Model(&models.Attachment{}).Where("id IN ?", []uint{attachment.ID, thumb.ID}).Count(&count)
+	if count != 0 {
+		t.Errorf("剩余附件 %d 条，want 0", count)
+	}
+	var m models.Message
+	models.DB.First(&m, message.ID)
+	if m.FileStatus != models.FileStatusInfected {
+		t.Errorf("file_status = %q, want infected", m.FileStatus)
+	}
+	for _, hash := range []string{attachment.BlobHash, thumb.BlobHash} {
+		var blob models.Blob
+		models.DB.First(&blob, "hash = ?", hash)
+		if blob.RefCount != 0 {
+			t.Errorf("blob %s ref_count = %d, want 0", hash[:8], blob.RefCount)
+		}
+	}
+
+	msgs := drain()
+	if len(msgs) != 1 || msgs[0].MessageID != message.ID || msgs[0].FileStatus != models.FileStatusInfected {
+		t.Errorf("广播 = %+v, want one infected file_scanned", msgs)
+	}
+}
+
+func TestScanAttachmentErrorStaysPending(t *testing.T) {
+	drain := setupTestDB(t)
+	withScanner(t, &scanner.Fake{Err: errors.New("clamd 不可用")})
+	attachment, thumb, message := createPendingUpload(t)
+
+	scanAttachment(attachment.ID)
+
+	for _, id := range []uint{attachment.ID, thumb.ID} {
+		var a models.Attachment
+		if err := models.DB.First(&a, id).Error; err != nil {
+			t.Fatalf("附件 %d 被删除: %v", id, err)
+		}
+		if a.ScanStatus != models.ScanPending {
+			t.Errorf("附件 %d scan_status = %q, want pending", id, a.ScanStatus)
+		}
+	}
+	var m models.Message
+	models.DB.First(&m, message.ID)
+	if m.FileStatus != models.FileStatusScanning {
+		t.Errorf("file_status = %q, want scanning", m.FileStatus)
+	}
+	if msgs := drain(); len(msgs) != 0 {
+		t.Errorf("广播 = %+v, want none", msgs)
+	}
+
+	// 记录失败次数并推迟下次重试
+	var a models.Attachment
+	models.DB.First(&a, attachment.ID)
+	if a.ScanAttempts != 1 || a.NextScanAt == nil || !a.NextScanAt.After(time.Now()) {
+		t.Errorf("scan_attempts = %d, next_scan_at = %v; want 1 and a future time", a.ScanAttempts, a.NextScanAt)
+	}
+}
+
+func TestScanAttachmentUnscannable(t *testing.T) {
+	drain := setupTestDB(t)
+	withScanner(t, &scanner.Fake{Err: fmt.Errorf("%w: INSTREAM size limit exceeded. ERROR", scanner.ErrUnscannable)})
+	attachment, thumb, message := createPendingUpload(t)
+
+	scanAttachment(attachment.ID)
+
+	for _, id := range []uint{attachment.ID, thumb.ID} {
+		var a models.Attachment
+		models.DB.First(&a, id)
+		if a.ScanStatus != models.ScanUnscannable {
+			t.Errorf("附件 %d scan_status = %q, want unscannable", id, a.ScanStatus)
+		}
+	}
+	var m models.Message
+	models.DB.First(&m, message.ID)
+	if m.FileStatus != models.FileStatusUnscannable {
+		t.Errorf("file_status = %q, want unscannable", m.FileStatus)
+	}
+	if _, err := openStoredFile(attachment.FileURL); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("openStoredFile: err = %v, want ErrNotFound", err)
+	}
+	msgs := drain()
+	if len(msgs) != 1 || msgs[0].MessageID != message.ID || msgs[0].FileStatus != models.FileStatusUnscannable {
+		t.Errorf("广播 = %+v, want one unscannable file_scanned", msgs)
+	}
+}
+
+func TestScanPendingAttachmentsBacksOff(t *testing.T) {
+	setupTestDB(t)
+	fake := &scanner.Fake{Err: errors.New("clamd 不可用")}
+	withScanner(t, fake)
+	createPendingUpload(t)
+
+	// 扫描失败的附件推迟到下次重试时间，不会在每一轮都被重新扫描
+	scanPendingAttachments()
+	scanPendingAttachments()
+	if fake.Calls() != 1 {
+		t.Errorf("扫描次数 = %d, want 1", fake.Calls())
+	}
+
+	models.DB.Model(&models.Attachment{}).Where("kind = ?", models.AttachmentKindFile).
+		Update("next_scan_at", time.Now().Add(-time.Second))
+	scanPendingAttachments()
+	if fake.Calls() != 2 {
+		t.Errorf("到达重试时间后扫描次数 = %d, want 2", fake.Calls())
+	}
+}
+
+func TestScanRetryDelay(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{1: scanInterval, 2: 2 * scanInterval, 3: 4 * scanInterval, 1000: maxScanBackoff} {
+		if got := scanRetryDelay(attempts); got != want {
+			t.Errorf("scanRetryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
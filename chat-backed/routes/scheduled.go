@@ -0,0 +1,465 @@
+package routes
+
+import (
+	"go-chat/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 定时消息调度器的检查间隔和每轮处理数量
+const (
+	scheduledInterval  = 5 * time.Second
+	scheduledBatchSize = 100
+)
+
+// 数据库等临时错误最多重试的次数，超过后标记为发送失败，避免一直卡在待发送状态
+const maxScheduledAttempts = 10
+
+// 定时消息最多可提前设置的时间
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// scheduledMessageRequest 创建或修改定时消息的请求
+type scheduledMessageRequest struct {
+	Content     string `json:"content"`
+	MessageType string `json:"message_type"`
+	FileURL     string `json:"file_url"`
+	FileName    string `json:"file_name"`
+	FileSize    int64  `json:"file_size"`
+	Target      uint   `json:"target"`      // 私聊目标用户ID
+	GroupID     uint   `json:"group_id"`    // 群组ID
+	ChannelID   uint   `json:"channel_id"`  // 群组内的频道ID
+	ExpireMode  string `json:"expire_mode"` // 阅后即焚计时方式
+	TTL         int    `json:"ttl"`         // 阅后即焚存活时间（秒）
+	SendAt      string `json:"send_at"`     // 发送时间，RFC3339 或 2006-01-02 15:04:05
+}
+
+// scheduledRequestFrom 用已有的定时消息填充请求，修改时只需提交要变更的字段
+func scheduledRequestFrom(sm *models.ScheduledMessage) scheduledMessageRequest {
+	return scheduledMessageRequest{
+		Content:     sm.Content,
+		MessageType: sm.MessageType,
+		FileURL:     sm.FileURL,
+		FileName:    sm.FileName,
+		FileSize:    sm.FileSize,
+		Target:      sm.TargetID,
+		GroupID:     sm.GroupID,
+		ChannelID:   sm.ChannelID,
+		ExpireMode:  sm.ExpireMode,
+		TTL:         sm.ExpireTTL,
+		SendAt:      sm.SendAt.Format(time.RFC3339),
+	}
+}
+
+// apply 校验请求并写入定时消息。会话必须是私聊或群聊之一，发送者当前需要有权在该会话发言
+func (r *scheduledMessageRequest) apply(userID uint, sm *models.ScheduledMessage) *groupError {
+	if r.MessageType == "" {
+		r.MessageType = "text"
+	}
+	if r.Content == "" && r.FileURL == "" {
+		return newGroupError(http.StatusBadRequest, "消息内容不能为空")
+	}
+	// 与实时消息一致：以 // 开头表示字面量斜杠；定时消息不支持斜杠命令
+	if r.MessageType == "text" {
+		if strings.HasPrefix(r.Content, "//") {
+			r.Content = r.Content[1:]
+		} else if strings.HasPrefix(r.Content, "/") {
+			return newGroupError(http.StatusBadRequest, "定时消息不支持斜杠命令")
+		}
+	}
+
+	sendAt, err := parseScheduleTime(r.SendAt)
+	if err != nil {
+		return newGroupError(http.StatusBadRequest, "send_at格式错误，应为RFC3339或 2006-01-02 15:04:05")
+	}
+	if !sendAt.After(time.Now()) {
+		return newGroupError(http.StatusBadRequest, "发送时间必须晚于当前时间")
+	}
+	if sendAt.After(time.Now().Add(maxScheduleAhead)) {
+		return newGroupError(http.StatusBadRequest, "发送时间不能超过一年")
+	}
+
+	if (r.GroupID == 0) == (r.Target == 0) {
+		return newGroupError(http.StatusBadRequest, "必须且只能指定私聊对象target或群组group_id之一")
+	}
+	if r.GroupID > 0 {
+		if _, gerr := authorizeSender(userID, r.GroupID, r.ChannelID); gerr != nil {
+			return gerr
+		}
+	} else {
+		r.ChannelID = 0
+		if r.Target == userID {
+			return newGroupError(http.StatusBadRequest, "不能给自己发送私聊消息")
+		}
+		var target models.User
+		if err := models.DB.Select("id").First(&target, r.Target).Error; err != nil {
+			return newGroupError(http.StatusNotFound, "目标用户不存在")
+		}
+	}
+
+	// 提前校验阅后即焚参数，发送时会按当时的会话设置再次确定
+	if _, _, gerr := resolveMessageExpiry(userID, r.GroupID, r.Target, r.TTL, r.ExpireMode); gerr != nil {
+		return gerr
+	}
+
+	sm.UserID = userID
+	sm.Content = r.Content
+	sm.MessageType = r.MessageType
+	sm.FileURL = r.FileURL
+	sm.FileName = r.FileName
+	sm.FileSize = r.FileSize
+	sm.GroupID = r.GroupID
+	sm.ChannelID = r.ChannelID
+	sm.TargetID = r.Target
+	sm.ExpireMode = r.ExpireMode
+	sm.ExpireTTL = r.TTL
+	sm.SendAt = sendAt
+	return nil
+}
+
+// parseScheduleTime 解析发送时间，支持 RFC3339 和本地时间 2006-01-02 15:04:05
+func parseScheduleTime(v string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// outgoingFromScheduled 将定时消息转换为统一发送流程的输入
+func outgoingFromScheduled(sm *models.ScheduledMessage) *OutgoingMessage {
+	return &OutgoingMessage{
+		Content:      sm.Content,
+		MessageType:  sm.MessageType,
+		FileURL:      sm.FileURL,
+		FileName:     sm.FileName,
+		FileSize:     sm.FileSize,
+		Target:       sm.TargetID,
+		GroupID:      sm.GroupID,
+		ChannelID:    sm.ChannelID,
+		ExpireMode:   sm.ExpireMode,
+		ExpireTTL:    sm.ExpireTTL,
+		SkipSlowMode: true,
+	}
+}
+
+// CreateScheduledMessage 创建定时消息
+func CreateScheduledMessage(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var req scheduledMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	sm := models.ScheduledMessage{Status: models.ScheduledPending}
+	if gerr := req.apply(userID, &sm); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	if err := models.DB.Create(&sm).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建定时消息失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "定时消息已创建",
+		"scheduled_message": sm,
+	})
+}
+
+// GetScheduledMessages 获取当前用户的定时消息列表，可按状态过滤
+func GetScheduledMessages(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	query := models.DB.Model(&models.ScheduledMessage{}).Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取定时消息失败"})
+		return
+	}
+
+	var messages []models.ScheduledMessage
+	if err := query.Order("send_at asc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取定时消息失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scheduled_messages": messages,
+		"pagination": gin.H{
+			"page":     page,
+			"pageSize": pageSize,
+			"total":    total,
+		},
+	})
+}
+
+// findOwnScheduledMessage 按ID查询当前用户的定时消息
+func findOwnScheduledMessage(c *gin.Context, userID uint) (*models.ScheduledMessage, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的定时消息ID"})
+		return nil, false
+	}
+
+	var sm models.ScheduledMessage
+	if err := models.DB.Where("id = ? AND user_id = ?", uint(id), userID).First(&sm).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "定时消息不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询定时消息失败"})
+		}
+		return nil, false
+	}
+	return &sm, true
+}
+
+// UpdateScheduledMessage 修改尚未发送的定时消息，只需提交要变更的字段
+func UpdateScheduledMessage(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	sm, ok := findOwnScheduledMessage(c, userID)
+	if !ok {
+		return
+	}
+	if sm.Status != models.ScheduledPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "定时消息已发送或已取消，无法修改"})
+		return
+	}
+
+	req := scheduledRequestFrom(sm)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if gerr := req.apply(userID, sm); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	// 条件更新：调度器已开始发送时不再修改
+	result := models.DB.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND status = ?", sm.ID, models.ScheduledPending).
+		Updates(map[string]interface{}{
+			"content":      sm.Content,
+			"message_type": sm.MessageType,
+			"file_url":     sm.FileURL,
+			"file_name":    sm.FileName,
+			"file_size":    sm.FileSize,
+			"group_id":     sm.GroupID,
+			"channel_id":   sm.ChannelID,
+			"target_id":    sm.TargetID,
+			"expire_mode":  sm.ExpireMode,
+			"expire_ttl":   sm.ExpireTTL,
+			"send_at":      sm.SendAt,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改定时消息失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "定时消息已发送或已取消，无法修改"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "定时消息已修改",
+		"scheduled_message": sm,
+	})
+}
+
+// CancelScheduledMessage 取消尚未发送的定时消息
+func CancelScheduledMessage(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	sm, ok := findOwnScheduledMessage(c, userID)
+	if !ok {
+		return
+	}
+
+	result := models.DB.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND status = ?", sm.ID, models.ScheduledPending).
+		Update("status", models.ScheduledCancelled)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取消定时消息失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "定时消息已发送或已取消"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "定时消息已取消"})
+}
+
+// StartScheduledMessageWorker 启动定时消息调度器。待发送的消息保存在数据库中，
+// 服务重启后会继续发送已到期的消息
+func StartScheduledMessageWorker() {
+	go func() {
+		ticker := time.NewTicker(scheduledInterval)
+		defer ticker.Stop()
+
+		for {
+			dispatchDueScheduledMessages()
+			<-ticker.C
+		}
+	}()
+}
+
+// dispatchDueScheduledMessages 发送所有已到期的定时消息
+func dispatchDueScheduledMessages() {
+	for {
+		var ids []uint
+		if err := models.DB.Model(&models.ScheduledMessage{}).
+			Where("status = ? AND send_at <= ?", models.ScheduledPending, time.Now()).
+			Order("send_at asc").Limit(scheduledBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			log.Printf("查询到期定时消息失败: %v", err)
+			return
+		}
+
+		failed := 0
+		for _, id := range ids {
+			if err := sendScheduledMessage(id); err != nil {
+				log.Printf("发送定时消息 %d 失败: %v", id, err)
+				retryScheduledMessage(id)
+				failed++
+			}
+		}
+
+		// 发送出错的消息仍是待发送状态，立即查询会再次取到同一批，留到下一轮重试
+		if failed > 0 || len(ids) < scheduledBatchSize {
+			return
+		}
+	}
+}
+
+// retryScheduledMessage 记录一次临时错误，超过重试次数后标记为发送失败并通知发送者
+func retryScheduledMessage(id uint) {
+	if err := models.DB.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND status = ?", id, models.ScheduledPending).
+		Update("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+		log.Printf("记录定时消息 %d 的重试次数失败: %v", id, err)
+		return
+	}
+
+	var sm models.ScheduledMessage
+	if err := models.DB.First(&sm, id).Error; err != nil || sm.Status != models.ScheduledPending || sm.Attempts < maxScheduledAttempts {
+		return
+	}
+	result := models.DB.Model(&sm).Where("status = ?", models.ScheduledPending).Updates(map[string]interface{}{
+		"status": models.ScheduledFailed,
+		"error":  "多次重试后仍发送失败",
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	sm.Error = "多次重试后仍发送失败"
+	notifyScheduledFailed(&sm)
+}
+
+// sendScheduledMessage 发送一条定时消息。消息的保存和定时消息状态的更新在同一事务中完成，
+// 并对定时消息加行锁，保证即使重启或多实例部署也不会重复发送
+func sendScheduledMessage(id uint) error {
+	var sent *models.Message
+	var failed *models.ScheduledMessage
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var sm models.ScheduledMessage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", id, models.ScheduledPending).
+			First(&sm).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				// 已被其他实例发送或已取消
+				return nil
+			}
+			return err
+		}
+
+		// 发送者无权发言、附件已被占用等无法通过重试解决的错误，标记为发送失败
+		fail := func(gerr *groupError) error {
+			sm.Status = models.ScheduledFailed
+			sm.Error = gerr.Message
+			failed = &sm
+			return tx.Model(&sm).Updates(map[string]interface{}{
+				"status": models.ScheduledFailed,
+				"error":  gerr.Message,
+			}).Error
+		}
+
+		// 与实时消息走相同的校验流程：发送时仍需是群成员、未被禁言且有发送权限
+		message, gerr := prepareMessage(sm.UserID, lookupUsername(sm.UserID), outgoingFromScheduled(&sm))
+		if gerr != nil && gerr.Status >= http.StatusInternalServerError {
+			// 数据库等临时错误，回滚后保持待发送状态，下一轮重试
+			return gerr
+		}
+		if gerr != nil {
+			return fail(gerr)
+		}
+
+		// 在保存点中保存消息，失败时只回滚消息本身，仍可在本事务中更新定时消息的状态
+		if err := tx.Transaction(func(tx *gorm.DB) error {
+			return saveMessage(tx, message)
+		}); err != nil {
+			if gerr := saveMessageError(err); gerr.Status < http.StatusInternalServerError {
+				return fail(gerr)
+			}
+			return err
+		}
+		sent = message
+		return tx.Model(&sm).Updates(map[string]interface{}{
+			"status":     models.ScheduledSent,
+			"message_id": message.ID,
+			"sent_at":    message.CreatedAt,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if sent != nil {
+		broadcastChatMessage(sent)
+	}
+	if failed != nil {
+		notifyScheduledFailed(failed)
+	}
+	return nil
+}
+
+// notifyScheduledFailed 通知发送者定时消息发送失败
+func notifyScheduledFailed(sm *models.ScheduledMessage) {
+	SendToUsers(BroadcastMessage{
+		Type:        "scheduled_message_failed",
+		UserID:      sm.UserID,
+		Content:     "定时消息发送失败：" + sm.Error,
+		MessageType: "system",
+		Target:      sm.TargetID,
+		GroupID:     sm.GroupID,
+		ChannelID:   sm.ChannelID,
+		CreatedAt:   time.Now().Format("2006-01-02 15:04:05"),
+	}, sm.UserID)
+}
@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"go-chat/models"
+	"testing"
+	"time"
+)
+
+func TestRetryScheduledMessageGivesUp(t *testing.T) {
+	drain := setupTestDB(t)
+	sm := &models.ScheduledMessage{UserID: 1, Content: "hi", TargetID: 2, SendAt: time.Now(), Status: models.ScheduledPending}
+	if err := models.DB.Create(sm).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i < maxScheduledAttempts; i++ {
+		retryScheduledMessage(sm.ID)
+	}
+	var got models.ScheduledMessage
+	models.DB.First(&got, sm.ID)
+	if got.Status != models.ScheduledPending || got.Attempts != maxScheduledAttempts-1 {
+		t.Fatalf("重试 %d 次后 status = %q, attempts = %d; want pending", maxScheduledAttempts-1, got.Status, got.Attempts)
+	}
+	if msgs := drain(); len(msgs) != 0 {
+		t.Fatalf("广播 = %+v, want none", msgs)
+	}
+
+	retryScheduledMessage(sm.ID)
+	models.DB.First(&got, sm.ID)
+	if got.Status != models.ScheduledFailed || got.Error == "" {
+		t.Errorf("超过重试次数后 status = %q, error = %q; want failed", got.Status, got.Error)
+	}
+	msgs := drain()
+	if len(msgs) != 1 || msgs[0].Type != "scheduled_message_failed" || len(msgs[0].Recipients) != 1 || msgs[0].Recipients[0] != 1 {
+		t.Errorf("广播 = %+v, want one scheduled_message_failed to user 1", msgs)
+	}
+
+	// 已失败的定时消息不再计数
+	retryScheduledMessage(sm.ID)
+	models.DB.First(&got, sm.ID)
+	if got.Attempts != maxScheduledAttempts {
+		t.Errorf("attempts = %d, want %d", got.Attempts, maxScheduledAttempts)
+	}
+}
@@ -0,0 +1,151 @@
+package routes
+
+import (
+	"go-chat/models"
+	"go-chat/search"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchMessages 全文搜索聊天记录，只返回调用者有权访问的会话中的消息
+func SearchMessages(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	keyword := strings.TrimSpace(c.Query("q"))
+	if keyword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q 不能为空"})
+		return
+	}
+
+	// 限制长度，避免滥用
+	if len([]rune(keyword)) > 100 {
+		keyword = string([]rune(keyword)[:100])
+	}
+
+	// 分页参数
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	query := &search.Query{
+		Keyword:     keyword,
+		UserID:      userID,
+		MessageType: c.Query("type"),
+		Page:        page,
+		PageSize:    pageSize,
+	}
+
+	// 解析ID类过滤条件
+	for param, dst := range map[string]*uint{
+		"group_id":  &query.GroupID,
+		"peer_id":   &query.PeerID,
+		"sender_id": &query.SenderID,
+	} {
+		if v := c.Query(param); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 " + param})
+				return
+			}
+			*dst = uint(id)
+		}
+	}
+
+	// 解析时间范围（支持 2006-01-02 或 RFC3339），to 为日期时包含当天
+	if v := c.Query("from"); v != "" {
+		from, err := parseSearchTime(v, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 from 时间"})
+			return
+		}
+		query.From = &from
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := parseSearchTime(v, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 to 时间"})
+			return
+		}
+		query.To = &to
+	}
+
+	// 查询调用者所在的群组，作为可访问范围
+	if err := models.DB.Model(&models.GroupMember{}).
+		Joins("JOIN `groups` ON `groups`.id = group_members.group_id AND `groups`.deleted_at IS NULL").
+		Where("group_members.user_id = ?", userID).
+		Pluck("group_members.group_id", &query.AllowedGroupIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询群组失败"})
+		return
+	}
+
+	// 排除无权访问的私有频道
+	query.ExcludedChannelIDs = inaccessibleChannelIDs(userID, query.AllowedGroupIDs)
+
+	if query.GroupID > 0 && !containsUint(query.AllowedGroupIDs, query.GroupID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "您不是该群组成员"})
+		return
+	}
+
+	engine, err := search.Get(os.Getenv("SEARCH_ENGINE"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := engine.Search(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索消息失败"})
+		return
+	}
+
+	// 计算总页数
+	totalPages := int(result.Total) / pageSize
+	if int(result.Total)%pageSize > 0 {
+		totalPages++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": result.Hits,
+		"pagination": gin.H{
+			"page":       page,
+			"pageSize":   pageSize,
+			"total":      result.Total,
+			"totalPages": totalPages,
+			"hasNext":    page < totalPages,
+			"hasPrev":    page > 1,
+		},
+	})
+}
+
+// parseSearchTime 解析搜索时间参数，endOfDay 为 true 时日期格式取次日零点（即包含当天）
+func parseSearchTime(v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+func containsUint(list []uint, v uint) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
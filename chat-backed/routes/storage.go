@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"go-chat/models"
+	"go-chat/storage"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+// fileStorage 上传文件的存储后端，由 InitStorage 根据配置创建
+var fileStorage storage.Storage = storage.NewLocal(uploadPath)
+
+// InitStorage 根据环境变量（STORAGE_BACKEND 等，见 storage.FromEnv）初始化存储后端，并创建上传暂存目录
+func InitStorage() {
+	s, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("初始化文件存储失败: %v", err)
+	}
+	fileStorage = s
+	if err := os.MkdirAll(uploadTempDir(), os.ModePerm); err != nil {
+		log.Fatalf("创建上传暂存目录失败: %v", err)
+	}
+}
+
+// uploadTempDir 上传暂存目录（环境变量 UPLOAD_TEMP_DIR，默认 ./uploads/tmp）：
+// 文件先保存在本机完成类型检查、图片处理和分片合并，再写入存储后端
+func uploadTempDir() string {
+	if dir := os.Getenv("UPLOAD_TEMP_DIR"); dir != "" {
+		return dir
+	}
+	return path.Join(uploadPath, "tmp")
+}
+
+// storageKey 将文件地址转换为存储中的键，如 /uploads/avatars/a.png 对应 avatars/a.png，
+// 不是上传文件的地址返回 false
+func storageKey(fileURL string) (string, bool) {
+	if !strings.HasPrefix(fileURL, "/uploads/") {
+		return "", false
+	}
+	key := strings.TrimPrefix(fileURL, "/uploads/")
+	if key == "" || path.Clean(key) != key || strings.HasPrefix(key, "../") {
+		return "", false
+	}
+	return key, true
+}
+
+// attachmentStorageKey 附件在存储后端中的键：去重后的文件按内容哈希存储，之前上传的文件按地址存储
+func attachmentStorageKey(attachment *models.Attachment) (string, bool) {
+	if attachment.BlobHash != "" {
+		return models.BlobKey(attachment.BlobHash), true
+	}
+	return storageKey(attachment.FileURL)
+}
+
+// putStagedFile 将暂存目录中的文件按内容写入存储后端，返回内容哈希
+func putStagedFile(stagedPath, contentType string) (string, error) {
+	f, err := os.Open(stagedPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash, _, err := models.PutBlob(fileStorage, f, contentType)
+	return hash, err
+}
+
+// openStoredFile 读取上传文件，等待安全扫描或无法扫描的文件视为不存在
+func openStoredFile(fileURL string) (io.ReadCloser, error) {
+	key, ok := storageKey(fileURL)
+	if attachment, err := findAttachment(fileURL); err == nil {
+		if attachment.Quarantined() {
+			return nil, storage.ErrNotFound
+		}
+		key, ok = attachmentStorageKey(attachment)
+	}
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return fileStorage.Open(key)
+}
+
+// deleteStoredFile 删除按地址存储的上传文件，失败时只记录日志
+func deleteStoredFile(fileURL string) {
+	key, ok := storageKey(fileURL)
+	if !ok {
+		return
+	}
+	if err := fileStorage.Delete(key); err != nil {
+		log.Printf("删除上传文件失败: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"go-chat/models"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 默认存储配额，可通过环境变量 STORAGE_QUOTA_USER、STORAGE_QUOTA_GROUP 修改，设置为 0 表示不限制
+const (
+	defaultUserStorageQuota  = 1 << 30 // 1GB
+	defaultGroupStorageQuota = 5 << 30 // 5GB
+)
+
+// defaultStorageQuota 读取用户或群组的默认配额，0 表示不限制
+func defaultStorageQuota(ownerType string) int64 {
+	env, quota := "STORAGE_QUOTA_USER", int64(defaultUserStorageQuota)
+	if ownerType == models.StorageOwnerGroup {
+		env, quota = "STORAGE_QUOTA_GROUP", int64(defaultGroupStorageQuota)
+	}
+	if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+		if v == "0" {
+			return 0
+		}
+		if n, err := parseByteSize(v); err == nil {
+			return n
+		}
+		log.Printf("警告: %s=%s 无效，使用默认值", env, v)
+	}
+	return quota
+}
+
+// loadStorageUsage 查询用户或群组的存储用量，没有记录时返回零用量
+func loadStorageUsage(ownerType string, ownerID uint) *models.StorageUsage {
+	usage := models.StorageUsage{OwnerType: ownerType, OwnerID: ownerID}
+	models.DB.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).First(&usage)
+	return &usage
+}
+
+// storageQuota 生效的配额：管理员单独设置的优先，否则使用默认配额。0 表示不限制
+func storageQuota(usage *models.StorageUsage) int64 {
+	if usage.QuotaBytes != nil {
+		return *usage.QuotaBytes
+	}
+	return defaultStorageQuota(usage.OwnerType)
+}
+
+// checkStorageQuota 检查再存入 size 字节后是否超过用户或群组的配额
+func checkStorageQuota(ownerType string, ownerID uint, size int64) *groupError {
+	usage := loadStorageUsage(ownerType, ownerID)
+	quota := storageQuota(usage)
+	if quota == 0 || usage.UsedBytes+size <= quota {
+		return nil
+	}
+	return storageQuotaExceeded(usage, quota, size)
+}
+
+// storageQuotaExceeded 构造超过配额时的错误
+func storageQuotaExceeded(usage *models.StorageUsage, quota, size int64) *groupError {
+	owner := "你的"
+	if usage.OwnerType == models.StorageOwnerGroup {
+		owner = "群组"
+	}
+	return newGroupError(http.StatusRequestEntityTooLarge, fmt.Sprintf("%s存储空间不足：已使用 %s，配额 %s，该文件 %s",
+		owner, formatByteSize(usage.UsedBytes), formatByteSize(quota), formatByteSize(size)))
+}
+
+// reserveStorage 在事务中按配额增加一个文件的存储用量，超过配额时返回 413 的 *groupError。
+// 前面的 checkStorageQuota 只用于尽早拒绝，并发上传时以这里的结果为准
+func reserveStorage(tx *gorm.DB, ownerType string, ownerID uint, size int64) error {
+	err := models.ReserveStorageUsage(tx, ownerType, ownerID, size, 1, defaultStorageQuota(ownerType))
+	if errors.Is(err, models.ErrStorageQuotaExceeded) {
+		usage := models.StorageUsage{OwnerType: ownerType, OwnerID: ownerID}
+		tx.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).First(&usage)
+		return storageQuotaExceeded(&usage, storageQuota(&usage), size)
+	}
+	return err
+}
+
+// storageUsageResponse 存储用量响应，quota_bytes 为 0 表示不限制
+func storageUsageResponse(usage *models.StorageUsage) gin.H {
+	quota := storageQuota(usage)
+	resp := gin.H{
+		"owner_type":  usage.OwnerType,
+		"owner_id":    usage.OwnerID,
+		"used_bytes":  usage.UsedBytes,
+		"file_count":  usage.FileCount,
+		"quota_bytes": quota,
+		"custom":      usage.QuotaBytes != nil,
+	}
+	if quota > 0 {
+		resp["remaining_bytes"] = max(quota-usage.UsedBytes, 0)
+	}
+	return resp
+}
+
+// GetStorageUsage 获取当前用户的存储用量和配额
+func GetStorageUsage(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	c.JSON(http.StatusOK, storageUsageResponse(loadStorageUsage(models.StorageOwnerUser, userID)))
+}
+
+// storageOwnerParams 解析管理接口路径中的归属类型和ID
+func storageOwnerParams(c *gin.Context) (string, uint, bool) {
+	ownerType := c.Param("type")
+	ownerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if (ownerType != models.StorageOwnerUser && ownerType != models.StorageOwnerGroup) || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户或群组"})
+		return "", 0, false
+	}
+	return ownerType, uint(ownerID), true
+}
+
+// AdminGetStorageUsage 管理员查看用户或群组的存储用量
+func AdminGetStorageUsage(c *gin.Context) {
+	ownerType, ownerID, ok := storageOwnerParams(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, storageUsageResponse(loadStorageUsage(ownerType, ownerID)))
+}
+
+type storageQuotaRequest struct {
+	QuotaBytes *int64 `json:"quota_bytes"` // 为空时恢复默认配额，0 表示不限制
+}
+
+// AdminSetStorageQuota 管理员设置用户或群组的存储配额
+func AdminSetStorageQuota(c *gin.Context) {
+	ownerType, ownerID, ok := storageOwnerParams(c)
+	if !ok {
+		return
+	}
+
+	var req storageQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if req.QuotaBytes != nil && *req.QuotaBytes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "配额不能为负数"})
+		return
+	}
+
+	if err := models.AddStorageUsage(models.DB, ownerType, ownerID, 0, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "设置配额失败"})
+		return
+	}
+	if err := models.DB.Model(&models.StorageUsage{}).
+		Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+		Update("quota_bytes", req.QuotaBytes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "设置配额失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, storageUsageResponse(loadStorageUsage(ownerType, ownerID)))
+}
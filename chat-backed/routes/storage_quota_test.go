@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"go-chat/models"
+	"net/http"
+	"testing"
+)
+
+func TestReserveStorage(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("STORAGE_QUOTA_USER", "100")
+
+	if err := reserveStorage(models.DB, models.StorageOwnerUser, 1, 60); err != nil {
+		t.Fatalf("第一个文件: %v", err)
+	}
+	// 检查和累加在同一条语句中，超出配额时用量不变
+	err := reserveStorage(models.DB, models.StorageOwnerUser, 1, 60)
+	gerr, ok := err.(*groupError)
+	if !ok || gerr.Status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("超出配额: err = %v, want 413", err)
+	}
+	if usage := loadStorageUsage(models.StorageOwnerUser, 1); usage.UsedBytes != 60 || usage.FileCount != 1 {
+		t.Errorf("used = %d, files = %d, want 60 and 1", usage.UsedBytes, usage.FileCount)
+	}
+	if err := reserveStorage(models.DB, models.StorageOwnerUser, 1, 40); err != nil {
+		t.Errorf("恰好用满配额: %v", err)
+	}
+
+	// 单独设置的配额优先，0 表示不限制
+	models.DB.Model(&models.StorageUsage{}).Where("owner_type = ? AND owner_id = ?", models.StorageOwnerUser, 1).Update("quota_bytes", 0)
+	if err := reserveStorage(models.DB, models.StorageOwnerUser, 1, 1<<20); err != nil {
+		t.Errorf("不限制配额: %v", err)
+	}
+}
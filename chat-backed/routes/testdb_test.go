@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"database/sql"
+	"fmt"
+	"go-chat/models"
+	"go-chat/storage"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func init() {
+	// 补充测试中用到的 MySQL 函数
+	sql.Register("sqlite3_chat", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("greatest", func(a, b int64) int64 { return max(a, b) }, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("least", func(a, b int64) int64 { return min(a, b) }, true)
+		},
+	})
+}
+
+func TestMain(m *testing.M) {
+	// 单元测试在包目录下运行，读取不到 .env，签发 token 和签名链接使用固定密钥
+	os.Setenv("JWT_SECRET", "test_jwt_secret_key")
+	os.Exit(m.Run())
+}
+
+// setupTestDB 使用临时的 SQLite 数据库和本地存储替换 models.DB 和 fileStorage，
+// 并为广播通道加上缓冲，返回收集广播消息的函数
+func setupTestDB(t *testing.T) func() []BroadcastMessage {
+	t.Helper()
+	db, err := gorm.Open(sqlite.New(sqlite.Config{
+		DriverName: "sqlite3_chat",
+		DSN:        filepath.Join(t.TempDir(), "chat.db") + "?_busy_timeout=5000",
+	}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Group{}, &models.Message{}, &models.Attachment{}, &models.Blob{},
+		&models.StorageUsage{}, &models.ScheduledMessage{}, &models.PinnedMessage{}, &models.GroupRetentionPolicy{},
+		&models.GroupMember{}, &models.GroupBan{}, &models.GroupInvite{}, &models.GroupJoinRequest{}, &models.GroupRole{},
+		&models.GroupAuditLog{}, &models.GroupChannel{}, &models.GroupChannelMember{}, &models.DisappearingSetting{},
+		&models.UploadSession{}, &models.ExportJob{}); err != nil {
+		t.Fatalf("创建测试表失败: %v", err)
+	}
+
+	oldDB, oldStorage, oldBroadcast := models.DB, fileStorage, broadcast
+	models.DB = db
+	fileStorage = storage.NewLocal(filepath.Join(t.TempDir(), "uploads"))
+	broadcast = make(chan BroadcastMessage, 100)
+	// 慢速模式的发言记录按群组和用户ID保存，每个测试的数据库从头分配ID
+	slowModeTracker.Lock()
+	clear(slowModeTracker.lastPost)
+	slowModeTracker.Unlock()
+	t.Cleanup(func() {
+		models.DB, fileStorage, broadcast = oldDB, oldStorage, oldBroadcast
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return func() []BroadcastMessage {
+		var msgs []BroadcastMessage
+		for {
+			select {
+			case msg := <-broadcast:
+				msgs = append(msgs, msg)
+			case <-time.After(10 * time.Millisecond):
+				return msgs
+			}
+		}
+	}
+}
+
+// createTestGroup 创建群组及其成员，members 为用户名到角色的映射，返回群组和用户名到用户的映射
+func createTestGroup(t *testing.T, owner string, members map[string]string) (*models.Group, map[string]*models.User) {
+	t.Helper()
+	users := make(map[string]*models.User)
+	roles := map[string]string{owner: models.RoleOwner}
+	for name, role := range members {
+		roles[name] = role
+	}
+	for name := range roles {
+		user := &models.User{Username: name}
+		if err := models.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+		users[name] = user
+	}
+
+	group := &models.Group{Name: owner + " 的群组", OwnerID: users[owner].ID}
+	if err := models.DB.Create(group).Error; err != nil {
+		t.Fatal(err)
+	}
+	for name, role := range roles {
+		if err := models.DB.Create(&models.GroupMember{GroupID: group.ID, UserID: users[name].ID, Role: role}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return group, users
+}
+
+// callHandler 以 userID 的身份调用接口，body 为 JSON 请求体，params 为交替的路由参数名和值
+func callHandler(handler gin.HandlerFunc, userID uint, body string, params ...interface{}) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("userID", userID)
+	for i := 0; i+1 < len(params); i += 2 {
+		c.Params = append(c.Params, gin.Param{Key: fmt.Sprint(params[i]), Value: fmt.Sprint(params[i+1])})
+	}
+	handler(c)
+	return w
+}
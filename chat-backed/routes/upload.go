@@ -1,35 +1,26 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
+	"go-chat/models"
 	"go-chat/utils"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// 定义允许的文件类型和最大文件大小
+// 定义默认的最大文件大小，分类型的限制见 upload_policy.go
 const (
 	maxUploadSize = 10 * 1024 * 1024 // 10MB
-	uploadPath    = "./uploads"
+	uploadPath    = "./uploads"      // 本地存储的默认目录
 )
 
-// 初始化上传目录
-// init 函数在程序启动时自动执行，用于进行初始化操作
-func init() {
-	// 检查上传路径是否存在，如果不存在则创建
-	// os.Stat 函数用于获取文件或目录的信息
-	// 如果返回的错误类型为 os.IsNotExist，则表示目录不存在
-	if _, err := os.Stat(uploadPath); os.IsNotExist(err) {
-		// 使用 MkdirAll 创建目录，包括所有必要的父目录
-		// os.ModePerm 表示设置权限为 0777，即所有用户都有读、写、执行权限
-		os.MkdirAll(uploadPath, os.ModePerm)
-	}
-}
-
 // UploadFile 处理文件上传
 func UploadFile(c *gin.Context) {
 	// 验证用户身份
@@ -50,6 +41,10 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
+	// 限制请求体大小，避免读取超大文件
+	policy := loadUploadPolicy()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, policy.maxRequestSize()+1<<20)
+
 	// 解析多部分表单
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -57,42 +52,121 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
-	// 检查文件大小
-	if file.Size > maxUploadSize {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "文件太大，最大支持10MB"})
+	// 根据文件头识别类型，检查类型策略和该类型的大小限制
+	contentType, gerr := policy.check(file)
+	if gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
+	// 检查存储配额
+	if gerr := checkStorageQuota(models.StorageOwnerUser, claims.UserID, file.Size); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
 		return
 	}
 
 	// 生成唯一文件名
 	ext := filepath.Ext(file.Filename)
 	fileName := fmt.Sprintf("%d_%d%s", claims.UserID, time.Now().UnixNano(), ext)
-	filePath := filepath.Join(uploadPath, fileName)
+	stagedPath := filepath.Join(uploadTempDir(), fileName)
 
-	// 保存文件
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
+	// 先保存到暂存目录，处理后再写入存储后端
+	if err := c.SaveUploadedFile(file, stagedPath); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
 		return
 	}
 
+	// 记录附件归属，发送前只有上传者本人可以访问
+	attachment := models.Attachment{
+		FileURL:      "/uploads/" + fileName,
+		OriginalName: file.Filename,
+		Size:         file.Size,
+		ContentType:  contentType,
+		Kind:         models.AttachmentKindFile,
+		UploaderID:   claims.UserID,
+	}
+	if gerr := saveAttachment(&attachment, stagedPath); gerr != nil {
+		c.JSON(gerr.Status, gin.H{"error": gerr.Message})
+		return
+	}
+
 	// 返回文件信息
-	c.JSON(http.StatusOK, gin.H{
-		"success":   true,
-		"file_url":  "/uploads/" + fileName,
-		"file_name": file.Filename,
-		"file_size": file.Size,
-	})
+	c.JSON(http.StatusOK, uploadResponse(&attachment))
 }
 
-// 服务静态文件
-func ServeFile(c *gin.Context) {
-	fileName := c.Param("filename")
-	filePath := filepath.Join(uploadPath, fileName)
+// saveAttachment 处理暂存的上传文件（图片去除元数据并生成缩略图，音频读取时长和波形），按内容写入存储后端并记录附件。
+// 内容相同的文件只保存一份，大小计入上传者的存储用量。返回前删除暂存文件，失败时释放已写入的内容
+func saveAttachment(attachment *models.Attachment, stagedPath string) *groupError {
+	defer os.Remove(stagedPath)
 
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
-		return
+	thumbnails, err := processUploadedImage(stagedPath, attachment.ContentType, attachment)
+	if errors.Is(err, errImageTooLarge) {
+		return newGroupError(http.StatusRequestEntityTooLarge, "图片太大，无法处理")
+	}
+	if err != nil {
+		return newGroupError(http.StatusBadRequest, "无法解析图片文件")
+	}
+	processUploadedAudio(stagedPath, attachment.ContentType, attachment)
+
+	hash, err := putStagedFile(stagedPath, attachment.ContentType)
+	if err != nil {
+		log.Printf("写入文件存储失败: %v", err)
+		releaseAttachmentBlobs(thumbnails)
+		return newGroupError(http.StatusInternalServerError, "保存文件失败")
+	}
+	attachment.BlobHash = hash
+	// 启用扫描时文件先处于隔离状态，扫描通过后才能下载，缩略图跟随原图
+	attachment.ScanStatus = initialScanStatus(hash)
+	for i := range thumbnails {
+		thumbnails[i].ScanStatus = attachment.ScanStatus
 	}
 
-	c.File(filePath)
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(attachment).Error; err != nil {
+			return err
+		}
+		for i := range thumbnails {
+			thumbnails[i].ParentID = attachment.ID
+			if err := tx.Create(&thumbnails[i]).Error; err != nil {
+				return err
+			}
+		}
+		return reserveStorage(tx, models.StorageOwnerUser, attachment.UploaderID, attachment.Size)
+	})
+	if err != nil {
+		releaseAttachmentBlobs(append(thumbnails, *attachment))
+		var gerr *groupError
+		if errors.As(err, &gerr) {
+			return gerr
+		}
+		log.Printf("保存附件记录失败: %v", err)
+		return newGroupError(http.StatusInternalServerError, "保存文件失败")
+	}
+	if attachment.ScanStatus == models.ScanPending {
+		go scanAttachment(attachment.ID)
+	}
+	return nil
+}
+
+// uploadResponse 上传完成后返回的文件信息，file_url 用于发送消息
+func uploadResponse(attachment *models.Attachment) gin.H {
+	return gin.H{
+		"success":      true,
+		"file_url":     attachment.FileURL,
+		"file_name":    attachment.OriginalName,
+		"file_size":    attachment.Size,
+		"content_type": attachment.ContentType,
+		"width":        attachment.Width,
+		"height":       attachment.Height,
+		"blurhash":     attachment.Blurhash,
+		"thumbnails":   attachment.Thumbnails,
+		"duration_ms":  attachment.DurationMs,
+		"waveform":     attachment.Waveform,
+		"scan_status":  attachment.ScanStatus,
+	}
+}
+
+// 服务上传文件，需要登录或签名链接，并校验对所在会话的访问权限
+func ServeFile(c *gin.Context) {
+	serveAttachment(c, "/uploads/"+filepath.Base(c.Param("filename")))
 }
@@ -0,0 +1,205 @@
+package routes
+
+import (
+	"errors"
+	"go-chat/models"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	uploadGCInterval           = time.Hour
+	uploadGCBatchSize          = 500
+	defaultUploadGCGracePeriod = 24 * time.Hour
+)
+
+// uploadGCGracePeriod 读取未被引用的上传文件的保留时间（环境变量 UPLOAD_GC_GRACE_PERIOD，如 24h、7d），
+// 留出上传后发送消息或设置头像的时间
+func uploadGCGracePeriod() time.Duration {
+	if v := os.Getenv("UPLOAD_GC_GRACE_PERIOD"); v != "" {
+		if d, err := parseCommandDuration(v); err == nil {
+			return d
+		}
+		log.Printf("警告: UPLOAD_GC_GRACE_PERIOD=%s 无效，使用默认值", v)
+	}
+	return defaultUploadGCGracePeriod
+}
+
+// releaseAttachmentBlobs 释放附件对文件内容的引用，用于附件记录保存失败时
+func releaseAttachmentBlobs(attachments []models.Attachment) {
+	for _, a := range attachments {
+		if a.BlobHash == "" {
+			continue
+		}
+		if err := models.ReleaseBlob(models.DB, a.BlobHash); err != nil {
+			log.Printf("释放文件引用失败: %v", err)
+		}
+	}
+}
+
+// deleteAttachment 删除附件及其缩略图的记录，扣减上传者和所在群组的存储用量，
+// 并释放对文件内容的引用，内容由清理任务在引用归零后删除
+func deleteAttachment(attachment *models.Attachment) error {
+	var records []models.Attachment
+	models.DB.Where("parent_id = ? AND kind = ?", attachment.ID, models.AttachmentKindThumbnail).Find(&records)
+	records = append(records, *attachment)
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		for _, r := range records {
+			if err := tx.Delete(&models.Attachment{}, r.ID).Error; err != nil {
+				return err
+			}
+			if r.BlobHash != "" {
+				if err := models.ReleaseBlob(tx, r.BlobHash); err != nil {
+					return err
+				}
+			}
+		}
+		if err := models.AddStorageUsage(tx, models.StorageOwnerUser, attachment.UploaderID, -attachment.Size, -1); err != nil {
+			return err
+		}
+		if attachment.MessageID > 0 && attachment.GroupID > 0 {
+			return models.AddStorageUsage(tx, models.StorageOwnerGroup, attachment.GroupID, -attachment.Size, -1)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 去重之前上传的文件没有引用计数，直接删除
+	for _, r := range records {
+		if r.BlobHash == "" {
+			deleteStoredFile(r.FileURL)
+		}
+	}
+	return nil
+}
+
+// fileReferenced 上传文件是否仍被消息、待发送的定时消息或用户头像引用
+func fileReferenced(fileURL string) bool {
+	var refs int64
+	models.DB.Model(&models.Message{}).Where("file_url = ?", fileURL).Count(&refs)
+	if refs == 0 {
+		models.DB.Model(&models.ScheduledMessage{}).Where("file_url = ? AND status = ?", fileURL, models.ScheduledPending).Count(&refs)
+	}
+	if refs == 0 {
+		models.DB.Model(&models.User{}).Where("avatar = ?", fileURL).Count(&refs)
+	}
+	return refs > 0
+}
+
+// removeUploadedFile 删除不再被引用的上传文件（如消息过期、更换头像后），仍被引用时保留
+func removeUploadedFile(fileURL string) {
+	if _, ok := storageKey(fileURL); !ok {
+		return
+	}
+	if fileReferenced(fileURL) {
+		return
+	}
+
+	attachment, err := findAttachment(fileURL)
+	if err != nil {
+		deleteStoredFile(fileURL)
+		return
+	}
+	if err := deleteAttachment(attachment); err != nil {
+		log.Printf("删除附件失败: %v", err)
+	}
+}
+
+// StartUploadGCWorker 启动上传文件清理任务：删除超过保留时间且不再被引用的附件，
+// 再从存储后端删除引用计数归零的文件内容
+func StartUploadGCWorker() {
+	go func() {
+		ticker := time.NewTicker(uploadGCInterval)
+		defer ticker.Stop()
+
+		for {
+			collectAttachments()
+			collectBlobs()
+			<-ticker.C
+		}
+	}()
+}
+
+// collectAttachments 删除不再被引用的附件，缩略图随原图一起删除
+func collectAttachments() {
+	cutoff := time.Now().Add(-uploadGCGracePeriod())
+	removed := 0
+	var lastID uint
+	for {
+		var attachments []models.Attachment
+		err := models.DB.
+			Where("id > ? AND kind <> ? AND created_at < ?", lastID, models.AttachmentKindThumbnail, cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM messages m WHERE m.file_url = attachments.file_url)").
+			Where("NOT EXISTS (SELECT 1 FROM scheduled_messages s WHERE s.file_url = attachments.file_url AND s.status = ?)", models.ScheduledPending).
+			Where("NOT EXISTS (SELECT 1 FROM users u WHERE u.avatar = attachments.file_url)").
+			Order("id").Limit(uploadGCBatchSize).
+			Find(&attachments).Error
+		if err != nil {
+			log.Printf("查询未引用的附件失败: %v", err)
+			return
+		}
+
+		for i := range attachments {
+			lastID = attachments[i].ID
+			// 查询之后可能刚被发送，删除前再确认一次
+			if fileReferenced(attachments[i].FileURL) {
+				continue
+			}
+			if err := deleteAttachment(&attachments[i]); err != nil {
+				log.Printf("删除附件失败: %v", err)
+				return
+			}
+			removed++
+		}
+
+		if len(attachments) < uploadGCBatchSize {
+			break
+		}
+	}
+	if removed > 0 {
+		log.Printf("已清理 %d 个未被引用的附件", removed)
+	}
+}
+
+// collectBlobs 从存储后端删除引用计数归零的文件内容。删除时锁定记录，
+// 同时上传的相同内容会等待删除完成后重新写入
+func collectBlobs() {
+	var hashes []string
+	if err := models.DB.Model(&models.Blob{}).Where("ref_count = 0").Limit(uploadGCBatchSize).Pluck("hash", &hashes).Error; err != nil {
+		log.Printf("查询未引用的文件失败: %v", err)
+		return
+	}
+
+	removed := 0
+	for _, hash := range hashes {
+		err := models.DB.Transaction(func(tx *gorm.DB) error {
+			var blob models.Blob
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("hash = ? AND ref_count = 0", hash).First(&blob).Error; err != nil {
+				return err
+			}
+			if err := fileStorage.Delete(models.BlobKey(hash)); err != nil {
+				return err
+			}
+			return tx.Delete(&blob).Error
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("删除文件失败: %v", err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		log.Printf("已删除 %d 个未被引用的文件", removed)
+	}
+}
@@ -0,0 +1,365 @@
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 默认允许上传的文件类型（环境变量 UPLOAD_ALLOWED_TYPES 覆盖），支持 image/* 形式的通配
+const defaultAllowedTypes = "image/*,audio/*,video/*,text/plain,text/csv,text/markdown,application/json," +
+	"application/pdf,application/zip,application/x-gzip,application/x-rar-compressed,application/x-7z-compressed," +
+	"application/msword,application/vnd.ms-excel,application/vnd.ms-powerpoint," +
+	"application/vnd.openxmlformats-officedocument.*,application/vnd.oasis.opendocument.*,application/epub+zip"
+
+// 默认的分类型大小限制（环境变量 UPLOAD_SIZE_LIMITS 覆盖），按顺序匹配，* 匹配所有类型
+const defaultSizeLimits = "image/*=10MB,audio/*=20MB,video/*=50MB,*=10MB"
+
+// 头像只允许常见图片格式
+const maxAvatarSize = 5 * 1024 * 1024 // 5MB
+
+var avatarTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// 头像文件按识别出的类型命名，不使用上传时的扩展名
+var avatarExtensions = map[string]string{
+	"image/png": ".png", "image/jpeg": ".jpg", "image/gif": ".gif", "image/webp": ".webp",
+}
+
+// 无论配置如何都拒绝的类型：可在浏览器中执行脚本的文档和可执行文件
+var dangerousTypes = []string{
+	"text/html", "text/xml", "application/xml", "application/xhtml+xml", "image/svg+xml",
+	"text/javascript", "application/javascript", "text/x-shellscript",
+	"application/x-msdownload", "application/x-executable", "application/x-mach-binary",
+}
+
+var dangerousExtensions = map[string]bool{
+	".html": true, ".htm": true, ".xhtml": true, ".shtml": true, ".svg": true, ".svgz": true, ".xml": true,
+	".js": true, ".mjs": true, ".hta": true, ".php": true, ".jsp": true, ".asp": true, ".aspx": true,
+	".exe": true, ".dll": true, ".com": true, ".scr": true, ".msi": true, ".bat": true, ".cmd": true,
+	".ps1": true, ".vbs": true, ".wsf": true, ".sh": true, ".jar": true,
+}
+
+// 标准库无法识别的文件头
+var magicSignatures = []struct {
+	prefix      []byte
+	contentType string
+}{
+	{[]byte("MZ"), "application/x-msdownload"},
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xca\xfe\xba\xbe"), "application/x-mach-binary"},
+	{[]byte("#!"), "text/x-shellscript"},
+	{[]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), "application/x-ole-storage"},
+	{[]byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+}
+
+// 容器格式按扩展名细分：Office 文档实际是 zip 或 OLE 文件，纯文本按扩展名区分 CSV、Markdown 等
+var containerRefinements = map[string]map[string]string{
+	"application/zip": {
+		".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		".odt":  "application/vnd.oasis.opendocument.text",
+		".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+		".odp":  "application/vnd.oasis.opendocument.presentation",
+		".epub": "application/epub+zip",
+	},
+	"application/x-ole-storage": {
+		".doc": "application/msword",
+		".xls": "application/vnd.ms-excel",
+		".ppt": "application/vnd.ms-powerpoint",
+	},
+	"text/plain": {
+		".csv":  "text/csv",
+		".md":   "text/markdown",
+		".json": "application/json",
+	},
+	"application/ogg": {
+		".ogg":  "audio/ogg",
+		".oga":  "audio/ogg",
+		".opus": "audio/ogg",
+	},
+	"video/mp4": {
+		".m4a": "audio/mp4",
+	},
+}
+
+// sizeLimit 某一类型的大小限制
+type sizeLimit struct {
+	pattern string
+	max     int64
+}
+
+// uploadPolicy 上传文件的类型和大小策略
+type uploadPolicy struct {
+	allowed []string
+	denied  []string
+	limits  []sizeLimit
+}
+
+// loadUploadPolicy 读取上传策略配置：UPLOAD_ALLOWED_TYPES、UPLOAD_DENIED_TYPES（逗号分隔的 MIME 类型）
+// 和 UPLOAD_SIZE_LIMITS（如 image/*=10MB,video/*=50MB,*=10MB）
+func loadUploadPolicy() *uploadPolicy {
+	policy := &uploadPolicy{
+		allowed: splitTypes(defaultAllowedTypes),
+		denied:  splitTypes(os.Getenv("UPLOAD_DENIED_TYPES")),
+	}
+	if v := os.Getenv("UPLOAD_ALLOWED_TYPES"); v != "" {
+		policy.allowed = splitTypes(v)
+	}
+
+	policy.limits, _ = parseSizeLimits(defaultSizeLimits)
+	if v := os.Getenv("UPLOAD_SIZE_LIMITS"); v != "" {
+		if limits, err := parseSizeLimits(v); err == nil {
+			policy.limits = limits
+		} else {
+			log.Printf("警告: UPLOAD_SIZE_LIMITS=%s 无效，使用默认值: %v", v, err)
+		}
+	}
+	return policy
+}
+
+func splitTypes(s string) []string {
+	var types []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// parseSizeLimits 解析 类型=大小 列表
+func parseSizeLimits(s string) ([]sizeLimit, error) {
+	var limits []sizeLimit
+	for _, item := range splitTypes(s) {
+		pattern, size, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("无效的大小限制: %s", item)
+		}
+		max, err := parseByteSize(size)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, sizeLimit{pattern: strings.TrimSpace(pattern), max: max})
+	}
+	return limits, nil
+}
+
+// parseByteSize 解析 512KB、10MB、1GB 形式的大小（大小写不敏感）
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		scale  int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+	scale := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s, scale = strings.TrimSuffix(s, u.suffix), u.scale
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n <= 0 || n > math.MaxInt64/scale {
+		return 0, fmt.Errorf("无效的大小: %s", s)
+	}
+	return n * scale, nil
+}
+
+// matchType 判断类型是否匹配模式，模式支持 * 和 image/* 形式
+func matchType(pattern, contentType string) bool {
+	if pattern == "*" || pattern == "*/*" || pattern == contentType {
+		return true
+	}
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*"))
+	}
+	return false
+}
+
+func matchAny(patterns []string, contentType string) bool {
+	for _, p := range patterns {
+		if matchType(p, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxSizeFor 返回类型对应的大小上限
+func (p *uploadPolicy) maxSizeFor(contentType string) int64 {
+	for _, l := range p.limits {
+		if matchType(l.pattern, contentType) {
+			return l.max
+		}
+	}
+	return maxUploadSize
+}
+
+// maxSizeForName 按扩展名推测类型对应的大小上限，用于还没有文件内容时的预先检查（如创建断点续传会话），
+// 内容上传完成后再按识别出的类型检查。无法推测类型时使用所有类型中最大的限制
+func (p *uploadPolicy) maxSizeForName(fileName string) int64 {
+	mediaType, _, err := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))))
+	if err != nil {
+		return p.maxRequestSize()
+	}
+	return p.maxSizeFor(mediaType)
+}
+
+// maxRequestSize 请求体的上限，取所有类型中最大的限制
+func (p *uploadPolicy) maxRequestSize() int64 {
+	max := int64(maxUploadSize)
+	for _, l := range p.limits {
+		if l.max > max {
+			max = l.max
+		}
+	}
+	return max
+}
+
+// check 根据文件头识别类型并校验策略，返回识别出的类型
+func (p *uploadPolicy) check(file *multipart.FileHeader) (string, *groupError) {
+	contentType, gerr := checkUploadType(file, p.allowed, p.denied)
+	if gerr != nil {
+		return "", gerr
+	}
+	if max := p.maxSizeFor(contentType); file.Size > max {
+		return "", newGroupError(http.StatusRequestEntityTooLarge, fmt.Sprintf("文件太大，该类型最大支持%s", formatByteSize(max)))
+	}
+	return contentType, nil
+}
+
+// checkUploadType 拒绝危险的扩展名和类型，并要求识别出的类型在允许列表中
+func checkUploadType(file *multipart.FileHeader, allowed, denied []string) (string, *groupError) {
+	if gerr := checkUploadExtension(file.Filename); gerr != nil {
+		return "", gerr
+	}
+	f, err := file.Open()
+	if err != nil {
+		return "", newGroupError(http.StatusBadRequest, "无法读取文件")
+	}
+	defer f.Close()
+	return checkContentType(f, file.Filename, allowed, denied)
+}
+
+// checkUploadExtension 拒绝危险的扩展名，用于读取文件内容之前的快速检查
+func checkUploadExtension(fileName string) *groupError {
+	if dangerousExtensions[strings.ToLower(filepath.Ext(fileName))] {
+		return newGroupError(http.StatusUnsupportedMediaType, "不允许上传该类型的文件")
+	}
+	return nil
+}
+
+// checkContentType 识别文件内容的类型并校验允许和拒绝列表
+func checkContentType(r io.Reader, fileName string, allowed, denied []string) (string, *groupError) {
+	if gerr := checkUploadExtension(fileName); gerr != nil {
+		return "", gerr
+	}
+	contentType, err := sniffContentType(r, strings.ToLower(filepath.Ext(fileName)))
+	if err != nil {
+		return "", newGroupError(http.StatusBadRequest, "无法读取文件")
+	}
+
+	if matchAny(dangerousTypes, contentType) || matchAny(denied, contentType) {
+		return "", newGroupError(http.StatusUnsupportedMediaType, "不允许上传该类型的文件")
+	}
+	if !matchAny(allowed, contentType) {
+		return "", newGroupError(http.StatusUnsupportedMediaType, fmt.Sprintf("不支持的文件类型: %s", contentType))
+	}
+	return contentType, nil
+}
+
+// sniffContentType 根据文件头（而不是文件名）识别文件类型，扩展名只用于细分容器格式
+func sniffContentType(r io.Reader, ext string) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+
+	contentType := ""
+	for _, sig := range magicSignatures {
+		if bytes.HasPrefix(head, sig.prefix) {
+			contentType = sig.contentType
+			break
+		}
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(head)
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			contentType = mediaType
+		}
+	}
+
+	if refined, ok := containerRefinements[contentType][ext]; ok {
+		contentType = refined
+	}
+	return contentType, nil
+}
+
+// sniffStoredContentType 识别已保存文件的类型，用于没有记录类型的旧附件
+func sniffStoredContentType(fileURL string) string {
+	rc, err := openStoredFile(fileURL)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer rc.Close()
+	contentType, err := sniffContentType(rc, strings.ToLower(path.Ext(fileURL)))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return contentType
+}
+
+// safeFileHeaders 计算下载响应的 Content-Type 和 Content-Disposition：使用识别出的类型，
+// 只有图片、音视频内联显示，其他文件一律作为附件下载
+func safeFileHeaders(contentType, fileName string) (string, string) {
+	if matchAny(dangerousTypes, contentType) {
+		contentType = "application/octet-stream"
+	}
+	disposition := "attachment"
+	if matchAny([]string{"image/*", "audio/*", "video/*"}, contentType) {
+		disposition = "inline"
+	}
+	if header := mime.FormatMediaType(disposition, map[string]string{"filename": fileName}); fileName != "" && header != "" {
+		disposition = header
+	}
+	return contentType, disposition
+}
+
+// setSafeFileHeaders 设置下载响应头，并禁止浏览器再次猜测类型、禁止文件在本站源下执行脚本
+func setSafeFileHeaders(c *gin.Context, contentType, fileName string) {
+	contentType, disposition := safeFileHeaders(contentType, fileName)
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	c.Header("Content-Disposition", disposition)
+}
+
+// formatByteSize 将字节数格式化为 10MB 这样的形式
+func formatByteSize(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return fmt.Sprintf("%dGB", n>>30)
+	case n >= 1<<20:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n >= 1<<10:
+		return fmt.Sprintf("%dKB", n>>10)
+	}
+	return fmt.Sprintf("%dB", n)
+}
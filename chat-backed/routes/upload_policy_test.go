@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMaxSizeForName(t *testing.T) {
+	t.Setenv("UPLOAD_SIZE_LIMITS", "image/*=10MB,video/*=50MB,*=5MB")
+	policy := loadUploadPolicy()
+
+	tests := []struct {
+		name string
+		want int64
+	}{
+		{"photo.JPG", 10 << 20},
+		{"report.pdf", 5 << 20},
+		// 无法推测类型时使用最大的限制，完成上传时再按识别出的类型检查
+		{"noext", 50 << 20},
+		{"data.unknownext", 50 << 20},
+	}
+	for _, tt := range tests {
+		if got := policy.maxSizeForName(tt.name); got != tt.want {
+			t.Errorf("maxSizeForName(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{
+		"10MB":                 10 << 20,
+		" 2gb ":                2 << 30,
+		"512 KB":               512 << 10,
+		"100":                  100,
+		"8589934591GB":         8589934591 << 30,
+		"0":                    -1,
+		"-5MB":                 -1,
+		"abc":                  -1,
+		"8589934592GB":         -1, // 超出 int64 范围
+		"9223372036854775807B": math.MaxInt64,
+		"9223372036854775808":  -1,
+	}
+	for in, want := range tests {
+		got, err := parseByteSize(in)
+		if want < 0 {
+			if err == nil {
+				t.Errorf("parseByteSize(%q) = %d, want error", in, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+}
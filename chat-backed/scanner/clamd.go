@@ -0,0 +1,107 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamd 默认的 StreamMaxLength 为 25MB，超过时返回 size limit exceeded 错误，需要在 clamd.conf 中调大
+const clamdChunkSize = 64 << 10
+
+// Clamd 通过 clamd 的 INSTREAM 命令扫描文件内容
+// （https://linux.die.net/man/8/clamd）
+type Clamd struct {
+	network string // tcp 或 unix
+	address string
+	timeout time.Duration
+}
+
+// NewClamd 创建 clamd 客户端，address 形如 tcp://127.0.0.1:3310 或 unix:///var/run/clamav/clamd.ctl
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	network, addr, ok := strings.Cut(address, "://")
+	if !ok || (network != "tcp" && network != "unix") || addr == "" {
+		return nil, fmt.Errorf("无效的 CLAMD_ADDRESS: %s", address)
+	}
+	return &Clamd{network: network, address: addr, timeout: timeout}, nil
+}
+
+// Scan 按 INSTREAM 协议发送文件内容：每块内容前加 4 字节大端长度，以长度为 0 的块结束，
+// 响应为 "stream: OK"、"stream: <特征> FOUND" 或 "<原因> ERROR"
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("连接 clamd 失败: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, err
+	}
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := conn.Write(buf[:4+n]); werr != nil {
+				// clamd 超出大小限制时会提前关闭连接，尽量读取它的错误信息
+				if reply, rerr := readReply(conn); rerr == nil {
+					return parseReply(reply)
+				}
+				return Result{}, werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return Result{}, err
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, fmt.Errorf("读取 clamd 响应失败: %w", err)
+	}
+	return parseReply(reply)
+}
+
+// readReply 读取以 \0 结尾的响应
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && len(reply) == 0 {
+		return "", err
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.Contains(reply, "size limit exceeded"):
+		// 文件超过 clamd 的 StreamMaxLength
+		return Result{}, fmt.Errorf("%w: %s", ErrUnscannable, reply)
+	default:
+		return Result{}, fmt.Errorf("clamd 扫描失败: %s", reply)
+	}
+}
@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd 在本地端口上模拟 clamd：读完 INSTREAM 的内容后返回 reply
+func fakeClamd(t *testing.T, reply string) *Clamd {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if _, err := r.ReadString(0); err != nil {
+					return
+				}
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+						return
+					}
+				}
+				conn.Write([]byte(reply + "\x00"))
+			}()
+		}
+	}()
+
+	c, err := NewClamd("tcp://"+ln.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClamdScan(t *testing.T) {
+	result, err := fakeClamd(t, "stream: OK").Scan(context.Background(), strings.NewReader("hello"))
+	if err != nil || result.Infected {
+		t.Errorf("OK: result = %+v, err = %v", result, err)
+	}
+
+	result, err = fakeClamd(t, "stream: Eicar-Signature FOUND").Scan(context.Background(), strings.NewReader("X5O!P%@AP"))
+	if err != nil || !result.Infected || result.Signature != "Eicar-Signature" {
+		t.Errorf("FOUND: result = %+v, err = %v", result, err)
+	}
+
+	// 超出 StreamMaxLength 时重试也不会成功
+	_, err = fakeClamd(t, "INSTREAM size limit exceeded. ERROR").Scan(context.Background(), strings.NewReader("big"))
+	if !errors.Is(err, ErrUnscannable) {
+		t.Errorf("size limit: err = %v, want ErrUnscannable", err)
+	}
+
+	_, err = fakeClamd(t, "Can't allocate memory ERROR").Scan(context.Background(), strings.NewReader("x"))
+	if err == nil || errors.Is(err, ErrUnscannable) {
+		t.Errorf("ERROR: err = %v, want a retryable error", err)
+	}
+}
@@ -0,0 +1,31 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Fake 返回固定结果的扫描器，用于测试。会读完文件内容并记录扫描次数
+type Fake struct {
+	Result Result
+	Err    error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *Fake) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	io.Copy(io.Discard, r)
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	return f.Result, f.Err
+}
+
+// Calls 返回已扫描的次数
+func (f *Fake) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
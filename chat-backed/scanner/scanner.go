@@ -0,0 +1,55 @@
+// Package scanner 在上传文件可被下载前检查其中是否包含恶意软件。
+//
+// Scanner 是扫描器接口，默认实现 Clamd 通过 clamd 的 INSTREAM 协议把文件内容交给 ClamAV 守护进程扫描；
+// 测试中使用返回固定结果的 Fake 代替。
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Result 扫描结果
+type Result struct {
+	Infected  bool   // 是否检测到恶意软件
+	Signature string // 命中的特征名称，如 Eicar-Signature
+}
+
+// ErrUnscannable 文件超出扫描器的大小限制等原因无法扫描，重试也不会成功
+var ErrUnscannable = errors.New("文件无法扫描")
+
+// Scanner 扫描文件内容，需要支持并发调用
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// FromEnv 根据环境变量创建扫描器：SCANNER 为 clamd 时连接 CLAMD_ADDRESS（默认 tcp://127.0.0.1:3310，
+// 也可以是 unix:///var/run/clamav/clamd.ctl），CLAMD_TIMEOUT 为单个文件的扫描超时（默认 2m）。
+// SCANNER 为空时返回 nil，表示不扫描
+func FromEnv() (Scanner, error) {
+	switch kind := strings.ToLower(os.Getenv("SCANNER")); kind {
+	case "", "none":
+		return nil, nil
+	case "clamd":
+		address := os.Getenv("CLAMD_ADDRESS")
+		if address == "" {
+			address = "tcp://127.0.0.1:3310"
+		}
+		timeout := 2 * time.Minute
+		if v := os.Getenv("CLAMD_TIMEOUT"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("无效的 CLAMD_TIMEOUT: %s", v)
+			}
+			timeout = d
+		}
+		return NewClamd(address, timeout)
+	default:
+		return nil, fmt.Errorf("不支持的扫描器: %s", kind)
+	}
+}
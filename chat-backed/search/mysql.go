@@ -0,0 +1,117 @@
+package search
+
+import (
+	"go-chat/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// ngram 分词的最小词长（MySQL 默认 ngram_token_size = 2），更短的关键词回退为 LIKE 查询
+const ngramTokenSize = 2
+
+// 摘要中命中词两侧保留的字符数
+const snippetRadius = 40
+
+// MySQLEngine 基于 MySQL FULLTEXT 索引（ngram 分词）的搜索引擎
+type MySQLEngine struct{}
+
+// Search 实现 Engine 接口
+func (e *MySQLEngine) Search(q *Query) (*Result, error) {
+	terms := Terms(q.Keyword)
+
+	db := models.DB.Model(&models.Message{})
+	db = applyAccessScope(db, q)
+	db = applyFilters(db, q)
+	db = applyKeyword(db, terms)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var messages []models.Message
+	offset := (q.Page - 1) * q.PageSize
+	if err := db.Order("created_at desc").Offset(offset).Limit(q.PageSize).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, len(messages))
+	for _, m := range messages {
+		hits = append(hits, Hit{
+			Message: m,
+			Snippet: Highlight(m.Content, terms, snippetRadius),
+		})
+	}
+
+	return &Result{Hits: hits, Total: total}, nil
+}
+
+// applyAccessScope 限制只搜索调用者可访问的会话：所在群组、参与的私聊以及全局聊天
+func applyAccessScope(db *gorm.DB, q *Query) *gorm.DB {
+	scope := models.DB.Where("group_id = 0 AND target_id = 0").
+		Or("group_id = 0 AND target_id > 0 AND (user_id = ? OR target_id = ?)", q.UserID, q.UserID)
+	if len(q.AllowedGroupIDs) > 0 {
+		scope = scope.Or("group_id IN ?", q.AllowedGroupIDs)
+	}
+	// 已归档（所属群组已解散）和已到期待销毁的阅后即焚消息不参与搜索
+	db = db.Where("archived_at IS NULL").Where("expires_at IS NULL OR expires_at > ?", time.Now()).Where(scope)
+	if len(q.ExcludedChannelIDs) > 0 {
+		db = db.Where("channel_id NOT IN ?", q.ExcludedChannelIDs)
+	}
+	return db
+}
+
+// applyFilters 应用可选的过滤条件
+func applyFilters(db *gorm.DB, q *Query) *gorm.DB {
+	if q.GroupID > 0 {
+		db = db.Where("group_id = ?", q.GroupID)
+	}
+	if q.PeerID > 0 {
+		db = db.Where("group_id = 0 AND ((user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?))",
+			q.UserID, q.PeerID, q.PeerID, q.UserID)
+	}
+	if q.SenderID > 0 {
+		db = db.Where("user_id = ?", q.SenderID)
+	}
+	if q.From != nil {
+		db = db.Where("created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		db = db.Where("created_at < ?", *q.To)
+	}
+	if q.MessageType != "" {
+		db = db.Where("message_type = ?", q.MessageType)
+	}
+	return db
+}
+
+// applyKeyword 使用全文索引匹配关键词，过短的词回退为 LIKE
+func applyKeyword(db *gorm.DB, terms []string) *gorm.DB {
+	var fulltext []string
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < ngramTokenSize {
+			db = db.Where("content LIKE ?", "%"+EscapeLike(term)+"%")
+			continue
+		}
+		fulltext = append(fulltext, `+"`+escapeBoolean(term)+`"`)
+	}
+
+	if len(fulltext) > 0 {
+		db = db.Where("MATCH(content) AGAINST(? IN BOOLEAN MODE)", strings.Join(fulltext, " "))
+	}
+	return db
+}
+
+// escapeBoolean 去除布尔模式中的双引号，避免破坏短语查询
+func escapeBoolean(term string) string {
+	return strings.ReplaceAll(term, `"`, " ")
+}
+
+// EscapeLike 转义 LIKE 通配符，用于拼接 LIKE 模式的用户输入
+func EscapeLike(term string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(term)
+}
@@ -0,0 +1,90 @@
+package search
+
+import (
+	"go-chat/models"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"聊天群":     "聊天群",
+		"100%":    `100\%`,
+		"a_b":     `a\_b`,
+		`C:\temp`: `C:\\temp`,
+	}
+	for in, want := range tests {
+		if got := EscapeLike(in); got != want {
+			t.Errorf("EscapeLike(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestApplyAccessScope(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "chat.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Message{}); err != nil {
+		t.Fatal(err)
+	}
+	oldDB := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = oldDB })
+
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	messages := map[string]models.Message{
+		"global":          {UserID: 2},
+		"dm sent":         {UserID: 1, TargetID: 2},
+		"dm received":     {UserID: 3, TargetID: 1},
+		"others dm":       {UserID: 2, TargetID: 3},
+		"group":           {UserID: 2, GroupID: 10},
+		"other group":     {UserID: 2, GroupID: 11},
+		"private channel": {UserID: 2, GroupID: 10, ChannelID: 5},
+		"public channel":  {UserID: 2, GroupID: 10, ChannelID: 6},
+		"archived":        {UserID: 2, GroupID: 10, ArchivedAt: &past},
+		"expired":         {UserID: 2, GroupID: 10, ExpiresAt: &past},
+		"expiring":        {UserID: 2, GroupID: 10, ExpiresAt: &future},
+	}
+	names := make(map[uint]string)
+	for name, m := range messages {
+		m.Content = name
+		if err := db.Create(&m).Error; err != nil {
+			t.Fatal(err)
+		}
+		names[m.ID] = name
+	}
+
+	visible := func(q *Query) []string {
+		var ids []uint
+		if err := applyAccessScope(db.Model(&models.Message{}), q).Pluck("id", &ids).Error; err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, id := range ids {
+			got = append(got, names[id])
+		}
+		slices.Sort(got)
+		return got
+	}
+
+	got := visible(&Query{UserID: 1, AllowedGroupIDs: []uint{10}, ExcludedChannelIDs: []uint{5}})
+	want := []string{"dm received", "dm sent", "expiring", "global", "group", "public channel"}
+	if !slices.Equal(got, want) {
+		t.Errorf("可见消息 = %q, want %q", got, want)
+	}
+
+	// 不在任何群组中时只能看到全局聊天和自己的私聊
+	got = visible(&Query{UserID: 1})
+	want = []string{"dm received", "dm sent", "global"}
+	if !slices.Equal(got, want) {
+		t.Errorf("无群组时可见消息 = %q, want %q", got, want)
+	}
+}
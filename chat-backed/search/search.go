@@ -0,0 +1,173 @@
+package search
+
+import (
+	"fmt"
+	"go-chat/models"
+	"html"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Query 消息搜索条件
+type Query struct {
+	Keyword string
+
+	// 访问范围：调用者本人ID及其所在的群组ID列表，引擎只会返回这些会话中的消息
+	UserID             uint
+	AllowedGroupIDs    []uint
+	ExcludedChannelIDs []uint // 所在群组中调用者无权访问的私有频道
+
+	// 可选过滤条件
+	GroupID     uint       // 仅搜索指定群组
+	PeerID      uint       // 仅搜索与指定用户的私聊
+	SenderID    uint       // 仅搜索指定发送者
+	From        *time.Time // 起始时间（含）
+	To          *time.Time // 截止时间（不含）
+	MessageType string     // 消息类型: text, image, file
+
+	Page     int
+	PageSize int
+}
+
+// Hit 单条搜索结果
+type Hit struct {
+	Message models.Message `json:"message"`
+	Snippet string         `json:"snippet"` // 带 <mark> 高亮的摘要（已进行 HTML 转义）
+}
+
+// Result 搜索结果
+type Result struct {
+	Hits  []Hit `json:"hits"`
+	Total int64 `json:"total"`
+}
+
+// Engine 搜索引擎接口，不同的后端（MySQL 全文索引、Elasticsearch 等）实现该接口
+type Engine interface {
+	Search(q *Query) (*Result, error)
+}
+
+var engines = struct {
+	sync.RWMutex
+	m map[string]Engine
+}{m: make(map[string]Engine)}
+
+func init() {
+	Register("mysql", &MySQLEngine{})
+}
+
+// Register 注册搜索引擎
+func Register(name string, engine Engine) {
+	engines.Lock()
+	engines.m[name] = engine
+	engines.Unlock()
+}
+
+// Get 按名称获取搜索引擎，名称为空时返回默认的 MySQL 引擎
+func Get(name string) (Engine, error) {
+	if name == "" {
+		name = "mysql"
+	}
+
+	engines.RLock()
+	defer engines.RUnlock()
+
+	engine, ok := engines.m[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的搜索引擎: %s", name)
+	}
+	return engine, nil
+}
+
+// Terms 将关键词拆分为搜索词
+func Terms(keyword string) []string {
+	return strings.Fields(keyword)
+}
+
+// Highlight 截取 content 中第一个命中词附近的摘要，并用 <mark> 标记所有命中词
+func Highlight(content string, terms []string, radius int) string {
+	runes := []rune(content)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	lowerTerms := make([][]rune, 0, len(terms))
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+		t := []rune(term)
+		for i, r := range t {
+			t[i] = unicode.ToLower(r)
+		}
+		lowerTerms = append(lowerTerms, t)
+	}
+
+	// 标记每个字符是否属于命中词
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range lowerTerms {
+		for i := 0; i+len(term) <= len(lower); i++ {
+			if runesEqual(lower[i:i+len(term)], term) {
+				for j := i; j < i+len(term); j++ {
+					marked[j] = true
+				}
+				if first == -1 || i < first {
+					first = i
+				}
+			}
+		}
+	}
+
+	// 计算摘要窗口
+	start, end := 0, len(runes)
+	if first >= 0 {
+		start = first - radius
+		end = first + radius
+	} else {
+		end = 2 * radius
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	inMark := false
+	for i := start; i < end; i++ {
+		if marked[i] && !inMark {
+			sb.WriteString("<mark>")
+			inMark = true
+		} else if !marked[i] && inMark {
+			sb.WriteString("</mark>")
+			inMark = false
+		}
+		sb.WriteString(html.EscapeString(string(runes[i])))
+	}
+	if inMark {
+		sb.WriteString("</mark>")
+	}
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+func runesEqual(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+package search
+
+import "testing"
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		content string
+		terms   []string
+		radius  int
+		want    string
+	}{
+		// 不区分大小写，标记所有命中词，并对内容进行 HTML 转义
+		{"Hello <b>World</b> hello", []string{"hello"}, 100, "<mark>Hello</mark> &lt;b&gt;World&lt;/b&gt; <mark>hello</mark>"},
+		// 以第一个命中词为中心截取摘要，被截断的一侧加省略号
+		{"abcdefghij", []string{"f"}, 2, "…de<mark>f</mark>g…"},
+		{"我们今天去聊天群吧", []string{"聊天"}, 2, "…天去<mark>聊天</mark>…"},
+		// 相邻的命中词合并为一个标记
+		{"foobar baz", []string{"bar", "foo", ""}, 100, "<mark>foobar</mark> baz"},
+		// 没有命中时返回开头的摘要
+		{"abcdefghij", []string{"xyz"}, 3, "abcdef…"},
+		{"短消息", nil, 10, "短消息"},
+	}
+	for _, tt := range tests {
+		if got := Highlight(tt.content, tt.terms, tt.radius); got != tt.want {
+			t.Errorf("Highlight(%q, %q, %d) = %q, want %q", tt.content, tt.terms, tt.radius, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalPutOpenDelete(t *testing.T) {
+	root := t.TempDir()
+	l := NewLocal(root)
+
+	key := "blobs/ab/abcdef"
+	content := []byte("hello storage")
+	if err := l.Put(key, bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "blobs", "ab", "abcdef")); err != nil {
+		t.Fatalf("文件未写入存储目录: %v", err)
+	}
+
+	rc, err := l.Open(key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	// 本地存储返回的文件支持 Seek，下载时用于 Range 请求
+	if _, ok := rc.(io.Seeker); !ok {
+		t.Error("Open 返回的 Reader 未实现 io.Seeker")
+	}
+	rc.Close()
+	if !bytes.Equal(got, content) {
+		t.Errorf("Open = %q, want %q", got, content)
+	}
+
+	// 覆盖已有文件
+	if err := l.Put(key, strings.NewReader("v2"), 2, ""); err != nil {
+		t.Fatalf("Put overwrite: %v", err)
+	}
+	rc, _ = l.Open(key)
+	got, _ = io.ReadAll(rc)
+	rc.Close()
+	if string(got) != "v2" {
+		t.Errorf("after overwrite = %q, want v2", got)
+	}
+
+	if err := l.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := l.Open(key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := l.Delete(key); err != nil {
+		t.Errorf("Delete missing: %v", err)
+	}
+}
+
+// errReader 读取到一半时失败
+type errReader struct{ n int }
+
+func (r *errReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, errors.New("连接中断")
+	}
+	r.n--
+	p[0] = 'x'
+	return 1, nil
+}
+
+func TestLocalPutFailureKeepsOldFile(t *testing.T) {
+	root := t.TempDir()
+	l := NewLocal(root)
+
+	if err := l.Put("a.txt", strings.NewReader("old"), 3, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Put("a.txt", &errReader{n: 2}, 10, ""); err == nil {
+		t.Fatal("Put with failing reader succeeded")
+	}
+
+	rc, err := l.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(got) != "old" {
+		t.Errorf("content = %q, want old", got)
+	}
+	// 写入失败的临时文件已清理
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 1 {
+		t.Errorf("存储目录中有 %d 个文件，want 1", len(entries))
+	}
+}
+
+func TestLocalInvalidKey(t *testing.T) {
+	root := t.TempDir()
+	l := NewLocal(filepath.Join(root, "uploads"))
+
+	for _, key := range []string{"", "/etc/passwd", "../outside", "a/../../b", `a\b`, "a//b", "./a"} {
+		if err := l.Put(key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Put(%q) succeeded, want error", key)
+		}
+		if _, err := l.Open(key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q): err = %v, want invalid key error", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside")); !os.IsNotExist(err) {
+		t.Error("文件写到了存储目录之外")
+	}
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config S3 兼容存储的配置
+type S3Config struct {
+	Endpoint  string // 服务地址，如 https://s3.amazonaws.com、http://127.0.0.1:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // 使用 endpoint/bucket/key 形式的地址，否则使用 bucket.endpoint/key
+}
+
+// S3 S3 兼容的对象存储，使用签名 V4 直接调用 REST 接口
+type S3 struct {
+	endpoint  *url.URL
+	bucket    string
+	pathStyle bool
+	signer    *signer
+	client    *http.Client
+}
+
+// NewS3 创建 S3 兼容存储
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("S3 存储需要配置 S3_ENDPOINT、S3_BUCKET、S3_ACCESS_KEY 和 S3_SECRET_KEY")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("无效的 S3_ENDPOINT: %s", cfg.Endpoint)
+	}
+	return &S3{
+		endpoint:  endpoint,
+		bucket:    cfg.Bucket,
+		pathStyle: cfg.PathStyle,
+		signer:    &signer{accessKey: cfg.AccessKey, secretKey: cfg.SecretKey, region: cfg.Region},
+		client:    &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// objectURL 对象地址
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("无效的文件键: %s", key)
+	}
+	u := *s.endpoint
+	objectPath := "/" + key
+	if s.pathStyle {
+		objectPath = "/" + s.bucket + objectPath
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.Path = s.endpoint.Path + objectPath
+	u.RawPath = uriEscape(u.Path, false)
+	return &u, nil
+}
+
+// do 签名并发送请求，body 为空时使用空内容的哈希，否则不对内容签名
+func (s *S3) do(method, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	payloadHash := emptyPayload
+	if body != nil {
+		req.ContentLength = size
+		payloadHash = unsignedPayload
+	}
+	s.signer.sign(req, payloadHash, time.Now())
+	return s.client.Do(req)
+}
+
+func (s *S3) Put(key string, r io.Reader, size int64, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(http.MethodPut, key, r, size, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError("上传", key, resp)
+	}
+	return nil
+}
+
+func (s *S3) Open(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	defer resp.Body.Close()
+	return nil, responseError("读取", key, resp)
+}
+
+func (s *S3) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return responseError("删除", key, resp)
+	}
+	return nil
+}
+
+// PresignGet 生成限时下载地址，并通过 response-* 参数让存储服务返回指定的响应头
+func (s *S3) PresignGet(key string, ttl time.Duration, opts GetOptions) (string, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	extra := url.Values{}
+	if opts.ContentType != "" {
+		extra.Set("response-content-type", opts.ContentType)
+	}
+	if opts.ContentDisposition != "" {
+		extra.Set("response-content-disposition", opts.ContentDisposition)
+	}
+	return s.signer.presign(http.MethodGet, u, ttl, extra, time.Now()), nil
+}
+
+// responseError 读取错误响应中的部分内容，便于排查签名或权限问题
+func responseError(action, key string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s %s 失败: %s %s", action, key, resp.Status, strings.TrimSpace(string(body)))
+}
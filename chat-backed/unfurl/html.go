@@ -0,0 +1,63 @@
+package unfurl
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// parseHTML 读取页面 <head> 中的预览信息：OpenGraph 和 Twitter 的 <meta> 标签（以 property 或 name 为键）、
+// description、<title>，以及 oEmbed 接口地址（键为 oembed）。遇到 <body> 后停止解析
+func parseHTML(r io.Reader) (map[string]string, error) {
+	meta := make(map[string]string)
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return meta, nil
+			}
+			return meta, z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "body":
+				return meta, nil
+			case "title":
+				inTitle = true
+			case "meta":
+				key := strings.ToLower(firstNonEmpty(attr(tok, "property"), attr(tok, "name")))
+				if key != "" && meta[key] == "" {
+					meta[key] = attr(tok, "content")
+				}
+			case "link":
+				if strings.EqualFold(attr(tok, "rel"), "alternate") &&
+					strings.EqualFold(attr(tok, "type"), "application/json+oembed") && meta["oembed"] == "" {
+					meta["oembed"] = attr(tok, "href")
+				}
+			}
+		case html.TextToken:
+			if inTitle && meta["title"] == "" {
+				meta["title"] = string(z.Text())
+			}
+		case html.EndTagToken:
+			tok := z.Token()
+			if tok.Data == "title" {
+				inTitle = false
+			} else if tok.Data == "head" {
+				return meta, nil
+			}
+		}
+	}
+}
+
+func attr(tok html.Token, name string) string {
+	for _, a := range tok.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,29 @@
+package unfurl
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// oEmbed 响应中用于预览的字段（https://oembed.com/）
+type oEmbed struct {
+	Title        string `json:"title"`
+	ProviderName string `json:"provider_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// fetchOEmbed 请求页面声明的 oEmbed 接口
+func (f *Fetcher) fetchOEmbed(ctx context.Context, endpoint string) (*oEmbed, error) {
+	resp, err := f.get(ctx, endpoint, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var oe oEmbed
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBodySize)).Decode(&oe); err != nil {
+		return nil, err
+	}
+	return &oe, nil
+}
@@ -0,0 +1,127 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":                true,
+		"2606:4700:4700::1111":   true,
+		"::ffff:8.8.8.8":         true,
+		"127.0.0.1":              false,
+		"0.0.0.0":                false,
+		"10.1.2.3":               false,
+		"172.16.5.4":             false,
+		"172.31.255.255":         false,
+		"192.168.1.1":            false,
+		"100.64.0.1":             false,
+		"169.254.169.254":        false,
+		"::1":                    false,
+		"::":                     false,
+		"fe80::1":                false,
+		"fd12:3456::1":           false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:10.0.0.1":        false,
+		"::ffff:169.254.169.254": false,
+		"64:ff9b::a00:1":         false,
+	}
+	for s, want := range tests {
+		if got := publicAddr(netip.MustParseAddr(s)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com/page":                 true,
+		"http://8.8.8.8/":                          true,
+		"http://127.0.0.1/":                        false,
+		"http://[::1]:8080/":                       false,
+		"http://[::ffff:127.0.0.1]/":               false,
+		"http://[::ffff:192.168.0.1]/":             false,
+		"http://10.0.0.1/":                         false,
+		"http://192.168.1.1:8080/admin":            false,
+		"http://169.254.169.254/latest/meta-data/": false,
+		"http://[fe80::1]/":                        false,
+		"http://localhost:3000/":                   false,
+		"http://api.LOCALHOST/":                    false,
+		"ftp://example.com/":                       false,
+		"file:///etc/passwd":                       false,
+	}
+	for raw, want := range tests {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkURL(u); (err == nil) != want {
+			t.Errorf("checkURL(%s) = %v, want allowed = %v", raw, err, want)
+		}
+	}
+}
+
+func TestSafeDialControl(t *testing.T) {
+	for address, want := range map[string]bool{
+		"8.8.8.8:443":              true,
+		"[2606:4700::1111]:443":    true,
+		"127.0.0.1:80":             false,
+		"10.0.0.1:80":              false,
+		"[::1]:80":                 false,
+		"[::ffff:192.168.0.1]:443": false,
+		"[fe80::1%eth0]:80":        false,
+	} {
+		err := safeDialControl("tcp", address, nil)
+		if want && err != nil {
+			t.Errorf("safeDialControl(%s) = %v, want nil", address, err)
+		}
+		if !want && !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("safeDialControl(%s) = %v, want ErrBlockedAddress", address, err)
+		}
+	}
+}
+
+func TestFetchRedirectToPrivateAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host {
+		case "public.test":
+			http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<title>内网页面</title>"))
+		}
+	}))
+	defer srv.Close()
+
+	// public.test 视为公网地址直接连接测试服务；其他主机名都解析到测试服务所在的本机地址，
+	// 连接时经过 safeDialControl 检查
+	f := NewFetcher(5 * time.Second)
+	f.client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := &net.Dialer{}
+			if host, _, _ := net.SplitHostPort(address); host != "public.test" {
+				d.Control = safeDialControl
+			}
+			return d.DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}
+
+	for _, target := range []string{
+		"http://127.0.0.1/",             // 重定向到内网 IP，由 CheckRedirect 拒绝
+		"http://[::ffff:127.0.0.1]/",    // IPv4 映射的 IPv6 地址
+		"http://internal.test/metadata", // 主机名解析到内网地址，由 safeDialControl 拒绝
+	} {
+		_, err := f.Fetch(context.Background(), "http://public.test/?to="+url.QueryEscape(target))
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("重定向到 %s: err = %v, want ErrBlockedAddress", target, err)
+		}
+	}
+}
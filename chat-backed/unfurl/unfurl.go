@@ -0,0 +1,208 @@
+// Package unfurl 抓取网页的链接预览（标题、描述和图片）。
+//
+// 优先读取页面的 OpenGraph 标签，缺少时使用页面声明的 oEmbed 接口和普通 <title>、description 标签。
+// 所有请求都只能访问公网地址（见 ssrf.go），并限制超时、重定向次数和响应大小。
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	maxBodySize  = 512 << 10 // 只读取页面开头的 512KB，<head> 中的标签足够
+	maxRedirects = 3
+	maxURLLength = 2048
+	userAgent    = "go-chat-linkpreview/1.0 (+https://github.com/hourmoon/go-chat)"
+)
+
+var (
+	// ErrBlockedAddress 目标地址是本机或内网地址
+	ErrBlockedAddress = errors.New("不允许访问内网地址")
+	// ErrNoPreview 页面没有可用于预览的信息
+	ErrNoPreview = errors.New("页面没有预览信息")
+)
+
+// Preview 链接预览
+type Preview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// Fetcher 抓取链接预览，可以并发使用
+type Fetcher struct {
+	client *http.Client
+}
+
+// NewFetcher 创建只能访问公网地址的抓取器，timeout 为单次抓取（包括重定向和 oEmbed 请求）的总时间
+func NewFetcher(timeout time.Duration) *Fetcher {
+	dialer := &net.Dialer{Timeout: timeout, Control: safeDialControl}
+	transport := &http.Transport{
+		Proxy:                 nil, // 经过代理时无法检查目标地址
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	return &Fetcher{client: &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("重定向次数过多")
+			}
+			return checkURL(req.URL)
+		},
+	}}
+}
+
+// urlPattern 匹配消息中的 http(s) 链接
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'\x{3000}-\x{303F}\x{FF00}-\x{FFEF}]+`)
+
+// FirstURL 返回文本中的第一个链接，去掉末尾的标点；没有时返回空
+func FirstURL(text string) string {
+	for _, m := range urlPattern.FindAllString(text, -1) {
+		m = strings.TrimRight(m, ".,;:!?)]}'\"")
+		if len(m) > maxURLLength {
+			continue
+		}
+		if u, err := url.Parse(m); err == nil && checkURL(u) == nil {
+			return u.String()
+		}
+	}
+	return ""
+}
+
+// checkURL 只允许 http 和 https 地址，主机为 IP 时必须是公网地址
+func checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("不支持的协议: %s", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" || strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return ErrBlockedAddress
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if addr, ok := netip.AddrFromSlice(ip); !ok || !publicAddr(addr) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
+
+// Fetch 抓取网页的预览信息
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkURL(u); err != nil {
+		return nil, err
+	}
+
+	resp, err := f.get(ctx, u.String(), "text/html,application/xhtml+xml")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNoPreview
+	}
+
+	// 以重定向后的地址为基准解析相对地址
+	page := resp.Request.URL
+	meta, err := parseHTML(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &Preview{
+		URL:         rawURL,
+		Title:       firstNonEmpty(meta["og:title"], meta["twitter:title"], meta["title"]),
+		Description: firstNonEmpty(meta["og:description"], meta["twitter:description"], meta["description"]),
+		Image:       firstNonEmpty(meta["og:image:secure_url"], meta["og:image"], meta["twitter:image"]),
+		SiteName:    meta["og:site_name"],
+	}
+	if (preview.Title == "" || preview.Image == "") && meta["oembed"] != "" {
+		if oembedURL, err := page.Parse(meta["oembed"]); err == nil && checkURL(oembedURL) == nil {
+			if oe, err := f.fetchOEmbed(ctx, oembedURL.String()); err == nil {
+				preview.Title = firstNonEmpty(preview.Title, oe.Title)
+				preview.Image = firstNonEmpty(preview.Image, oe.ThumbnailURL)
+				preview.SiteName = firstNonEmpty(preview.SiteName, oe.ProviderName)
+			}
+		}
+	}
+	if preview.Title == "" {
+		return nil, ErrNoPreview
+	}
+
+	preview.Image = resolveImage(page, preview.Image)
+	preview.Title = truncate(preview.Title, 200)
+	preview.Description = truncate(preview.Description, 500)
+	preview.SiteName = truncate(preview.SiteName, 100)
+	return preview, nil
+}
+
+// get 发送 GET 请求，非 2xx 响应返回错误
+func (f *Fetcher) get(ctx context.Context, rawURL, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", accept)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("请求 %s 失败: %s", rawURL, resp.Status)
+	}
+	return resp, nil
+}
+
+// resolveImage 将图片地址解析为绝对地址，只保留 http(s) 公网地址
+func resolveImage(page *url.URL, image string) string {
+	if image == "" {
+		return ""
+	}
+	u, err := page.Parse(strings.TrimSpace(image))
+	if err != nil || len(u.String()) > maxURLLength || checkURL(u) != nil {
+		return ""
+	}
+	return u.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// truncate 按字符截断过长的文本
+func truncate(s string, n int) string {
+	r := []rune(strings.Join(strings.Fields(s), " "))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IsAdmin 判断用户是否为系统管理员（环境变量 ADMIN_USER_IDS，逗号分隔的用户ID）
+func IsAdmin(userID uint) bool {
+	for _, s := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+		if err == nil && uint(id) == userID {
+			return true
+		}
+	}
+	return false
+}
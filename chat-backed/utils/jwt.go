@@ -4,15 +4,31 @@ import (
 	"errors"
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
 )
 
-var jwtKey []byte
+var (
+	jwtKey     []byte
+	jwtKeyOnce sync.Once
+)
+
+// InitJWT 读取 JWT_SECRET 初始化签名密钥，未设置时 panic。
+// 服务启动时调用以便尽早发现配置错误；未调用时在第一次签发或校验时初始化
+func InitJWT() {
+	jwtKeyOnce.Do(loadJWTKey)
+}
+
+// signingKey 返回 JWT 和签名链接共用的密钥
+func signingKey() []byte {
+	InitJWT()
+	return jwtKey
+}
 
-func init() {
+func loadJWTKey() {
 	// 尝试从环境变量获取 JWT_SECRET
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
@@ -54,13 +70,13 @@ func GenerateJWT(userID uint, username string) (string, error) {
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(jwtKey)
+	signed, err := token.SignedString(signingKey())
 	return signed, err
 }
 
 func ParseJWT(tokenStr string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
+		return signingKey(), nil
 	})
 
 	if err != nil || !token.Valid {
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+const randomCodeAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GenerateRandomCode 生成指定长度的随机码（去除了易混淆字符）
+func GenerateRandomCode(n int) (string, error) {
+	code := make([]byte, n)
+	max := big.NewInt(int64(len(randomCodeAlphabet)))
+	for i := range code {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = randomCodeAlphabet[idx.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET", "test_jwt_secret_key")
+	os.Exit(m.Run())
+}
+
+// splitSigned 拆分签名地址中的路径和签名参数
+func splitSigned(t *testing.T, signed string) (path, uid, expires, sig string) {
+	t.Helper()
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	return u.Path, q.Get("uid"), q.Get("expires"), q.Get("sig")
+}
+
+func TestSignFileURL(t *testing.T) {
+	signed, expiresAt := SignFileURL("/uploads/1_1700000000.png", 42, 10*time.Minute)
+	if d := time.Until(expiresAt); d <= 9*time.Minute || d > 10*time.Minute {
+		t.Errorf("expiresAt 距今 %v, want 10m", d)
+	}
+	path, uid, expires, sig := splitSigned(t, signed)
+	if userID, ok := VerifyFileSignature(path, uid, expires, sig); !ok || userID != 42 {
+		t.Fatalf("VerifyFileSignature = %d, %v; want 42, true", userID, ok)
+	}
+
+	forged := sig[:len(sig)-1] + "0"
+	if forged == sig {
+		forged = sig[:len(sig)-1] + "1"
+	}
+	later := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	tampered := map[string][4]string{
+		"其他文件":    {"/uploads/2_1700000000.png", uid, expires, sig},
+		"其他用户":    {path, "43", expires, sig},
+		"延长有效期":   {path, uid, later, sig},
+		"篡改签名":    {path, uid, expires, forged},
+		"空签名":     {path, uid, expires, ""},
+		"无效的用户ID": {path, "abc", expires, sig},
+		"无效的过期时间": {path, uid, "soon", sig},
+	}
+	for name, args := range tampered {
+		if _, ok := VerifyFileSignature(args[0], args[1], args[2], args[3]); ok {
+			t.Errorf("%s: 签名校验通过", name)
+		}
+	}
+}
+
+func TestSignFileURLExpired(t *testing.T) {
+	signed, _ := SignFileURL("/uploads/1_1700000000.png", 42, -time.Second)
+	path, uid, expires, sig := splitSigned(t, signed)
+	if _, ok := VerifyFileSignature(path, uid, expires, sig); ok {
+		t.Error("过期的签名校验通过")
+	}
+}
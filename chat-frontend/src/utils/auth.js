@@ -125,6 +125,17 @@ export const getAuthToken = () => {
   })
 }
 
+/**
+ * 为上传文件地址附加认证token，服务端下载上传文件需要认证
+ * @param {string} url 文件地址
+ * @returns {string} 带token的文件地址
+ */
+export const withFileToken = (url) => {
+  const token = authToken.value || sessionStorage.getItem('token') || localStorage.getItem('token')
+  if (!token || !url.includes('/uploads/')) return url
+  return `${url}${url.includes('?') ? '&' : '?'}token=${encodeURIComponent(token)}`
+}
+
 /**
  * 获取用户名
  * @returns {string|null} 返回用户名或null
@@ -0,0 +1,70 @@
+import request from './request'
+
+/**
+ * 文件上传服务层
+ * 小文件直接上传，大文件使用断点续传（分片上传，网络中断后从已接收的位置继续）
+ */
+
+// 超过该大小的文件使用断点续传
+const RESUMABLE_THRESHOLD = 10 * 1024 * 1024
+// 单个分片失败后的重试次数
+const MAX_CHUNK_RETRIES = 3
+
+// 计算文件或分片的 SHA-256（十六进制）
+const sha256Hex = async (blob) => {
+  const digest = await crypto.subtle.digest('SHA-256', await blob.arrayBuffer())
+  return Array.from(new Uint8Array(digest)).map(b => b.toString(16).padStart(2, '0')).join('')
+}
+
+// 直接上传
+const uploadDirect = (file) => {
+  const formData = new FormData()
+  formData.append('file', file)
+  return request.post('/upload', formData, {
+    headers: { 'Content-Type': 'multipart/form-data' }
+  })
+}
+
+// 断点续传：创建会话后按偏移量依次上传分片，失败时查询服务端已接收的位置重试
+const uploadResumable = async (file, onProgress) => {
+  const checksum = await sha256Hex(file)
+  const session = await request.post('/upload/sessions', {
+    file_name: file.name,
+    size: file.size,
+    checksum
+  })
+
+  let offset = session.offset
+  const chunkSize = session.chunk_size
+  let retries = 0
+  while (offset < file.size) {
+    const chunk = file.slice(offset, offset + chunkSize)
+    try {
+      const result = await request.patch(`/upload/sessions/${session.upload_id}`, chunk, {
+        headers: {
+          'Content-Type': 'application/offset+octet-stream',
+          'Upload-Offset': String(offset),
+          'Upload-Checksum': await sha256Hex(chunk)
+        },
+        timeout: 0
+      })
+      offset = result.offset
+      retries = 0
+      onProgress?.(Math.round(offset / file.size * 100))
+    } catch (error) {
+      if (++retries > MAX_CHUNK_RETRIES) throw error
+      const status = await request.get(`/upload/sessions/${session.upload_id}`)
+      offset = status.offset
+    }
+  }
+
+  return request.post(`/upload/sessions/${session.upload_id}/complete`, null, { timeout: 0 })
+}
+
+// 上传文件，返回 file_url 等文件信息
+export const uploadFile = (file, onProgress) => {
+  if (file.size > RESUMABLE_THRESHOLD) {
+    return uploadResumable(file, onProgress)
+  }
+  return uploadDirect(file)
+}